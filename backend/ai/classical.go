@@ -0,0 +1,137 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // Support JPEG decoding
+	"sort"
+)
+
+// ClassicalDetectorOptions configures the non-LLM room detector
+type ClassicalDetectorOptions struct {
+	Edge         EdgeDetectionOptions // Preprocessing used to find wall edges
+	WallDilation int                  // Dilation radius that closes small gaps in walls (default: 2)
+	MinAreaRatio float64              // Smallest room as a fraction of the image area (default: 0.002)
+	MaxAreaRatio float64              // Largest room as a fraction of the image area (default: 0.6)
+	MinSideRatio float64              // Shortest room side as a fraction of the image side (default: 0.02)
+	Walls        WallDetectionOptions // Wall and doorway extraction
+}
+
+// DefaultClassicalDetectorOptions returns sensible defaults
+func DefaultClassicalDetectorOptions() ClassicalDetectorOptions {
+	return ClassicalDetectorOptions{
+		Edge:         DefaultEdgeDetectionOptions(),
+		WallDilation: 2,
+		MinAreaRatio: 0.002,
+		MaxAreaRatio: 0.6,
+		MinSideRatio: 0.02,
+		Walls:        DefaultWallDetectionOptions(),
+	}
+}
+
+// ClassicalDetector is a RoomDetector built on the edge-detection pipeline.
+// It treats detected edges as walls, flood-fills the enclosed free space and
+// reports each sufficiently large enclosed region as a room; walls and
+// doorways come from DetectWalls on the same edges. It needs no
+// network access, which makes it a fallback when Vertex AI is unavailable.
+type ClassicalDetector struct {
+	opts ClassicalDetectorOptions
+}
+
+// NewClassicalDetector returns a detector using the given options
+func NewClassicalDetector(opts ClassicalDetectorOptions) *ClassicalDetector {
+	return &ClassicalDetector{opts: opts}
+}
+
+// DetectRooms decodes the image and returns enclosed regions as UNKNOWN-type
+// rooms, along with the walls and doorways found in it
+func (d *ClassicalDetector) DetectRooms(ctx context.Context, data []byte, mimeType string) (*Detection, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image decode error: %w", err)
+	}
+	edges := ProcessFloorplanForAnalysis(img, d.opts.Edge)
+	walls, doors := DetectWalls(edges, d.opts.Walls)
+	return &Detection{Rooms: detectRoomsInEdges(edges, d.opts), Walls: walls, Doors: doors}, nil
+}
+
+// DetectRoomsClassical finds enclosed regions in a floorplan image.
+// Rooms are returned in reading order (top-to-bottom, left-to-right) with
+// rects in [ymin, xmin, ymax, xmax] 0-1000 coordinates.
+func DetectRoomsClassical(img image.Image, opts ClassicalDetectorOptions) []GeminiRoom {
+	return detectRoomsInEdges(ProcessFloorplanForAnalysis(img, opts.Edge), opts)
+}
+
+// detectRoomsInEdges is DetectRoomsClassical on an already computed edge image.
+func detectRoomsInEdges(edges image.Image, opts ClassicalDetectorOptions) []GeminiRoom {
+	// 1. Wall mask: edges, dilated so that door gaps and anti-aliasing don't leak
+	walls := Dilate(edges, opts.WallDilation)
+
+	// 2. Invert so free space is white and flood-fill its connected regions
+	free := invertGray(walls)
+	regions := GetConnectedComponentBoundingBoxes(free)
+
+	bounds := free.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	imgArea := float64(width * height)
+	minW := int(float64(width) * opts.MinSideRatio)
+	minH := int(float64(height) * opts.MinSideRatio)
+
+	// 3. Keep enclosed regions of plausible room size
+	var rects []image.Rectangle
+	for _, r := range regions {
+		// Regions touching the border are the exterior of the building
+		if r.Min.X <= 0 || r.Min.Y <= 0 || r.Max.X >= width || r.Max.Y >= height {
+			continue
+		}
+
+		area := float64(r.Dx() * r.Dy())
+		if area < imgArea*opts.MinAreaRatio || area > imgArea*opts.MaxAreaRatio {
+			continue
+		}
+		if r.Dx() < minW || r.Dy() < minH {
+			continue
+		}
+
+		// Undo the shrink caused by wall dilation
+		r = r.Inset(-opts.WallDilation).Intersect(bounds)
+		rects = append(rects, r)
+	}
+
+	sort.Slice(rects, func(i, j int) bool {
+		if rects[i].Min.Y != rects[j].Min.Y {
+			return rects[i].Min.Y < rects[j].Min.Y
+		}
+		return rects[i].Min.X < rects[j].Min.X
+	})
+
+	// 4. Convert pixels to Gemini's relative 0-1000 scale
+	scaleY := func(v int) int { return v * 1000 / height }
+	scaleX := func(v int) int { return v * 1000 / width }
+
+	rooms := make([]GeminiRoom, 0, len(rects))
+	for i, r := range rects {
+		rooms = append(rooms, GeminiRoom{
+			Name: fmt.Sprintf("Room %d", i+1),
+			Type: "UNKNOWN",
+			Rect: []int{scaleY(r.Min.Y), scaleX(r.Min.X), scaleY(r.Max.Y), scaleX(r.Max.X)},
+		})
+	}
+	return rooms
+}
+
+// invertGray returns the negative of a grayscale image
+func invertGray(img image.Image) *image.Gray {
+	bounds := img.Bounds()
+	out := image.NewGray(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y
+			out.SetGray(x, y, color.Gray{Y: 255 - v})
+		}
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+// createTwoRoomPlan draws a 400x300 plan with an outer wall and one interior
+// wall at x=200, giving a left and a right room.
+func createTwoRoomPlan() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+
+	black := &image.Uniform{color.Black}
+	walls := []image.Rectangle{
+		image.Rect(20, 20, 380, 26),   // top
+		image.Rect(20, 274, 380, 280), // bottom
+		image.Rect(20, 20, 26, 280),   // left
+		image.Rect(374, 20, 380, 280), // right
+		image.Rect(197, 20, 203, 280), // interior
+	}
+	for _, w := range walls {
+		draw.Draw(img, w, black, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func TestDetectRoomsClassical_TwoRooms(t *testing.T) {
+	opts := DefaultClassicalDetectorOptions()
+	opts.Edge.ResizeMaxWidth = 0
+
+	rooms := DetectRoomsClassical(createTwoRoomPlan(), opts)
+
+	// Wall interiors between the two Canny edges are thin strips and must be filtered out
+	if len(rooms) != 2 {
+		t.Fatalf("expected 2 rooms, got %d: %+v", len(rooms), rooms)
+	}
+
+	// Left room spans roughly x 26..197, y 26..274 of a 400x300 image
+	left := rooms[0].Rect
+	if left[1] > 80 || left[3] < 470 || left[3] > 510 {
+		t.Errorf("left room rect = %v, want xmin<=80 and xmax≈490", left)
+	}
+	if left[0] > 100 || left[2] < 890 {
+		t.Errorf("left room rect = %v, want to span most of the height", left)
+	}
+
+	right := rooms[1].Rect
+	if right[1] < 490 || right[1] > 530 || right[3] < 920 {
+		t.Errorf("right room rect = %v, want xmin≈505 and xmax>=920", right)
+	}
+
+	for i, r := range rooms {
+		if r.Type != "UNKNOWN" || r.Name == "" {
+			t.Errorf("Room[%d] = %+v, want named UNKNOWN room", i, r)
+		}
+		if r.Rect[0] >= r.Rect[2] || r.Rect[1] >= r.Rect[3] {
+			t.Errorf("Room[%d] rect %v is not [ymin, xmin, ymax, xmax]", i, r.Rect)
+		}
+	}
+}
+
+func TestClassicalDetector_DetectRooms(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, createTwoRoomPlan()); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+
+	d := NewClassicalDetector(DefaultClassicalDetectorOptions())
+	detection, err := d.DetectRooms(context.Background(), buf.Bytes(), "image/png")
+	if err != nil {
+		t.Fatalf("DetectRooms() error = %v", err)
+	}
+	if len(detection.Rooms) != 2 || detection.ContentBox != nil {
+		t.Fatalf("expected 2 rooms and no content box, got %+v", detection)
+	}
+
+	if _, err := d.DetectRooms(context.Background(), []byte("not an image"), "image/png"); err == nil {
+		t.Fatal("expected error for undecodable image")
+	}
+}
+
+func TestDetectRoomsClassical_BlankImage(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	if rooms := DetectRoomsClassical(img, DefaultClassicalDetectorOptions()); len(rooms) != 0 {
+		t.Fatalf("expected no rooms in blank image, got %+v", rooms)
+	}
+}
@@ -0,0 +1,61 @@
+package ai
+
+import (
+	"context"
+)
+
+// Detector names used to select a provider at upload time.
+const (
+	DetectorGemini    = "gemini"
+	DetectorClassical = "classical"
+)
+
+// RoomDetector finds rooms in a floorplan image.
+// Implementations return rooms in GeminiRoom format: rect is
+// [ymin, xmin, ymax, xmax] on a relative 0-1000 scale.
+type RoomDetector interface {
+	DetectRooms(ctx context.Context, data []byte, mimeType string) (*Detection, error)
+}
+
+// Detection is what a RoomDetector found in one image.
+type Detection struct {
+	Rooms []GeminiRoom
+	// ContentBox is [ymin, xmin, ymax, xmax] (0-1000) around the floorplan
+	// drawing, excluding margins, title blocks and legends. It is nil when
+	// the detector does not locate the drawing.
+	ContentBox []int
+	Walls      []GeminiWall
+	Doors      []GeminiOpening
+	Windows    []GeminiOpening // Nil when the detector does not look for windows
+	Scale      *GeminiScale    // Nil unless the drawing has a readable scale bar or dimension
+}
+
+// StaticDetector is a RoomDetector that always returns the same rooms.
+// It lets tests and offline demos run the upload flow without Google credentials.
+type StaticDetector struct {
+	Rooms      []GeminiRoom
+	ContentBox []int
+	Walls      []GeminiWall
+	Doors      []GeminiOpening
+	Windows    []GeminiOpening
+	Scale      *GeminiScale
+}
+
+// DetectRooms returns a copy of the configured detection, ignoring the image.
+func (d StaticDetector) DetectRooms(ctx context.Context, data []byte, mimeType string) (*Detection, error) {
+	rooms := make([]GeminiRoom, len(d.Rooms))
+	copy(rooms, d.Rooms)
+	var scale *GeminiScale
+	if d.Scale != nil {
+		s := *d.Scale
+		scale = &s
+	}
+	return &Detection{
+		Rooms:      rooms,
+		ContentBox: append([]int(nil), d.ContentBox...),
+		Walls:      append([]GeminiWall(nil), d.Walls...),
+		Doors:      append([]GeminiOpening(nil), d.Doors...),
+		Windows:    append([]GeminiOpening(nil), d.Windows...),
+		Scale:      scale,
+	}, nil
+}
@@ -8,21 +8,52 @@ import (
 	"google.golang.org/genai"
 )
 
-const (
-	ProjectID = "floorplan-digital-twin"
-	Location  = "global"
-	ModelName = "gemini-3-flash-preview"
-)
+// GeminiOptions selects the Vertex AI project, location and model.
+type GeminiOptions struct {
+	ProjectID string
+	Location  string
+	Model     string
+}
+
+// GeminiDetector is the RoomDetector backed by Gemini on Vertex AI.
+// The Vertex AI client is created lazily on first use and then shared.
+type GeminiDetector struct {
+	opts GeminiOptions
 
-var (
 	clientOnce sync.Once
 	clientInst *genai.Client
 	clientErr  error
-)
+}
+
+// NewGeminiDetector returns a detector for the given project, location and model.
+func NewGeminiDetector(opts GeminiOptions) *GeminiDetector {
+	return &GeminiDetector{opts: opts}
+}
+
+// DetectRooms sends the image to Gemini and parses the (possibly truncated) JSON reply.
+func (d *GeminiDetector) DetectRooms(ctx context.Context, data []byte, mimeType string) (*Detection, error) {
+	jsonResponse, err := d.AnalyzeFloorplan(ctx, data, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := parseGeminiResponse(jsonResponse)
+	if err != nil {
+		return nil, fmt.Errorf("json parse error: %w", err)
+	}
+	return &Detection{
+		Rooms:      parsed.Rooms,
+		ContentBox: parsed.ContentBox,
+		Walls:      parsed.Walls,
+		Doors:      parsed.Doors,
+		Windows:    parsed.Windows,
+		Scale:      parsed.Scale,
+	}, nil
+}
 
 // AnalyzeFloorplan sends image/PDF data to Vertex AI and returns the JSON analysis
-func AnalyzeFloorplan(ctx context.Context, data []byte, mimeType string) (string, error) {
-	client, err := getClient(ctx)
+func (d *GeminiDetector) AnalyzeFloorplan(ctx context.Context, data []byte, mimeType string) (string, error) {
+	client, err := d.getClient(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to create genai client: %w", err)
 	}
@@ -31,7 +62,7 @@ func AnalyzeFloorplan(ctx context.Context, data []byte, mimeType string) (string
 Return machine-parseable JSON only.`
 
 	promptText := `OBJECTIVE:
-Detect all functional rooms/spaces in the floorplan image.
+Detect all functional rooms/spaces, walls, doors and windows in the floorplan image, locate the floorplan drawing on the sheet, and read its scale.
 
 LABEL RULES:
 - For each room, set "name" to the visible room label text when readable.
@@ -46,15 +77,29 @@ COORDINATE RULES:
 - Use integer coordinates only.
 - Use relative scale 0..1000 where [0,0] is top-left and [1000,1000] is bottom-right.
 - Enforce ymin < ymax and xmin < xmax.
+- For rooms that are not rectangles (L-shaped offices, angled corridors), also set "polygon" to the room outline as a list of [y, x] vertices in order, on the same 0..1000 scale; "rect" must still be its bounding box.
+- Omit "polygon" for rectangular rooms.
+- "content_box" is [ymin, xmin, ymax, xmax] around the floorplan drawing itself, excluding page margins, title blocks, legends and notes. It must contain every room.
+
+STRUCTURE RULES:
+- "walls" lists every straight wall segment as {"line": [y1, x1, y2, x2], "thickness": t}: "line" runs along the middle of the wall from end to end, split at corners, junctions and openings.
+- "thickness" is the wall's width across it on the 0..1000 scale of the image height for horizontal walls and of the image width for vertical walls; use at least 1.
+- "doors" and "windows" list each opening as {"line": [y1, x1, y2, x2]} across its clear width, from one side of the opening to the other, along the wall it sits in.
+- Use the same 0..1000 scale for every "line".
+
+SCALE RULES:
+- If the drawing has a scale bar, or a dimension line labeled with its length, set "scale" to {"line": [y1, x1, y2, x2], "meters": m}: "line" runs from one end of the bar or dimension to the other, and "meters" is the labeled length converted to meters (1 ft = 0.3048 m, 1000 mm = 1 m).
+- Prefer the longest clearly labeled dimension. Omit "scale" when no length is readable; never guess it from typical room sizes.
 
 OUTPUT CONTRACT:
-- Return strictly valid JSON with exactly one top-level key: "rooms".
-- "rooms" is an array of objects with exactly: {"name", "type", "rect"}.
+- Return strictly valid JSON with exactly the top-level keys "rooms", "content_box", "walls", "doors" and "windows", plus "scale" when one is readable.
+- Write the keys in the order "rooms", "content_box", "scale", "doors", "windows", "walls", so that rooms come first.
+- "rooms" is an array of objects with exactly: {"name", "type", "rect"} and, for non-rectangular rooms, "polygon".
 - Do not include markdown, prose, code fences, comments, or extra keys.
-- If no valid rooms are detectable, return {"rooms":[]}.
+- If no valid rooms are detectable, return {"rooms":[],"content_box":[0,0,1000,1000],"walls":[],"doors":[],"windows":[]}.
 
 EXAMPLE OUTPUT:
-{"rooms":[{"name":"Office 101","type":"OFFICE","rect":[120,80,280,300]},{"name":"Unlabeled corridor","type":"UNKNOWN","rect":[300,40,420,960]}]}`
+{"rooms":[{"name":"Office 101","type":"OFFICE","rect":[120,80,280,300]},{"name":"Office 102","type":"OFFICE","rect":[120,320,400,560],"polygon":[[120,320],[120,560],[400,560],[400,440],[280,440],[280,320]]},{"name":"Unlabeled corridor","type":"UNKNOWN","rect":[300,40,420,960]}],"content_box":[60,30,900,980],"scale":{"line":[940,80,940,560],"meters":12},"doors":[{"line":[200,300,250,300]}],"windows":[{"line":[120,150,120,230]}],"walls":[{"line":[120,80,120,560],"thickness":8},{"line":[120,300,280,300],"thickness":6}]}`
 
 	// Create data part based on mimeType
 	dataPart := genai.NewPartFromBytes(data, mimeType)
@@ -69,7 +114,7 @@ EXAMPLE OUTPUT:
 		},
 	}
 
-	resp, err := client.Models.GenerateContent(ctx, ModelName, contents, &genai.GenerateContentConfig{
+	resp, err := client.Models.GenerateContent(ctx, d.opts.Model, contents, &genai.GenerateContentConfig{
 		SystemInstruction: &genai.Content{
 			Role: "system",
 			Parts: []*genai.Part{
@@ -104,7 +149,37 @@ EXAMPLE OUTPUT:
 		ResponseSchema: &genai.Schema{
 			Type:     genai.TypeObject,
 			Required: []string{"rooms"},
+			// Rooms first and the numerous walls last: a reply cut off at
+			// MaxOutputTokens then loses structure rather than rooms.
+			PropertyOrdering: []string{"rooms", "content_box", "scale", "doors", "windows", "walls"},
 			Properties: map[string]*genai.Schema{
+				"content_box": {
+					Type:     genai.TypeArray,
+					MinItems: int64Ptr(4),
+					MaxItems: int64Ptr(4),
+					Items:    &genai.Schema{Type: genai.TypeInteger},
+				},
+				"walls": {
+					Type: genai.TypeArray,
+					Items: &genai.Schema{
+						Type:     genai.TypeObject,
+						Required: []string{"line", "thickness"},
+						Properties: map[string]*genai.Schema{
+							"line":      lineSchema(),
+							"thickness": {Type: genai.TypeInteger},
+						},
+					},
+				},
+				"doors":   openingsSchema(),
+				"windows": openingsSchema(),
+				"scale": {
+					Type:     genai.TypeObject,
+					Required: []string{"line", "meters"},
+					Properties: map[string]*genai.Schema{
+						"line":   lineSchema(),
+						"meters": {Type: genai.TypeNumber},
+					},
+				},
 				"rooms": {
 					Type: genai.TypeArray,
 					Items: &genai.Schema{
@@ -122,6 +197,16 @@ EXAMPLE OUTPUT:
 								MaxItems: int64Ptr(4),
 								Items:    &genai.Schema{Type: genai.TypeInteger},
 							},
+							"polygon": {
+								Type:     genai.TypeArray,
+								MinItems: int64Ptr(3),
+								Items: &genai.Schema{
+									Type:     genai.TypeArray,
+									MinItems: int64Ptr(2),
+									MaxItems: int64Ptr(2),
+									Items:    &genai.Schema{Type: genai.TypeInteger},
+								},
+							},
 						},
 					},
 				},
@@ -151,6 +236,28 @@ EXAMPLE OUTPUT:
 	return "", fmt.Errorf("unexpected response format")
 }
 
+// lineSchema is a [y1, x1, y2, x2] segment.
+func lineSchema() *genai.Schema {
+	return &genai.Schema{
+		Type:     genai.TypeArray,
+		MinItems: int64Ptr(4),
+		MaxItems: int64Ptr(4),
+		Items:    &genai.Schema{Type: genai.TypeInteger},
+	}
+}
+
+// openingsSchema is a list of doors or windows, each a line across the opening.
+func openingsSchema() *genai.Schema {
+	return &genai.Schema{
+		Type: genai.TypeArray,
+		Items: &genai.Schema{
+			Type:       genai.TypeObject,
+			Required:   []string{"line"},
+			Properties: map[string]*genai.Schema{"line": lineSchema()},
+		},
+	}
+}
+
 func float32Ptr(value float32) *float32 {
 	return &value
 }
@@ -159,15 +266,15 @@ func int64Ptr(value int64) *int64 {
 	return &value
 }
 
-// getClient creates a new Vertex AI client (shared helper)
-func getClient(ctx context.Context) (*genai.Client, error) {
-	clientOnce.Do(func() {
-		clientInst, clientErr = genai.NewClient(ctx, &genai.ClientConfig{
-			Project:  ProjectID,
-			Location: Location,
+// getClient creates the Vertex AI client on first use
+func (d *GeminiDetector) getClient(ctx context.Context) (*genai.Client, error) {
+	d.clientOnce.Do(func() {
+		d.clientInst, d.clientErr = genai.NewClient(ctx, &genai.ClientConfig{
+			Project:  d.opts.ProjectID,
+			Location: d.opts.Location,
 			Backend:  genai.BackendVertexAI,
 		})
 	})
 
-	return clientInst, clientErr
+	return d.clientInst, d.clientErr
 }
@@ -0,0 +1,155 @@
+package ai
+
+import "testing"
+
+func TestParseGeminiResponse_CleanJSON(t *testing.T) {
+	input := "```json\n{\"rooms\":[{\"name\":\"Office 101\",\"type\":\"OFFICE\",\"rect\":[10,20,100,200]}]}\n```"
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+
+	if len(got.Rooms) != 1 {
+		t.Fatalf("expected 1 room, got %d", len(got.Rooms))
+	}
+
+	if got.Rooms[0].Name != "Office 101" {
+		t.Fatalf("expected room name Office 101, got %q", got.Rooms[0].Name)
+	}
+}
+
+func TestParseGeminiResponse_ExtractFirstObject(t *testing.T) {
+	input := "Result:\n{\"rooms\":[]}\nThanks"
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+
+	if len(got.Rooms) != 0 {
+		t.Fatalf("expected empty rooms, got %d", len(got.Rooms))
+	}
+}
+
+func TestParseGeminiResponse_TruncatedObjectRecovered(t *testing.T) {
+	input := "{\"rooms\":[{\"name\":\"Office 7\",\"type\":\"OFFICE\",\"rect\":[10,20,50,70]}]"
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+
+	if len(got.Rooms) != 1 {
+		t.Fatalf("expected 1 room, got %d", len(got.Rooms))
+	}
+
+	if got.Rooms[0].Name != "Office 7" {
+		t.Fatalf("expected room name Office 7, got %q", got.Rooms[0].Name)
+	}
+}
+
+func TestParseGeminiResponse_TruncatedMidString(t *testing.T) {
+	// Second room is truncated mid-name; only first room should be recovered.
+	input := `{"rooms":[{"name":"Office 1","type":"OFFICE","rect":[10,20,50,70]},{"name":"Off`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if len(got.Rooms) < 1 {
+		t.Fatalf("expected at least 1 room, got %d", len(got.Rooms))
+	}
+	if got.Rooms[0].Name != "Office 1" {
+		t.Fatalf("expected room name Office 1, got %q", got.Rooms[0].Name)
+	}
+}
+
+func TestParseGeminiResponse_TruncatedMidRect(t *testing.T) {
+	// Second room's rect is incomplete; only first room should survive.
+	input := `{"rooms":[{"name":"Office 1","type":"OFFICE","rect":[10,20,50,70]},{"name":"Office 2","type":"MEETING","rect":[80,`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if len(got.Rooms) < 1 {
+		t.Fatalf("expected at least 1 room, got %d", len(got.Rooms))
+	}
+	if got.Rooms[0].Name != "Office 1" {
+		t.Fatalf("expected room name Office 1, got %q", got.Rooms[0].Name)
+	}
+}
+
+func TestParseGeminiResponse_Invalid(t *testing.T) {
+	input := "no json here"
+
+	_, err := parseGeminiResponse(input)
+	if err == nil {
+		t.Fatalf("expected error for invalid input")
+	}
+}
+
+func TestParseGeminiResponse_Polygon(t *testing.T) {
+	input := `{"rooms":[{"name":"Office L","type":"OFFICE","rect":[100,100,300,300],"polygon":[[100,100],[100,300],[300,300],[300,200],[200,200],[200,100]]}]}`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if len(got.Rooms) != 1 || len(got.Rooms[0].Polygon) != 6 || got.Rooms[0].Polygon[3][1] != 200 {
+		t.Fatalf("expected the room with its 6-point polygon, got %+v", got.Rooms)
+	}
+}
+
+func TestParseGeminiResponse_ContentBox(t *testing.T) {
+	input := `{"content_box":[60,30,900,980],"rooms":[{"name":"Office 101","type":"OFFICE","rect":[100,100,200,200]}]}`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if len(got.ContentBox) != 4 || got.ContentBox[0] != 60 || got.ContentBox[3] != 980 || len(got.Rooms) != 1 {
+		t.Fatalf("expected the content box and one room, got %+v", got)
+	}
+}
+
+func TestParseGeminiResponse_Structure(t *testing.T) {
+	input := `{"rooms":[],"walls":[{"line":[120,80,120,560],"thickness":8}],"doors":[{"line":[200,300,250,300]}],"windows":[{"line":[120,150,120,230]},{"line":[900,150,900,230]}]}`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if len(got.Walls) != 1 || got.Walls[0].Thickness != 8 || len(got.Walls[0].Line) != 4 {
+		t.Fatalf("walls = %+v", got.Walls)
+	}
+	if len(got.Doors) != 1 || got.Doors[0].Line[2] != 250 || len(got.Windows) != 2 {
+		t.Fatalf("doors = %+v, windows = %+v", got.Doors, got.Windows)
+	}
+}
+
+func TestParseGeminiResponse_TruncatedInWalls(t *testing.T) {
+	// Rooms come first, so a reply cut off in the walls keeps every room.
+	input := `{"rooms":[{"name":"A","type":"OFFICE","rect":[0,0,10,10]},{"name":"B","type":"MEETING","rect":[10,10,20,20]}],"content_box":[0,0,1000,1000],"doors":[{"line":[5,10,8,10]}],"walls":[{"line":[0,0,0,10],"thickness":2},{"line":[0,10,`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if len(got.Rooms) != 2 || got.Rooms[1].Name != "B" || len(got.Doors) != 1 || len(got.ContentBox) != 4 {
+		t.Fatalf("got %+v", got)
+	}
+}
+
+func TestParseGeminiResponse_Scale(t *testing.T) {
+	input := `{"rooms":[],"scale":{"line":[940,80,940,560],"meters":12.5}}`
+
+	got, err := parseGeminiResponse(input)
+	if err != nil {
+		t.Fatalf("parseGeminiResponse() error = %v", err)
+	}
+	if got.Scale == nil || len(got.Scale.Line) != 4 || got.Scale.Meters != 12.5 {
+		t.Fatalf("scale = %+v", got.Scale)
+	}
+}
@@ -0,0 +1,250 @@
+package ai
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// WallDetectionOptions configures the Hough-style wall extractor.
+// Lengths are in pixels of the edge image.
+type WallDetectionOptions struct {
+	MinLength    int // Shortest wall segment (default: 20)
+	MaxGap       int // Longest break in an edge line still treated as one segment (default: 3)
+	MaxThickness int // Widest wall, measured between its two edge lines (default: 15)
+	MinDoorWidth int // Narrowest gap between collinear walls reported as a door (default: 12)
+	MaxDoorWidth int // Widest gap between collinear walls reported as a door (default: 80)
+}
+
+// DefaultWallDetectionOptions returns sensible defaults for edge images of
+// up to about 800 pixels wide
+func DefaultWallDetectionOptions() WallDetectionOptions {
+	return WallDetectionOptions{
+		MinLength:    20,
+		MaxGap:       3,
+		MaxThickness: 15,
+		MinDoorWidth: 12,
+		MaxDoorWidth: 80,
+	}
+}
+
+// houghThetaSteps is the angular resolution of the accumulator: one-degree
+// steps over [0, 180).
+const houghThetaSteps = 180
+
+// segment is a straight run along the line x*cos(theta) + y*sin(theta) = rho,
+// from t0 to t1 in the direction (-sin(theta), cos(theta)).
+type segment struct {
+	theta     int // Accumulator angle step
+	rho       float64
+	t0, t1    float64
+	thickness float64 // Distance between the outermost merged edge lines
+}
+
+func (s segment) length() float64 {
+	return s.t1 - s.t0
+}
+
+// point returns the pixel at position t along s.
+func (s segment) point(t float64) (x, y float64) {
+	sin, cos := math.Sincos(float64(s.theta) * math.Pi / houghThetaSteps)
+	return s.rho*cos - t*sin, s.rho*sin + t*cos
+}
+
+// horizontal reports whether s is closer to horizontal than to vertical,
+// i.e. whether its thickness is measured along the y axis.
+func (s segment) horizontal() bool {
+	sin, cos := math.Sincos(float64(s.theta) * math.Pi / houghThetaSteps)
+	return math.Abs(sin) >= math.Abs(cos)
+}
+
+// parallel reports whether a and b lie within one accumulator step of each other.
+func parallel(a, b segment) bool {
+	d := a.theta - b.theta
+	return d >= -1 && d <= 1
+}
+
+// DetectWalls extracts straight walls and doorways from an edge image such as
+// the output of DetectEdgesCanny.
+//
+// Edge pixels vote in a Hough accumulator; the strongest lines are walked over
+// the pixels not yet claimed by a stronger one and split into segments at
+// breaks longer than MaxGap. Parallel, overlapping segments at most
+// MaxThickness apart (the two faces of one wall) merge into a wall along their
+// center line, and a gap of MinDoorWidth to MaxDoorWidth between two collinear
+// walls is reported as a door. Results use GeminiWall and GeminiOpening
+// coordinates ([y1, x1, y2, x2], 0-1000).
+func DetectWalls(edges image.Image, opts WallDetectionOptions) ([]GeminiWall, []GeminiOpening) {
+	bounds := edges.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, nil
+	}
+
+	// 1. Collect edge pixels
+	var points [][2]float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if color.GrayModel.Convert(edges.At(x, y)).(color.Gray).Y > 127 {
+				points = append(points, [2]float64{float64(x - bounds.Min.X), float64(y - bounds.Min.Y)})
+			}
+		}
+	}
+
+	// 2. Every edge pixel votes for each line through it
+	var sin, cos [houghThetaSteps]float64
+	for i := range sin {
+		sin[i], cos[i] = math.Sincos(float64(i) * math.Pi / houghThetaSteps)
+	}
+	diag := int(math.Ceil(math.Hypot(float64(width), float64(height))))
+	stride := 2*diag + 1
+	acc := make([]int, houghThetaSteps*stride)
+	for _, p := range points {
+		for theta := 0; theta < houghThetaSteps; theta++ {
+			acc[theta*stride+int(math.Round(p[0]*cos[theta]+p[1]*sin[theta]))+diag]++
+		}
+	}
+
+	// 3. Local maxima with enough votes for a MinLength segment, strongest first
+	type peak struct{ theta, rho, votes int }
+	var peaks []peak
+	for theta := 0; theta < houghThetaSteps; theta++ {
+		for r := 0; r < stride; r++ {
+			votes := acc[theta*stride+r]
+			if votes < opts.MinLength || !localMax(acc, stride, theta, r) {
+				continue
+			}
+			peaks = append(peaks, peak{theta: theta, rho: r - diag, votes: votes})
+		}
+	}
+	sort.Slice(peaks, func(i, j int) bool {
+		if peaks[i].votes != peaks[j].votes {
+			return peaks[i].votes > peaks[j].votes
+		}
+		if peaks[i].theta != peaks[j].theta {
+			return peaks[i].theta < peaks[j].theta
+		}
+		return peaks[i].rho < peaks[j].rho
+	})
+
+	// 4. Walk each peak's line over unclaimed pixels, splitting it at gaps
+	used := make([]bool, len(points))
+	var segments []segment
+	for _, pk := range peaks {
+		c, s := cos[pk.theta], sin[pk.theta]
+		rho := float64(pk.rho)
+		var on []int
+		for i, p := range points {
+			if !used[i] && math.Abs(p[0]*c+p[1]*s-rho) <= 1 {
+				on = append(on, i)
+			}
+		}
+		if len(on) < opts.MinLength {
+			continue
+		}
+		along := func(i int) float64 { return -points[i][0]*s + points[i][1]*c }
+		sort.Slice(on, func(i, j int) bool { return along(on[i]) < along(on[j]) })
+
+		start := 0
+		for j := 1; j <= len(on); j++ {
+			if j < len(on) && along(on[j])-along(on[j-1]) <= float64(opts.MaxGap+1) {
+				continue
+			}
+			run := on[start:j]
+			start = j
+			t0, t1 := along(run[0]), along(run[len(run)-1])
+			if t1-t0+1 < float64(opts.MinLength) {
+				continue
+			}
+			var sum float64
+			for _, i := range run {
+				used[i] = true
+				sum += points[i][0]*c + points[i][1]*s
+			}
+			segments = append(segments, segment{theta: pk.theta, rho: sum / float64(len(run)), t0: t0, t1: t1})
+		}
+	}
+
+	// 5. Merge the faces of each wall, longest first
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].length() > segments[j].length() })
+	merged := make([]bool, len(segments))
+	var walls []segment
+	for i, s := range segments {
+		if merged[i] {
+			continue
+		}
+		lo, hi, t0, t1 := s.rho, s.rho, s.t0, s.t1
+		for j := i + 1; j < len(segments); j++ {
+			o := segments[j]
+			if merged[j] || !parallel(s, o) || o.t1 < s.t0 || o.t0 > s.t1 ||
+				max(hi, o.rho)-min(lo, o.rho) > float64(opts.MaxThickness) {
+				continue
+			}
+			merged[j] = true
+			lo, hi = min(lo, o.rho), max(hi, o.rho)
+			t0, t1 = min(t0, o.t0), max(t1, o.t1)
+		}
+		walls = append(walls, segment{theta: s.theta, rho: (lo + hi) / 2, t0: t0, t1: t1, thickness: max(hi-lo, 1)})
+	}
+
+	// 6. A door-sized gap to the next collinear wall is a doorway
+	var doors []segment
+	for i, a := range walls {
+		next, gap := -1, 0.0
+		for j, b := range walls {
+			if i == j || !parallel(a, b) || math.Abs(a.rho-b.rho) > max(a.thickness, b.thickness) || b.t0 <= a.t1 {
+				continue
+			}
+			if next == -1 || b.t0-a.t1 < gap {
+				next, gap = j, b.t0-a.t1
+			}
+		}
+		if next != -1 && gap >= float64(opts.MinDoorWidth) && gap <= float64(opts.MaxDoorWidth) {
+			doors = append(doors, segment{theta: a.theta, rho: (a.rho + walls[next].rho) / 2, t0: a.t1, t1: walls[next].t0})
+		}
+	}
+
+	// 7. Convert pixels to Gemini's relative 0-1000 scale
+	scale := func(v float64, size int) int { return min(max(int(math.Round(v*1000/float64(size))), 0), 1000) }
+	line := func(s segment) []int {
+		x0, y0 := s.point(s.t0)
+		x1, y1 := s.point(s.t1)
+		if x1 < x0 || (x1 == x0 && y1 < y0) {
+			x0, y0, x1, y1 = x1, y1, x0, y0
+		}
+		return []int{scale(y0, height), scale(x0, width), scale(y1, height), scale(x1, width)}
+	}
+
+	geminiWalls := make([]GeminiWall, 0, len(walls))
+	for _, w := range walls {
+		across := width
+		if w.horizontal() {
+			across = height
+		}
+		geminiWalls = append(geminiWalls, GeminiWall{Line: line(w), Thickness: max(scale(w.thickness, across), 1)})
+	}
+	openings := make([]GeminiOpening, 0, len(doors))
+	for _, d := range doors {
+		openings = append(openings, GeminiOpening{Line: line(d)})
+	}
+	return geminiWalls, openings
+}
+
+// localMax reports whether the accumulator cell (theta, r) is at least as
+// large as its eight neighbors.
+func localMax(acc []int, stride, theta, r int) bool {
+	v := acc[theta*stride+r]
+	for dt := -1; dt <= 1; dt++ {
+		for dr := -1; dr <= 1; dr++ {
+			t, rr := theta+dt, r+dr
+			if (dt == 0 && dr == 0) || t < 0 || t >= houghThetaSteps || rr < 0 || rr >= stride {
+				continue
+			}
+			if acc[t*stride+rr] > v {
+				return false
+			}
+		}
+	}
+	return true
+}
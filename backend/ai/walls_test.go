@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+// createDoorwayPlan is createTwoRoomPlan with a 40 pixel doorway in the
+// interior wall, from y=120 to y=160.
+func createDoorwayPlan() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(img, img.Bounds(), &image.Uniform{color.White}, image.Point{}, draw.Src)
+
+	black := &image.Uniform{color.Black}
+	walls := []image.Rectangle{
+		image.Rect(20, 20, 380, 26),   // top
+		image.Rect(20, 274, 380, 280), // bottom
+		image.Rect(20, 20, 26, 280),   // left
+		image.Rect(374, 20, 380, 280), // right
+		image.Rect(197, 20, 203, 120), // interior, above the door
+		image.Rect(197, 160, 203, 280),
+	}
+	for _, w := range walls {
+		draw.Draw(img, w, black, image.Point{}, draw.Src)
+	}
+	return img
+}
+
+func near(got, want, tolerance int) bool {
+	return got >= want-tolerance && got <= want+tolerance
+}
+
+func TestDetectWalls_Doorway(t *testing.T) {
+	edgeOpts := DefaultEdgeDetectionOptions()
+	edgeOpts.ResizeMaxWidth = 0
+	walls, doors := DetectWalls(ProcessFloorplanForAnalysis(createDoorwayPlan(), edgeOpts), DefaultWallDetectionOptions())
+
+	if len(walls) != 6 {
+		t.Fatalf("expected 4 outer walls and 2 interior pieces, got %d: %+v", len(walls), walls)
+	}
+	var top *GeminiWall
+	for i, w := range walls {
+		if w.Line[0] < 100 && w.Line[2] < 100 {
+			top = &walls[i]
+		}
+	}
+	// 23/300 down, from x=20 to x=380; 6 of 300 pixels thick.
+	if top == nil || !near(top.Line[0], 77, 5) || !near(top.Line[1], 50, 10) || !near(top.Line[3], 950, 10) || !near(top.Thickness, 20, 4) {
+		t.Fatalf("top wall = %+v", top)
+	}
+
+	if len(doors) != 1 {
+		t.Fatalf("expected one doorway, got %+v", doors)
+	}
+	// x=200 of 400, y=120..160 of 300.
+	if d := doors[0].Line; !near(d[0], 400, 10) || !near(d[1], 500, 5) || !near(d[2], 533, 10) || !near(d[3], 500, 5) {
+		t.Fatalf("doorway = %v", d)
+	}
+}
+
+func TestDetectWalls_BlankImage(t *testing.T) {
+	walls, doors := DetectWalls(image.NewGray(image.Rect(0, 0, 200, 100)), DefaultWallDetectionOptions())
+	if len(walls) != 0 || len(doors) != 0 {
+		t.Fatalf("expected nothing on a blank image, got %+v %+v", walls, doors)
+	}
+}
+
+func TestClassicalDetector_DetectsWalls(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, createDoorwayPlan()); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+
+	opts := DefaultClassicalDetectorOptions()
+	opts.Edge.ResizeMaxWidth = 0
+	detection, err := NewClassicalDetector(opts).DetectRooms(context.Background(), buf.Bytes(), "image/png")
+	if err != nil {
+		t.Fatalf("DetectRooms() error = %v", err)
+	}
+	if len(detection.Walls) != 6 || len(detection.Doors) != 1 || detection.Windows != nil {
+		t.Fatalf("detection = %+v", detection)
+	}
+}
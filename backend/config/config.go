@@ -0,0 +1,415 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"floorplan-whiteboard/ai"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// EnvPrefix prefixes every environment variable that overrides a config value.
+const EnvPrefix = "SPACETWIN_"
+
+// Config is the full backend configuration.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Store     StoreConfig     `yaml:"store"`
+	Gemini    GeminiConfig    `yaml:"gemini"`
+	Detection DetectionConfig `yaml:"detection"`
+	Realtime  RealtimeConfig  `yaml:"realtime"`
+	Jobs      JobsConfig      `yaml:"jobs"`
+	PDF       PDFConfig       `yaml:"pdf"`
+	MQTT      MQTTConfig      `yaml:"mqtt"`
+	Export    ExportConfig    `yaml:"export"`
+}
+
+// ServerConfig configures the HTTP listener.
+type ServerConfig struct {
+	Addr           string   `yaml:"addr"`
+	AllowedOrigins []string `yaml:"allowed_origins"` // "*" allows any origin
+}
+
+// StoreConfig configures floorplan persistence.
+type StoreConfig struct {
+	Path string `yaml:"path"` // BoltDB file path
+}
+
+// GeminiConfig selects the Vertex AI project and model used for room detection.
+// Its fields mirror ai.GeminiOptions, which it converts to.
+type GeminiConfig struct {
+	ProjectID string `yaml:"project_id"`
+	Location  string `yaml:"location"`
+	Model     string `yaml:"model"`
+}
+
+// DetectionConfig configures room detection and image preprocessing.
+type DetectionConfig struct {
+	DefaultDetector string              `yaml:"default_detector"` // gemini or classical
+	Edge            EdgeDetectionConfig `yaml:"edge"`
+}
+
+// EdgeDetectionConfig holds the default edge-detection parameters. Its fields
+// mirror ai.EdgeDetectionOptions, which it converts to.
+type EdgeDetectionConfig struct {
+	BlurRadius     float64 `yaml:"blur_radius"`
+	CannyLow       float64 `yaml:"canny_low"`
+	CannyHigh      float64 `yaml:"canny_high"`
+	ResizeMaxWidth int     `yaml:"resize_max_width"` // 0 = no resize
+}
+
+// Slow websocket client policies: what the hub does when a client's send queue is full.
+const (
+	SlowClientDisconnect = "disconnect" // Close the connection; the client reconnects and resyncs
+	SlowClientDrop       = "drop"       // Discard the message and keep the connection
+)
+
+// RealtimeConfig configures the websocket hub.
+type RealtimeConfig struct {
+	Simulate           bool          `yaml:"simulate"` // Broadcast random room updates (development only)
+	SimulationInterval time.Duration `yaml:"simulation_interval"`
+	SendQueueSize      int           `yaml:"send_queue_size"` // Messages buffered per client
+	WriteTimeout       time.Duration `yaml:"write_timeout"`   // Deadline for each websocket write
+	PingInterval       time.Duration `yaml:"ping_interval"`
+	PongTimeout        time.Duration `yaml:"pong_timeout"`       // Clients silent for longer are disconnected
+	SlowClientPolicy   string        `yaml:"slow_client_policy"` // disconnect or drop
+	ReplayLogSize      int           `yaml:"replay_log_size"`    // Status updates kept for resuming clients
+	Broker             string        `yaml:"broker"`             // memory, or redis to share updates between replicas
+	Redis              RedisConfig   `yaml:"redis"`
+}
+
+// Realtime brokers: how hubs exchange messages.
+const (
+	BrokerMemory = "memory" // In process; a single replica
+	BrokerRedis  = "redis"  // Redis pub/sub; every replica sharing the channel sees every message
+)
+
+// RedisConfig configures the Redis realtime broker.
+type RedisConfig struct {
+	Addr     string `yaml:"addr"`
+	Password string `yaml:"password"`
+	DB       int    `yaml:"db"`
+	Channel  string `yaml:"channel"` // Pub/sub channel; the sequence counter is stored at <channel>:seq
+}
+
+// JobsConfig configures the asynchronous analysis worker pool.
+type JobsConfig struct {
+	Workers   int           `yaml:"workers"`
+	QueueSize int           `yaml:"queue_size"` // Pending jobs accepted before POST /jobs returns 503
+	Retain    int           `yaml:"retain"`     // Finished jobs kept in memory for polling
+	Timeout   time.Duration `yaml:"timeout"`    // Per-job deadline
+}
+
+// PDFConfig configures server-side rasterization of uploaded PDFs.
+type PDFConfig struct {
+	DPI           float64 `yaml:"dpi"`
+	MaxPages      int     `yaml:"max_pages"`       // Larger documents are rejected
+	MaxPagePixels int     `yaml:"max_page_pixels"` // Pages larger at the configured DPI are rejected
+}
+
+// MQTTConfig configures the bridge that ingests room status from sensors over MQTT.
+type MQTTConfig struct {
+	Enabled  bool              `yaml:"enabled"`
+	Broker   string            `yaml:"broker"` // e.g. tcp://localhost:1883
+	ClientID string            `yaml:"client_id"`
+	Username string            `yaml:"username"`
+	Password string            `yaml:"password"`
+	QoS      int               `yaml:"qos"`
+	Topics   []MQTTTopicConfig `yaml:"topics"`
+}
+
+// MQTTTopicConfig maps an MQTT topic to a room. Topic levels written as
+// {floorplan_id} or {room_id} match any single level and supply that ID;
+// otherwise the fixed FloorplanID/RoomID is used.
+type MQTTTopicConfig struct {
+	Topic       string `yaml:"topic"`
+	FloorplanID string `yaml:"floorplan_id"`
+	RoomID      string `yaml:"room_id"`
+}
+
+// ExportConfig configures floorplan exports in physical units (SVG, DXF).
+type ExportConfig struct {
+	PixelsPerMeter float64 `yaml:"pixels_per_meter"` // Scale of uncalibrated cropped floorplan images; requests may override it
+}
+
+// Default returns the configuration used when no file or overrides are given.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:           ":8080",
+			AllowedOrigins: []string{"*"},
+		},
+		Store: StoreConfig{
+			Path: "floorplans.db",
+		},
+		Gemini: GeminiConfig{
+			ProjectID: "floorplan-digital-twin",
+			Location:  "global",
+			Model:     "gemini-3-flash-preview",
+		},
+		Detection: DetectionConfig{
+			DefaultDetector: "gemini",
+			Edge:            EdgeDetectionConfig(ai.DefaultEdgeDetectionOptions()),
+		},
+		Realtime: RealtimeConfig{
+			SimulationInterval: 2 * time.Second,
+			SendQueueSize:      64,
+			WriteTimeout:       10 * time.Second,
+			PingInterval:       30 * time.Second,
+			PongTimeout:        60 * time.Second,
+			SlowClientPolicy:   SlowClientDisconnect,
+			ReplayLogSize:      1024,
+			Broker:             BrokerMemory,
+			Redis: RedisConfig{
+				Addr:    "localhost:6379",
+				Channel: "spacetwin:realtime",
+			},
+		},
+		Jobs: JobsConfig{
+			Workers:   2,
+			QueueSize: 32,
+			Retain:    500,
+			Timeout:   10 * time.Minute,
+		},
+		PDF: PDFConfig{
+			DPI:           150,
+			MaxPages:      20,
+			MaxPagePixels: 40_000_000,
+		},
+		MQTT: MQTTConfig{
+			Broker:   "tcp://localhost:1883",
+			ClientID: "spacetwin-backend",
+			QoS:      1,
+			Topics: []MQTTTopicConfig{
+				{Topic: "spacetwin/{floorplan_id}/{room_id}/status"},
+			},
+		},
+		Export: ExportConfig{
+			PixelsPerMeter: 50,
+		},
+	}
+}
+
+// Load builds the configuration from defaults, the optional YAML file at path
+// and SPACETWIN_* environment variables (in increasing precedence), then
+// validates the result.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		// Unknown keys are errors, so a misspelled setting does not silently keep its default.
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := cfg.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate reports every invalid setting in one error.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr must not be empty")
+	check(len(c.Server.AllowedOrigins) > 0, "server.allowed_origins must list at least one origin")
+	check(c.Store.Path != "", "store.path must not be empty")
+	check(c.Gemini.ProjectID != "", "gemini.project_id must not be empty")
+	check(c.Gemini.Location != "", "gemini.location must not be empty")
+	check(c.Gemini.Model != "", "gemini.model must not be empty")
+	check(c.Detection.DefaultDetector != "", "detection.default_detector must not be empty")
+
+	edge := c.Detection.Edge
+	check(edge.BlurRadius > 0, "detection.edge.blur_radius must be positive, got %v", edge.BlurRadius)
+	check(edge.CannyLow >= 0, "detection.edge.canny_low must not be negative, got %v", edge.CannyLow)
+	check(edge.CannyHigh >= edge.CannyLow, "detection.edge.canny_high (%v) must be >= canny_low (%v)", edge.CannyHigh, edge.CannyLow)
+	check(edge.ResizeMaxWidth >= 0, "detection.edge.resize_max_width must not be negative, got %d", edge.ResizeMaxWidth)
+
+	rt := c.Realtime
+	check(rt.SimulationInterval > 0, "realtime.simulation_interval must be positive, got %v", rt.SimulationInterval)
+	check(rt.SendQueueSize > 0, "realtime.send_queue_size must be positive, got %d", rt.SendQueueSize)
+	check(rt.WriteTimeout > 0, "realtime.write_timeout must be positive, got %v", rt.WriteTimeout)
+	check(rt.PingInterval > 0, "realtime.ping_interval must be positive, got %v", rt.PingInterval)
+	check(rt.PongTimeout > rt.PingInterval, "realtime.pong_timeout (%v) must be longer than ping_interval (%v)", rt.PongTimeout, rt.PingInterval)
+	check(rt.SlowClientPolicy == SlowClientDisconnect || rt.SlowClientPolicy == SlowClientDrop,
+		"realtime.slow_client_policy must be %s or %s, got %q", SlowClientDisconnect, SlowClientDrop, rt.SlowClientPolicy)
+	check(rt.ReplayLogSize > 0, "realtime.replay_log_size must be positive, got %d", rt.ReplayLogSize)
+	check(rt.Broker == BrokerMemory || rt.Broker == BrokerRedis,
+		"realtime.broker must be %s or %s, got %q", BrokerMemory, BrokerRedis, rt.Broker)
+	if rt.Broker == BrokerRedis {
+		check(rt.Redis.Addr != "", "realtime.redis.addr must not be empty")
+		check(rt.Redis.Channel != "", "realtime.redis.channel must not be empty")
+	}
+
+	check(c.Jobs.Workers > 0, "jobs.workers must be positive, got %d", c.Jobs.Workers)
+	check(c.Jobs.QueueSize > 0, "jobs.queue_size must be positive, got %d", c.Jobs.QueueSize)
+	check(c.Jobs.Retain > 0, "jobs.retain must be positive, got %d", c.Jobs.Retain)
+	check(c.Jobs.Timeout > 0, "jobs.timeout must be positive, got %v", c.Jobs.Timeout)
+
+	check(c.PDF.DPI >= 36 && c.PDF.DPI <= 600, "pdf.dpi must be between 36 and 600, got %v", c.PDF.DPI)
+	check(c.PDF.MaxPages > 0, "pdf.max_pages must be positive, got %d", c.PDF.MaxPages)
+	check(c.PDF.MaxPagePixels > 0, "pdf.max_page_pixels must be positive, got %d", c.PDF.MaxPagePixels)
+
+	if c.MQTT.Enabled {
+		check(c.MQTT.Broker != "", "mqtt.broker must not be empty")
+		check(c.MQTT.ClientID != "", "mqtt.client_id must not be empty")
+		check(c.MQTT.QoS >= 0 && c.MQTT.QoS <= 2, "mqtt.qos must be 0, 1 or 2, got %d", c.MQTT.QoS)
+		check(len(c.MQTT.Topics) > 0, "mqtt.topics must list at least one topic")
+	}
+
+	check(c.Export.PixelsPerMeter > 0, "export.pixels_per_meter must be positive, got %v", c.Export.PixelsPerMeter)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// applyEnv overrides fields from environment variables looked up with lookup.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs []error
+
+	boolean := func(name string, dst *bool) {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+				return
+			}
+			*dst = b
+		}
+	}
+	str := func(name string, dst *string) {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			*dst = v
+		}
+	}
+	list := func(name string, dst *[]string) {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			var items []string
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			*dst = items
+		}
+	}
+	float := func(name string, dst *float64) {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+				return
+			}
+			*dst = f
+		}
+	}
+	integer := func(name string, dst *int) {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+				return
+			}
+			*dst = i
+		}
+	}
+	duration := func(name string, dst *time.Duration) {
+		if v, ok := lookup(EnvPrefix + name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: %w", EnvPrefix, name, err))
+				return
+			}
+			*dst = d
+		}
+	}
+
+	str("ADDR", &c.Server.Addr)
+	list("ALLOWED_ORIGINS", &c.Server.AllowedOrigins)
+	str("STORE_PATH", &c.Store.Path)
+	str("GEMINI_PROJECT_ID", &c.Gemini.ProjectID)
+	str("GEMINI_LOCATION", &c.Gemini.Location)
+	str("GEMINI_MODEL", &c.Gemini.Model)
+	str("DEFAULT_DETECTOR", &c.Detection.DefaultDetector)
+	float("EDGE_BLUR_RADIUS", &c.Detection.Edge.BlurRadius)
+	float("EDGE_CANNY_LOW", &c.Detection.Edge.CannyLow)
+	float("EDGE_CANNY_HIGH", &c.Detection.Edge.CannyHigh)
+	integer("EDGE_RESIZE_MAX_WIDTH", &c.Detection.Edge.ResizeMaxWidth)
+	boolean("SIMULATE", &c.Realtime.Simulate)
+	duration("SIMULATION_INTERVAL", &c.Realtime.SimulationInterval)
+	integer("WS_SEND_QUEUE_SIZE", &c.Realtime.SendQueueSize)
+	duration("WS_WRITE_TIMEOUT", &c.Realtime.WriteTimeout)
+	duration("WS_PING_INTERVAL", &c.Realtime.PingInterval)
+	duration("WS_PONG_TIMEOUT", &c.Realtime.PongTimeout)
+	str("WS_SLOW_CLIENT_POLICY", &c.Realtime.SlowClientPolicy)
+	integer("WS_REPLAY_LOG_SIZE", &c.Realtime.ReplayLogSize)
+	str("REALTIME_BROKER", &c.Realtime.Broker)
+	str("REDIS_ADDR", &c.Realtime.Redis.Addr)
+	str("REDIS_PASSWORD", &c.Realtime.Redis.Password)
+	integer("REDIS_DB", &c.Realtime.Redis.DB)
+	str("REDIS_CHANNEL", &c.Realtime.Redis.Channel)
+	integer("JOB_WORKERS", &c.Jobs.Workers)
+	integer("JOB_QUEUE_SIZE", &c.Jobs.QueueSize)
+	integer("JOB_RETAIN", &c.Jobs.Retain)
+	duration("JOB_TIMEOUT", &c.Jobs.Timeout)
+	float("PDF_DPI", &c.PDF.DPI)
+	integer("PDF_MAX_PAGES", &c.PDF.MaxPages)
+	integer("PDF_MAX_PAGE_PIXELS", &c.PDF.MaxPagePixels)
+	boolean("MQTT_ENABLED", &c.MQTT.Enabled)
+	str("MQTT_BROKER", &c.MQTT.Broker)
+	str("MQTT_CLIENT_ID", &c.MQTT.ClientID)
+	str("MQTT_USERNAME", &c.MQTT.Username)
+	str("MQTT_PASSWORD", &c.MQTT.Password)
+	integer("MQTT_QOS", &c.MQTT.QoS)
+	float("EXPORT_PIXELS_PER_METER", &c.Export.PixelsPerMeter)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid environment override: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// AllowsAnyOrigin reports whether AllowedOrigins contains "*".
+func (s ServerConfig) AllowsAnyOrigin() bool {
+	for _, allowed := range s.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin reports whether origin may make cross-origin requests.
+func (s ServerConfig) AllowsOrigin(origin string) bool {
+	for _, allowed := range s.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
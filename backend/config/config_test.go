@@ -0,0 +1,112 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	cfg := Default()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Default().Validate() error = %v", err)
+	}
+}
+
+func TestLoad_FileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	yamlData := `
+server:
+  addr: ":9090"
+  allowed_origins: ["https://twin.example.com"]
+gemini:
+  model: "gemini-2.5-pro"
+detection:
+  edge:
+    canny_high: 120
+realtime:
+  simulation_interval: 5s
+`
+	if err := os.WriteFile(path, []byte(yamlData), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(EnvPrefix+"ADDR", ":7070")
+	t.Setenv(EnvPrefix+"GEMINI_LOCATION", "europe-west4")
+	t.Setenv(EnvPrefix+"PDF_DPI", "300")
+	t.Setenv(EnvPrefix+"SIMULATE", "true")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if cfg.Server.Addr != ":7070" {
+		t.Errorf("Addr = %q, want env override :7070", cfg.Server.Addr)
+	}
+	if cfg.Gemini.Model != "gemini-2.5-pro" || cfg.Gemini.Location != "europe-west4" {
+		t.Errorf("Gemini = %+v, want file model and env location", cfg.Gemini)
+	}
+	if cfg.Gemini.ProjectID != "floorplan-digital-twin" {
+		t.Errorf("ProjectID = %q, want default to survive partial file", cfg.Gemini.ProjectID)
+	}
+	if cfg.Detection.Edge.CannyHigh != 120 || cfg.Detection.Edge.CannyLow != 50 {
+		t.Errorf("Edge = %+v, want canny_high from file and default canny_low", cfg.Detection.Edge)
+	}
+	if !cfg.Realtime.Simulate || cfg.Realtime.SimulationInterval != 5*time.Second {
+		t.Errorf("Realtime = %+v, want env simulate and 5s interval from file", cfg.Realtime)
+	}
+	if cfg.PDF.DPI != 300 || cfg.PDF.MaxPages != 20 {
+		t.Errorf("PDF = %+v, want env DPI and default max_pages", cfg.PDF)
+	}
+	if !cfg.Server.AllowsOrigin("https://twin.example.com") || cfg.Server.AllowsOrigin("https://evil.example.com") {
+		t.Errorf("AllowedOrigins = %v not applied", cfg.Server.AllowedOrigins)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing config file")
+	}
+
+	typo := filepath.Join(t.TempDir(), "typo.yaml")
+	os.WriteFile(typo, []byte("server:\n  allowed_origin: [\"https://twin.example.com\"]\n"), 0o600)
+	if _, err := Load(typo); err == nil || !strings.Contains(err.Error(), "allowed_origin") {
+		t.Errorf("expected error for unknown key, got %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.yaml")
+	os.WriteFile(empty, nil, 0o600)
+	if _, err := Load(empty); err != nil {
+		t.Errorf("empty config file error = %v, want defaults", err)
+	}
+
+	t.Setenv(EnvPrefix+"EDGE_CANNY_LOW", "not-a-number")
+	if _, err := Load(""); err == nil || !strings.Contains(err.Error(), "EDGE_CANNY_LOW") {
+		t.Errorf("expected env parse error, got %v", err)
+	}
+}
+
+func TestValidate_ReportsAllProblems(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Addr = ""
+	cfg.Detection.Edge.CannyLow = 200
+	cfg.Realtime.SimulationInterval = 0
+	cfg.Realtime.SlowClientPolicy = "block"
+	cfg.Realtime.Broker = "kafka"
+	cfg.MQTT.Enabled = true
+	cfg.MQTT.QoS = 3
+	cfg.Export.PixelsPerMeter = 0
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"server.addr", "canny_high", "simulation_interval", "slow_client_policy", "realtime.broker", "mqtt.qos", "pixels_per_meter"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+}
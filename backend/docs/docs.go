@@ -24,36 +24,45 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/api/v1/process/crop": {
-            "post": {
-                "description": "Detect and crop the floorplan area from a paper document image",
-                "consumes": [
-                    "application/json"
-                ],
+        "/api/v1/floorplans": {
+            "get": {
+                "description": "List stored floorplans, paginated and sorted by creation time",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "edge-detection"
+                    "floorplans"
                 ],
-                "summary": "Automatic crop floorplan from paper",
-                "operationId": "cropFloorplan",
+                "summary": "List floorplans",
+                "operationId": "listFloorplans",
                 "parameters": [
                     {
-                        "description": "Image and edge detection options",
-                        "name": "request",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/handler.CropFloorplanRequest"
-                        }
+                        "type": "integer",
+                        "default": 1,
+                        "description": "Page number (1-based)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Items per page (max 100)",
+                        "name": "page_size",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "-created_at",
+                        "description": "Sort order: created_at or -created_at",
+                        "name": "sort",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Cropped floorplan",
+                        "description": "Page of floorplans",
                         "schema": {
-                            "$ref": "#/definitions/handler.CropFloorplanResponse"
+                            "$ref": "#/definitions/handler.FloorplanListResponse"
                         }
                     },
                     "400": {
@@ -77,62 +86,89 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/process/edges": {
-            "post": {
-                "description": "Process a floorplan image to detect edges using Canny edge detection",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+        "/api/v1/floorplans/{id}": {
+            "get": {
+                "description": "Get a stored floorplan including its image and rooms",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "edge-detection"
+                    "floorplans"
                 ],
-                "summary": "Detect edges in a floorplan image",
-                "operationId": "detectEdges",
+                "summary": "Get a floorplan",
+                "operationId": "getFloorplan",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "Floorplan image file",
-                        "name": "file",
-                        "in": "formData",
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Floorplan",
+                        "schema": {
+                            "$ref": "#/definitions/models.Floorplan"
+                        }
                     },
-                    {
-                        "type": "number",
-                        "default": 1.2,
-                        "description": "Blur radius for preprocessing",
-                        "name": "blur_radius",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "number",
-                        "default": 50,
-                        "description": "Canny low threshold",
-                        "name": "canny_low",
-                        "in": "formData"
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
                     },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Replace the filename and (optionally) the full room list and georeferencing transform of a floorplan. Room IDs must be unique. Rooms that keep their ID keep their occupancy, and their status must be omitted or unchanged: status is changed with POST /api/v1/floorplans/{id}/rooms/{roomId}/status.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Update a floorplan",
+                "operationId": "updateFloorplan",
+                "parameters": [
                     {
-                        "type": "number",
-                        "default": 150,
-                        "description": "Canny high threshold",
-                        "name": "canny_high",
-                        "in": "formData"
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
                     },
                     {
-                        "type": "integer",
-                        "default": 800,
-                        "description": "Maximum width for resizing",
-                        "name": "resize_max_width",
-                        "in": "formData"
+                        "description": "Updated floorplan fields",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.UpdateFloorplanRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Edge detection result",
+                        "description": "Updated floorplan",
                         "schema": {
-                            "$ref": "#/definitions/handler.EdgeDetectionResponse"
+                            "$ref": "#/definitions/models.Floorplan"
                         }
                     },
                     "400": {
@@ -144,6 +180,55 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Delete a stored floorplan and all of its rooms",
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Delete a floorplan",
+                "operationId": "deleteFloorplan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted"
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -156,9 +241,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/process/edges-json": {
+        "/api/v1/floorplans/{id}/calibration": {
             "post": {
-                "description": "Process a floorplan image (provided as base64) to detect edges",
+                "description": "Set the floorplan's pixels_per_meter from two points on the cropped image and the real distance between them, e.g. the ends of a scale bar. Floorplan and room responses then include each room's width_m, height_m and area_m2, and svg and dxf exports use this scale by default.",
                 "consumes": [
                     "application/json"
                 ],
@@ -166,28 +251,33 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "edge-detection"
+                    "floorplans"
                 ],
-                "summary": "Detect edges using JSON request with base64 image",
-                "operationId": "detectEdgesJSON",
+                "summary": "Calibrate a floorplan's scale",
+                "operationId": "calibrateFloorplan",
                 "parameters": [
                     {
-                        "description": "JSON request with image and options",
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Two points and their distance in meters",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/handler.CalibrationRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Edge detection result with processed image",
+                        "description": "Calibrated floorplan",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.Floorplan"
                         }
                     },
                     "400": {
@@ -199,6 +289,15 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -211,35 +310,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/v1/upload": {
-            "post": {
-                "description": "Upload a floorplan image (PNG, JPG, JPEG) and return the detected rooms",
-                "consumes": [
-                    "multipart/form-data"
-                ],
+        "/api/v1/floorplans/{id}/events": {
+            "get": {
+                "description": "Server-sent events carrying the same JSON messages as the websocket, named by their type: a subscribed acknowledgement, a snapshot of the rooms' statuses, then status_update messages and broadcasts. Snapshots and status updates have their seq as event ID, so a reconnecting client sending Last-Event-ID gets the updates it missed.",
                 "produces": [
-                    "application/json"
+                    "text/event-stream"
                 ],
                 "tags": [
-                    "upload"
+                    "status"
                 ],
-                "summary": "Upload a floorplan image",
-                "operationId": "uploadFloorplan",
+                "summary": "Stream a floorplan's live updates",
+                "operationId": "streamFloorplanEvents",
                 "parameters": [
                     {
-                        "type": "file",
-                        "description": "Floorplan image file",
-                        "name": "file",
-                        "in": "formData",
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Seq of the last event received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Detection results with rooms",
+                        "description": "Event stream",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "type": "string"
                         }
                     },
                     "400": {
@@ -251,6 +352,15 @@ const docTemplate = `{
                             }
                         }
                     },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
@@ -262,65 +372,1970 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "handler.CropFloorplanRequest": {
-            "type": "object",
-            "required": [
-                "image"
-            ],
-            "properties": {
-                "image": {
-                    "description": "base64 or data:image/...",
-                    "type": "string"
-                },
-                "options": {
-                    "$ref": "#/definitions/handler.EdgeDetectionRequest"
-                }
-            }
         },
-        "handler.CropFloorplanResponse": {
-            "type": "object",
-            "properties": {
-                "cropped_image": {
-                    "type": "string"
-                },
-                "message": {
-                    "type": "string"
+        "/api/v1/floorplans/{id}/export": {
+            "get": {
+                "description": "Export a floorplan for GIS, wayfinding and CAD tools.\ngeojson returns an IMDF-style feature collection with a level feature for the floorplan and a unit polygon per room (name, type, status, category, and width_m, height_m and area_m2 once the floorplan is calibrated); coordinates are WGS 84 when the floorplan has a geo_transform and cropped image pixels otherwise.\nsvg and dxf return the rooms in meters, scaled by pixels_per_meter, grouped in layers named by room type and labeled with their names, and with their areas once the floorplan is calibrated or pixels_per_meter is given; svg can embed the floorplan image underneath.",
+                "produces": [
+                    "application/json",
+                    "image/svg+xml",
+                    "image/vnd.dxf"
+                ],
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Export a floorplan",
+                "operationId": "exportFloorplan",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Export format: geojson, svg or dxf (default: geojson)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Image scale for svg and dxf (default: the floorplan's calibrated scale, else export.pixels_per_meter)",
+                        "name": "pixels_per_meter",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Embed the floorplan image in svg exports (default: false)",
+                        "name": "include_image",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "GeoJSON feature collection, or an SVG or DXF file",
+                        "schema": {
+                            "$ref": "#/definitions/export.FeatureCollection"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
                 }
             }
         },
-        "handler.EdgeDetectionRequest": {
-            "type": "object",
-            "properties": {
-                "blur_radius": {
-                    "type": "number",
-                    "default": 1.2
-                },
-                "canny_high": {
+        "/api/v1/floorplans/{id}/graph": {
+            "get": {
+                "description": "List the floorplan's rooms as nodes and the connections between them as edges. Rooms linked by a door connect through it; other rooms connect where their outlines run alongside each other, no further apart than the thickest wall (at least 10 pixels) for at least 10 pixels. Distances are in cropped image pixels, from room center to room center through the connection.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "navigation"
+                ],
+                "summary": "Get the room adjacency graph",
+                "operationId": "getFloorplanGraph",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/navigation.Graph"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/heatmap.png": {
+            "get": {
+                "description": "Render the stored floorplan image with each room filled by how busy it was between from and to, from green (never) through yellow to red (always), labeled with the busy percentage. Rooms without recorded status are grey.",
+                "produces": [
+                    "image/png"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Render a utilization heatmap",
+                "operationId": "getFloorplanHeatmap",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start, RFC 3339 (default: 24 hours before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End, RFC 3339 (default: now)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "PNG image",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/overlay": {
+            "get": {
+                "description": "Render the stored cropped image with every room outlined, color-coded by its type or status, and labeled with its name, for reviewing detection results",
+                "produces": [
+                    "image/png",
+                    "image/svg+xml"
+                ],
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Render detected rooms over a floorplan",
+                "operationId": "getFloorplanOverlay",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output format: png or svg (default: png)",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Color rooms by type or status (default: type)",
+                        "name": "color_by",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Annotated image",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/rooms": {
+            "post": {
+                "description": "Add a room that was missed by detection",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Add a room to a floorplan",
+                "operationId": "createRoom",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Room to add",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CreateRoomRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created room",
+                        "schema": {
+                            "$ref": "#/definitions/models.Room"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/rooms/{roomId}": {
+            "delete": {
+                "description": "Remove a falsely detected room from a floorplan",
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Remove a room",
+                "operationId": "deleteRoom",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room ID",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Deleted"
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Update the name, type, rect or polygon of a detected room. Status is changed with POST /api/v1/floorplans/{id}/rooms/{roomId}/status; a status here must equal the current one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "floorplans"
+                ],
+                "summary": "Correct a room",
+                "operationId": "patchRoom",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room ID",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.PatchRoomRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated room",
+                        "schema": {
+                            "$ref": "#/definitions/models.Room"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/rooms/{roomId}/history": {
+            "get": {
+                "description": "List the recorded status and occupancy changes of a room with from \u003c= timestamp \u003c to, oldest first. initial is the last change before from, i.e. the room's state at from.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Get a room's status history",
+                "operationId": "getRoomHistory",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room ID",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start, RFC 3339 (default: 24 hours before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End, RFC 3339 (default: now)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Status changes",
+                        "schema": {
+                            "$ref": "#/definitions/occupancy.RoomHistory"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/rooms/{roomId}/status": {
+            "post": {
+                "description": "Set the status and/or occupancy count of a room. The stored room is updated and a status_update is pushed to websocket clients subscribed to the floorplan.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Report a room's live status",
+                "operationId": "setRoomStatus",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room ID",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "New status and/or occupancy",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.RoomStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated room",
+                        "schema": {
+                            "$ref": "#/definitions/models.Room"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/rooms/{roomId}/utilization": {
+            "get": {
+                "description": "Aggregate a room's status history into hourly or daily buckets with the percentage of time it was busy, available, offline or unknown, and its peak occupancy.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Get a room's utilization",
+                "operationId": "getRoomUtilization",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room ID",
+                        "name": "roomId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start, RFC 3339 (default: 24 hours before to)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End, RFC 3339 (default: now)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bucket size: hour (default) or day",
+                        "name": "interval",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "IANA time zone the buckets are aligned in (default: UTC)",
+                        "name": "tz",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Utilization per bucket",
+                        "schema": {
+                            "$ref": "#/definitions/occupancy.Utilization"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/route": {
+            "get": {
+                "description": "Find the shortest path through the room adjacency graph (see getFloorplanGraph) from one room to another, for wayfinding. By default a floorplan with doors is only crossed through doors, since rooms that merely share a boundary are separated by a wall; a floorplan without doors is crossed at shared boundaries. via=door or via=any picks the edges explicitly.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "navigation"
+                ],
+                "summary": "Find a route between rooms",
+                "operationId": "getFloorplanRoute",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start room ID",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Destination room ID",
+                        "name": "to",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Edges the route may use: door or any; default doors when the floorplan has any, else any",
+                        "name": "via",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/navigation.Route"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Floorplan or room not found, or no route",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/floorplans/{id}/status": {
+            "post": {
+                "description": "Set the status and/or occupancy of several rooms at once. All changes are validated and stored together; each changed room is pushed to subscribed websocket clients.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "status"
+                ],
+                "summary": "Report several rooms' live status",
+                "operationId": "setRoomStatuses",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Floorplan ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Changes by room ID",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkRoomStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated rooms",
+                        "schema": {
+                            "$ref": "#/definitions/handler.BulkRoomStatusResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/jobs": {
+            "post": {
+                "description": "Accepts the same upload as /api/v1/upload but returns immediately with a job ID. Poll GET /api/v1/jobs/{id}, or subscribe to the job ID on /ws for job_progress messages; on success the job result is {\"floorplan_ids\": [...]}, the saved floorplans (one per PDF page, in page order) to load from GET /api/v1/floorplans/{id}.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Submit a floorplan for asynchronous analysis",
+                "operationId": "createJob",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Floorplan image or PDF file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room detector: gemini (Vertex AI) or classical (offline edge-based)",
+                        "name": "detector",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Queued job",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "503": {
+                        "description": "Job queue is full",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/jobs/{id}": {
+            "get": {
+                "description": "Report the state (queued, running, succeeded, failed) of an analysis job and, once finished, its result or error",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "jobs"
+                ],
+                "summary": "Get job status",
+                "operationId": "getJob",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Job ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Job",
+                        "schema": {
+                            "$ref": "#/definitions/jobs.Job"
+                        }
+                    },
+                    "404": {
+                        "description": "Not found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/process/crop": {
+            "post": {
+                "description": "Detect and crop the floorplan area from a paper document image",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "edge-detection"
+                ],
+                "summary": "Automatic crop floorplan from paper",
+                "operationId": "cropFloorplan",
+                "parameters": [
+                    {
+                        "description": "Image and edge detection options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CropFloorplanRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Cropped floorplan",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CropFloorplanResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/process/edges": {
+            "post": {
+                "description": "Process a floorplan image to detect edges using Canny edge detection",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "edge-detection"
+                ],
+                "summary": "Detect edges in a floorplan image",
+                "operationId": "detectEdges",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Floorplan image file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "default": 1.2,
+                        "description": "Blur radius for preprocessing",
+                        "name": "blur_radius",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "default": 50,
+                        "description": "Canny low threshold",
+                        "name": "canny_low",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "number",
+                        "default": 150,
+                        "description": "Canny high threshold",
+                        "name": "canny_high",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 800,
+                        "description": "Maximum width for resizing",
+                        "name": "resize_max_width",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Edge detection result",
+                        "schema": {
+                            "$ref": "#/definitions/handler.EdgeDetectionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/process/edges-json": {
+            "post": {
+                "description": "Process a floorplan image (provided as base64) to detect edges",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "edge-detection"
+                ],
+                "summary": "Detect edges using JSON request with base64 image",
+                "operationId": "detectEdgesJSON",
+                "parameters": [
+                    {
+                        "description": "JSON request with image and options",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Edge detection result with processed image",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/upload": {
+            "post": {
+                "description": "Upload a floorplan image (PNG, JPG, JPEG) or PDF, detect its rooms and persist the result. Each PDF page is rasterized server-side, text included, and becomes its own floorplan; if any page fails, none are saved. PDF uploads respond with {\"floorplans\": [...]}, one entry per page in the image response shape plus \"page\".",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "upload"
+                ],
+                "summary": "Upload a floorplan image or PDF",
+                "operationId": "uploadFloorplan",
+                "parameters": [
+                    {
+                        "type": "file",
+                        "description": "Floorplan image or PDF file",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Room detector: gemini (Vertex AI) or classical (offline edge-based)",
+                        "name": "detector",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Detection results with rooms",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "export.Feature": {
+            "type": "object",
+            "properties": {
+                "feature_type": {
+                    "type": "string"
+                },
+                "geometry": {
+                    "$ref": "#/definitions/export.Polygon"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "properties": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "export.FeatureCollection": {
+            "type": "object",
+            "properties": {
+                "features": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/export.Feature"
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "export.Polygon": {
+            "type": "object",
+            "properties": {
+                "coordinates": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "array",
+                            "items": {
+                                "type": "number",
+                                "format": "float64"
+                            }
+                        }
+                    }
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.BulkRoomStatusRequest": {
+            "type": "object",
+            "required": [
+                "rooms"
+            ],
+            "properties": {
+                "rooms": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/occupancy.Change"
+                    }
+                }
+            }
+        },
+        "handler.BulkRoomStatusResponse": {
+            "type": "object",
+            "properties": {
+                "rooms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Room"
+                    }
+                }
+            }
+        },
+        "handler.CalibrationRequest": {
+            "type": "object",
+            "required": [
+                "meters"
+            ],
+            "properties": {
+                "end": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "meters": {
+                    "description": "Real distance between the points",
+                    "type": "number"
+                },
+                "start": {
+                    "description": "[x, y] in cropped image pixels",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                }
+            }
+        },
+        "handler.CreateRoomRequest": {
+            "type": "object",
+            "required": [
+                "name"
+            ],
+            "properties": {
+                "name": {
+                    "type": "string"
+                },
+                "polygon": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "rect": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/models.RoomStatus"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.RoomType"
+                }
+            }
+        },
+        "handler.CropFloorplanRequest": {
+            "type": "object",
+            "required": [
+                "image"
+            ],
+            "properties": {
+                "image": {
+                    "description": "base64 or data:image/...",
+                    "type": "string"
+                },
+                "options": {
+                    "$ref": "#/definitions/handler.EdgeDetectionRequest"
+                }
+            }
+        },
+        "handler.CropFloorplanResponse": {
+            "type": "object",
+            "properties": {
+                "cropped_image": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.EdgeDetectionRequest": {
+            "type": "object",
+            "properties": {
+                "blur_radius": {
+                    "type": "number",
+                    "default": 1.2
+                },
+                "canny_high": {
                     "type": "number",
                     "default": 150
                 },
-                "canny_low": {
-                    "type": "number",
-                    "default": 50
+                "canny_low": {
+                    "type": "number",
+                    "default": 50
+                },
+                "resize_max_width": {
+                    "type": "integer",
+                    "default": 800
+                }
+            }
+        },
+        "handler.EdgeDetectionResponse": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "processed_image": {
+                    "description": "data:image/png;base64,...",
+                    "type": "string"
+                }
+            }
+        },
+        "handler.FloorplanListResponse": {
+            "type": "object",
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Floorplan"
+                    }
+                },
+                "page": {
+                    "type": "integer"
                 },
-                "resize_max_width": {
-                    "type": "integer",
-                    "default": 800
+                "page_size": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
                 }
             }
         },
-        "handler.EdgeDetectionResponse": {
+        "handler.PatchRoomRequest": {
             "type": "object",
             "properties": {
-                "message": {
+                "name": {
                     "type": "string"
                 },
-                "processed_image": {
-                    "description": "data:image/png;base64,...",
+                "polygon": {
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "rect": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "description": "Must equal the current status (see errStatusEdit)",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.RoomStatus"
+                        }
+                    ]
+                },
+                "type": {
+                    "$ref": "#/definitions/models.RoomType"
+                }
+            }
+        },
+        "handler.RoomStatusRequest": {
+            "type": "object",
+            "properties": {
+                "occupancy": {
+                    "type": "integer"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.RoomStatus"
+                }
+            }
+        },
+        "handler.UpdateFloorplanRequest": {
+            "type": "object",
+            "required": [
+                "filename"
+            ],
+            "properties": {
+                "filename": {
+                    "type": "string"
+                },
+                "geo_transform": {
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "rooms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Room"
+                    }
+                }
+            }
+        },
+        "jobs.Job": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "result": {},
+                "stage": {
+                    "description": "Free-form progress label while running",
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/jobs.State"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "jobs.State": {
+            "type": "string",
+            "enum": [
+                "queued",
+                "running",
+                "succeeded",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "StateQueued",
+                "StateRunning",
+                "StateSucceeded",
+                "StateFailed"
+            ]
+        },
+        "models.ContentBox": {
+            "type": "object",
+            "properties": {
+                "bounds": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "crop": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "original_height": {
+                    "type": "integer"
+                },
+                "original_width": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Door": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "position": {
+                    "description": "Center of the opening",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "room_ids": {
+                    "description": "Rooms on either side, nearest first; one for exterior doors",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "width": {
+                    "description": "Clear width in pixels",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Floorplan": {
+            "type": "object",
+            "properties": {
+                "content_box": {
+                    "description": "Where the cropped image lies on the uploaded sheet",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.ContentBox"
+                        }
+                    ]
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "doors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Door"
+                    }
+                },
+                "filename": {
+                    "type": "string"
+                },
+                "geo_transform": {
+                    "description": "Pixel to WGS 84 mapping, when georeferenced",
+                    "type": "array",
+                    "items": {
+                        "type": "number"
+                    }
+                },
+                "height": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "image_url": {
+                    "description": "Data URI or URL",
+                    "type": "string"
+                },
+                "page": {
+                    "description": "1-based source page for floorplans rasterized from a PDF",
+                    "type": "integer"
+                },
+                "pixels_per_meter": {
+                    "description": "Scale of the cropped image, once calibrated",
+                    "type": "number"
+                },
+                "rooms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Room"
+                    }
+                },
+                "walls": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Wall"
+                    }
+                },
+                "width": {
+                    "type": "integer"
+                },
+                "windows": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Window"
+                    }
+                }
+            }
+        },
+        "models.Room": {
+            "type": "object",
+            "properties": {
+                "area_m2": {
+                    "type": "number"
+                },
+                "floorplan_id": {
+                    "type": "string"
+                },
+                "height_m": {
+                    "type": "number"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "occupancy": {
+                    "description": "People currently in the room, as last reported",
+                    "type": "integer"
+                },
+                "polygon": {
+                    "description": "Outline of non-rectangular rooms",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "integer"
+                        }
+                    }
+                },
+                "rect": {
+                    "description": "[x, y, w, h]; the polygon's bounding box when it has one",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "status": {
+                    "$ref": "#/definitions/models.RoomStatus"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.RoomType"
+                },
+                "width_m": {
+                    "description": "Size in meters, filled in by Measure for responses from calibrated floorplans.",
+                    "type": "number"
+                }
+            }
+        },
+        "models.RoomStatus": {
+            "type": "string",
+            "enum": [
+                "AVAILABLE",
+                "BUSY",
+                "OFFLINE"
+            ],
+            "x-enum-varnames": [
+                "RoomStatusAvailable",
+                "RoomStatusBusy",
+                "RoomStatusOffline"
+            ]
+        },
+        "models.RoomType": {
+            "type": "string",
+            "enum": [
+                "OFFICE",
+                "MEETING",
+                "HALLWAY",
+                "UNKNOWN"
+            ],
+            "x-enum-varnames": [
+                "RoomTypeOffice",
+                "RoomTypeMeeting",
+                "RoomTypeHallway",
+                "RoomTypeUnknown"
+            ]
+        },
+        "models.StatusChange": {
+            "type": "object",
+            "properties": {
+                "floorplan_id": {
+                    "type": "string"
+                },
+                "occupancy": {
+                    "type": "integer"
+                },
+                "room_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.RoomStatus"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Wall": {
+            "type": "object",
+            "properties": {
+                "end": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "start": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "thickness": {
+                    "description": "Pixels across the wall",
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Window": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "position": {
+                    "description": "Center of the opening",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "room_ids": {
+                    "description": "Rooms it opens onto, nearest first",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "width": {
+                    "description": "Clear width in pixels",
+                    "type": "integer"
+                }
+            }
+        },
+        "navigation.Edge": {
+            "type": "object",
+            "properties": {
+                "distance": {
+                    "description": "Pixels from From's center through Point to To's center",
+                    "type": "number"
+                },
+                "door_id": {
+                    "type": "string"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "point": {
+                    "description": "Where the edge crosses from one room into the other",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "to": {
+                    "type": "string"
+                },
+                "via": {
+                    "description": "ViaDoor or ViaBoundary",
+                    "type": "string"
+                }
+            }
+        },
+        "navigation.Graph": {
+            "type": "object",
+            "properties": {
+                "edges": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/navigation.Edge"
+                    }
+                },
+                "nodes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/navigation.Node"
+                    }
+                }
+            }
+        },
+        "navigation.Node": {
+            "type": "object",
+            "properties": {
+                "center": {
+                    "description": "Center of the room's bounding rect",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "$ref": "#/definitions/models.RoomType"
+                }
+            }
+        },
+        "navigation.Route": {
+            "type": "object",
+            "properties": {
+                "distance": {
+                    "description": "Sum of the edges' distances, in pixels",
+                    "type": "number"
+                },
+                "edges": {
+                    "description": "Edges[i] leads from Rooms[i] to Rooms[i+1]",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/navigation.Edge"
+                    }
+                },
+                "rooms": {
+                    "description": "From the start room to the destination",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/navigation.Node"
+                    }
+                }
+            }
+        },
+        "occupancy.Change": {
+            "type": "object",
+            "properties": {
+                "occupancy": {
+                    "type": "integer"
+                },
+                "room_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "$ref": "#/definitions/models.RoomStatus"
+                }
+            }
+        },
+        "occupancy.RoomHistory": {
+            "type": "object",
+            "properties": {
+                "changes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.StatusChange"
+                    }
+                },
+                "from": {
+                    "type": "string"
+                },
+                "initial": {
+                    "description": "Last change before From: the room's state at From, if known",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.StatusChange"
+                        }
+                    ]
+                },
+                "room_id": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "occupancy.Utilization": {
+            "type": "object",
+            "properties": {
+                "buckets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/occupancy.UtilizationBucket"
+                    }
+                },
+                "busy_percent": {
+                    "description": "Over the whole range",
+                    "type": "number"
+                },
+                "from": {
+                    "type": "string"
+                },
+                "interval": {
+                    "type": "string"
+                },
+                "room_id": {
+                    "type": "string"
+                },
+                "to": {
+                    "type": "string"
+                }
+            }
+        },
+        "occupancy.UtilizationBucket": {
+            "type": "object",
+            "properties": {
+                "available_percent": {
+                    "type": "number"
+                },
+                "busy_percent": {
+                    "type": "number"
+                },
+                "end": {
+                    "type": "string"
+                },
+                "offline_percent": {
+                    "type": "number"
+                },
+                "peak_occupancy": {
+                    "type": "integer"
+                },
+                "start": {
                     "type": "string"
+                },
+                "unknown_percent": {
+                    "description": "Before the first recorded change, or in the future",
+                    "type": "number"
                 }
             }
         }
@@ -0,0 +1,125 @@
+package export
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"floorplan-whiteboard/models"
+)
+
+func testFloorplan() *models.Floorplan {
+	return &models.Floorplan{ID: "fp", Width: 200, Height: 100, ImageURL: "data:image/png;base64,AAAA", Rooms: []models.Room{
+		{ID: "room-a", Name: "Café", Type: models.RoomTypeOffice, Rect: models.Rect{0, 0, 100, 50}},
+		{ID: "room-b", Name: "Board <room>", Type: models.RoomTypeMeeting, Rect: models.Rect{100, 0, 100, 100}},
+		{ID: "room-c", Rect: models.Rect{0, 50, 50, 50}},
+		{ID: "room-d", Name: "Corridor", Type: models.RoomTypeHallway, Rect: models.Rect{50, 50, 50, 50}, Polygon: []models.Point{{50, 50}, {100, 50}, {50, 100}}},
+	}}
+}
+
+func TestSVG(t *testing.T) {
+	svg := string(SVG(testFloorplan(), SVGOptions{PixelsPerMeter: 50, Measured: true}))
+	for _, want := range []string{
+		`width="4000mm" height="2000mm" viewBox="0 0 4 2"`,
+		`<g id="MEETING"`, `<g id="OFFICE"`, `<g id="UNKNOWN"`,
+		`<rect x="2" y="0" width="2" height="2"/>`,
+		`<text x="3" y="1"`, "Board &lt;room&gt;",
+		`<g id="room-c" data-width-m="1" data-height-m="1" data-area-m2="1">`, ">room-c</text>",
+		`<g id="room-d" data-width-m="1" data-height-m="1" data-area-m2="0.5">`,
+		`<polygon points="1,1 2,1 1,2"/>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %s:\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, "<image") {
+		t.Error("SVG embeds the image without includeImage")
+	}
+	if !strings.Contains(string(SVG(testFloorplan(), SVGOptions{PixelsPerMeter: 50, IncludeImage: true})), `<image id="floorplan" href="data:image/png;base64,AAAA"`) {
+		t.Error("SVG does not embed the image with includeImage")
+	}
+	if unmeasured := string(SVG(testFloorplan(), SVGOptions{PixelsPerMeter: 50})); strings.Contains(unmeasured, "data-area-m2") {
+		t.Errorf("SVG has sizes without Measured:\n%s", unmeasured)
+	}
+}
+
+func TestSVG_Pixels(t *testing.T) {
+	svg := string(SVG(testFloorplan(), SVGOptions{RoomColor: func(room models.Room) string {
+		if room.ID == "room-b" {
+			return "#ff0000"
+		}
+		return "#00ff00"
+	}}))
+	for _, want := range []string{
+		`width="200" height="100" viewBox="0 0 200 100"`,
+		`<g id="room-b" fill="#ff0000" stroke="#ff0000"><rect x="100" y="0" width="100" height="100"/>`,
+		`font-size="12"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %s:\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, "room-room-") || strings.Contains(svg, "data-width-m") {
+		t.Errorf("unexpected room group attributes:\n%s", svg)
+	}
+}
+
+// dxfPairs parses a DXF file into group code/value pairs.
+func dxfPairs(t *testing.T, data []byte) [][2]string {
+	t.Helper()
+	var pairs [][2]string
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		code := strings.TrimSpace(scanner.Text())
+		if !scanner.Scan() {
+			t.Fatalf("DXF ends after group code %s", code)
+		}
+		pairs = append(pairs, [2]string{code, scanner.Text()})
+	}
+	return pairs
+}
+
+func TestDXF(t *testing.T) {
+	pairs := dxfPairs(t, DXF(testFloorplan(), 50, true))
+	if last := pairs[len(pairs)-1]; last != [2]string{"0", "EOF"} {
+		t.Fatalf("last pair = %v, want EOF", last)
+	}
+
+	layers := map[string]bool{}
+	var vertices [][2]string
+	hallwayVertices := 0
+	var texts []string
+	for i, p := range pairs {
+		switch {
+		case p == [2]string{"0", "LAYER"}:
+			layers[pairs[i+1][1]] = true
+		case p == [2]string{"0", "VERTEX"} && pairs[i+1][1] == "MEETING":
+			vertices = append(vertices, [2]string{pairs[i+2][1], pairs[i+3][1]})
+		case p == [2]string{"0", "VERTEX"} && pairs[i+1][1] == "HALLWAY":
+			hallwayVertices++
+		case p[0] == "1" && i > 0 && pairs[i-1][0] == "40":
+			texts = append(texts, p[1])
+		}
+	}
+	for _, name := range []string{"OFFICE", "MEETING", "UNKNOWN", "HALLWAY"} {
+		if !layers[name] {
+			t.Errorf("DXF has no %s layer: %v", name, layers)
+		}
+	}
+	// The meeting room spans x 2-4 m and, with y flipped, y 0-2 m.
+	want := [][2]string{{"2", "2"}, {"4", "2"}, {"4", "0"}, {"2", "0"}}
+	if len(vertices) != 4 || vertices[0] != want[0] || vertices[1] != want[1] || vertices[2] != want[2] || vertices[3] != want[3] {
+		t.Errorf("meeting room vertices = %v, want %v", vertices, want)
+	}
+	if hallwayVertices != 3 {
+		t.Errorf("hallway polygon has %d vertices, want 3", hallwayVertices)
+	}
+	if strings.Join(texts, ",") != `Corridor,0.5 m2,Board <room>,4 m2,Caf\U+00E9,2 m2,room-c,1 m2` {
+		t.Errorf("labels = %q", texts)
+	}
+
+	// At an assumed scale, areas would be made up.
+	if dxf := string(DXF(testFloorplan(), 50, false)); strings.Contains(dxf, " m2") {
+		t.Errorf("unmeasured DXF prints areas:\n%s", dxf)
+	}
+}
@@ -0,0 +1,154 @@
+package export
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"floorplan-whiteboard/models"
+)
+
+// dxfTextHeight is the height of room labels, in meters.
+const dxfTextHeight = 0.3
+
+// DXF converts fp into an ASCII DXF (R12) drawing in meters: each room is a
+// closed polyline along its polygon or rect, with its name centered on the
+// rect, on a layer named by its RoomType. pixelsPerMeter converts the cropped
+// image coordinates rooms are stored in; the y axis is flipped so the drawing
+// is upright in CAD tools, with the floorplan's bottom-left corner at the
+// origin. With measured, each room's floor area is printed under its name;
+// leave it off when pixelsPerMeter is only an assumed scale.
+func DXF(fp *models.Floorplan, pixelsPerMeter float64, measured bool) []byte {
+	x := func(px float64) float64 { return px / pixelsPerMeter }
+	y := func(px float64) float64 { return (float64(fp.Height) - px) / pixelsPerMeter }
+	list := layers(fp)
+
+	w := &dxfWriter{}
+	w.section("HEADER")
+	w.pair(9, "$ACADVER")
+	w.pair(1, "AC1009")
+	w.pair(9, "$EXTMIN")
+	w.point(10, 0, 0)
+	w.pair(9, "$EXTMAX")
+	w.point(10, float64(fp.Width)/pixelsPerMeter, float64(fp.Height)/pixelsPerMeter)
+	w.pair(0, "ENDSEC")
+
+	w.section("TABLES")
+	w.pair(0, "TABLE")
+	w.pair(2, "LTYPE")
+	w.integer(70, 1)
+	w.pair(0, "LTYPE")
+	w.pair(2, "CONTINUOUS")
+	w.integer(70, 0)
+	w.pair(3, "Solid line")
+	w.integer(72, 65)
+	w.integer(73, 0)
+	w.number(40, 0)
+	w.pair(0, "ENDTAB")
+	w.pair(0, "TABLE")
+	w.pair(2, "LAYER")
+	w.integer(70, len(list))
+	for _, l := range list {
+		w.pair(0, "LAYER")
+		w.pair(2, l.name)
+		w.integer(70, 0)
+		w.integer(62, l.color)
+		w.pair(6, "CONTINUOUS")
+	}
+	w.pair(0, "ENDTAB")
+	w.pair(0, "ENDSEC")
+
+	w.section("ENTITIES")
+	for _, l := range list {
+		for _, room := range l.rooms {
+			w.pair(0, "POLYLINE")
+			w.pair(8, l.name)
+			w.integer(66, 1)
+			w.integer(70, 1) // Closed
+			w.point(10, 0, 0)
+			for _, p := range room.Outline() {
+				w.pair(0, "VERTEX")
+				w.pair(8, l.name)
+				w.point(10, x(float64(p[0])), y(float64(p[1])))
+			}
+			w.pair(0, "SEQEND")
+			w.pair(8, l.name)
+
+			cx := x(float64(room.Rect[0]) + float64(room.Rect[2])/2)
+			cy := y(float64(room.Rect[1]) + float64(room.Rect[3])/2)
+			w.pair(0, "TEXT")
+			w.pair(8, l.name)
+			w.point(10, cx, cy)
+			w.number(40, dxfTextHeight)
+			w.pair(1, dxfText(room.Label()))
+			w.integer(72, 1) // Centered
+			w.integer(73, 2) // Middle
+			w.point(11, cx, cy)
+
+			if !measured {
+				continue
+			}
+			room.Measure(pixelsPerMeter)
+			ay := cy - 1.5*dxfTextHeight
+			w.pair(0, "TEXT")
+			w.pair(8, l.name)
+			w.point(10, cx, ay)
+			w.number(40, dxfTextHeight*0.8)
+			w.pair(1, decimal(room.AreaM2)+" m2")
+			w.integer(72, 1) // Centered
+			w.integer(73, 2) // Middle
+			w.point(11, cx, ay)
+		}
+	}
+	w.pair(0, "ENDSEC")
+	w.pair(0, "EOF")
+	return []byte(w.String())
+}
+
+// dxfWriter accumulates DXF group code/value pairs.
+type dxfWriter struct {
+	strings.Builder
+}
+
+func (w *dxfWriter) pair(code int, value string) {
+	fmt.Fprintf(w, "%3d\n%s\n", code, value)
+}
+
+func (w *dxfWriter) integer(code, value int) {
+	w.pair(code, strconv.Itoa(value))
+}
+
+func (w *dxfWriter) number(code int, value float64) {
+	w.pair(code, strconv.FormatFloat(value, 'f', -1, 64))
+}
+
+func (w *dxfWriter) section(name string) {
+	w.pair(0, "SECTION")
+	w.pair(2, name)
+}
+
+// point writes a 2D point with group codes code and code+10, at z = 0.
+func (w *dxfWriter) point(code int, x, y float64) {
+	w.number(code, x)
+	w.number(code+10, y)
+	w.number(code+20, 0)
+}
+
+// dxfText makes s safe for a single-line DXF string: line breaks become
+// spaces and non-ASCII characters use the \U+XXXX escape.
+func dxfText(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\r':
+			b.WriteByte(' ')
+		case r > 0x7e && r <= 0xffff:
+			fmt.Fprintf(&b, `\U+%04X`, r)
+		case r > 0xffff:
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,122 @@
+// Package export converts stored floorplans into formats that GIS, wayfinding
+// and CAD tools can consume.
+package export
+
+import "floorplan-whiteboard/models"
+
+// FeatureCollection is an IMDF-style GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature with the IMDF feature_type member.
+type Feature struct {
+	Type        string         `json:"type"`
+	ID          string         `json:"id"`
+	FeatureType string         `json:"feature_type"`
+	Geometry    Polygon        `json:"geometry"`
+	Properties  map[string]any `json:"properties"`
+}
+
+// Polygon is a GeoJSON polygon geometry.
+type Polygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// imdfCategories maps room types onto IMDF unit categories.
+var imdfCategories = map[models.RoomType]string{
+	models.RoomTypeOffice:  "office",
+	models.RoomTypeMeeting: "conferenceroom",
+	models.RoomTypeHallway: "walkway",
+}
+
+// GeoJSON converts fp into a feature collection with one "level" feature
+// covering the whole floorplan and one "unit" feature per room, outlined by
+// its polygon or rect and carrying its name, type, status and IMDF category,
+// and its size in meters when fp is calibrated.
+//
+// With a GeoTransform, coordinates are WGS 84 longitude and latitude;
+// otherwise they are cropped image pixels, x to the right and y down.
+func GeoJSON(fp *models.Floorplan) *FeatureCollection {
+	levelID := fp.ID + "-level"
+	fc := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{{
+		Type:        "Feature",
+		ID:          levelID,
+		FeatureType: "level",
+		Geometry:    polygon(fp.GeoTransform, []models.Point{{0, 0}, {fp.Width, 0}, {fp.Width, fp.Height}, {0, fp.Height}}),
+		Properties: map[string]any{
+			"name":          fp.Filename,
+			"category":      "unspecified",
+			"ordinal":       0,
+			"georeferenced": fp.GeoTransform != nil,
+		},
+	}}}
+
+	if fp.PixelsPerMeter > 0 {
+		fc.Features[0].Properties["pixels_per_meter"] = fp.PixelsPerMeter
+	}
+
+	for _, room := range fp.Rooms {
+		outline := room.Outline()
+		if outline == nil {
+			continue
+		}
+		category, ok := imdfCategories[room.Type]
+		if !ok {
+			category = "unspecified"
+		}
+		properties := map[string]any{
+			"name":      room.Name,
+			"type":      room.Type,
+			"status":    room.Status,
+			"occupancy": room.Occupancy,
+			"category":  category,
+			"level_id":  levelID,
+		}
+		if fp.PixelsPerMeter > 0 {
+			room.Measure(fp.PixelsPerMeter)
+			properties["width_m"] = room.WidthM
+			properties["height_m"] = room.HeightM
+			properties["area_m2"] = room.AreaM2
+		}
+		fc.Features = append(fc.Features, Feature{
+			Type:        "Feature",
+			ID:          room.ID,
+			FeatureType: "unit",
+			Geometry:    polygon(fp.GeoTransform, outline),
+			Properties:  properties,
+		})
+	}
+	return fc
+}
+
+// polygon returns the closed ring through points mapped through t, wound
+// counterclockwise as RFC 7946 requires.
+func polygon(t *models.GeoTransform, points []models.Point) Polygon {
+	ring := make([][2]float64, 0, len(points)+1)
+	for i := 0; i <= len(points); i++ {
+		p := points[i%len(points)]
+		x, y := float64(p[0]), float64(p[1])
+		if t != nil {
+			x, y = t.Apply(x, y)
+		}
+		ring = append(ring, [2]float64{x, y})
+	}
+	if signedArea(ring) < 0 {
+		for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+			ring[i], ring[j] = ring[j], ring[i]
+		}
+	}
+	return Polygon{Type: "Polygon", Coordinates: [][][2]float64{ring}}
+}
+
+// signedArea is positive for counterclockwise rings.
+func signedArea(ring [][2]float64) float64 {
+	var sum float64
+	for i := 0; i < len(ring)-1; i++ {
+		sum += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return sum / 2
+}
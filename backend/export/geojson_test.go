@@ -0,0 +1,91 @@
+package export
+
+import (
+	"math"
+	"testing"
+
+	"floorplan-whiteboard/models"
+)
+
+func TestGeoJSON_Pixels(t *testing.T) {
+	fp := &models.Floorplan{ID: "fp", Filename: "plan.png", Width: 100, Height: 50, Rooms: []models.Room{
+		{ID: "a", Name: "Office 101", Type: models.RoomTypeOffice, Status: models.RoomStatusBusy, Rect: models.Rect{10, 20, 30, 15}},
+		{ID: "b", Name: "Broken", Rect: models.Rect{1, 2}},
+	}}
+	fc := GeoJSON(fp)
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 2 {
+		t.Fatalf("GeoJSON() = %+v, want a level and one unit", fc)
+	}
+	level, unit := fc.Features[0], fc.Features[1]
+	if level.FeatureType != "level" || level.Properties["georeferenced"] != false {
+		t.Errorf("level = %+v", level)
+	}
+	if unit.ID != "a" || unit.FeatureType != "unit" || unit.Properties["category"] != "office" ||
+		unit.Properties["status"] != models.RoomStatusBusy || unit.Properties["level_id"] != level.ID {
+		t.Errorf("unit = %+v", unit)
+	}
+
+	ring := unit.Geometry.Coordinates[0]
+	if len(ring) != 5 || ring[0] != ring[4] {
+		t.Fatalf("ring = %v, want a closed rectangle", ring)
+	}
+	if signedArea(ring) != 30*15 {
+		t.Errorf("ring = %v, want counterclockwise with the room's area", ring)
+	}
+}
+
+func TestGeoJSON_Georeferenced(t *testing.T) {
+	// 1e-5 degrees per pixel, image y pointing south.
+	transform := models.GeoTransform{1e-5, 0, 100.5, 0, -1e-5, 13.75}
+	fp := &models.Floorplan{ID: "fp", Width: 100, Height: 50, GeoTransform: &transform, Rooms: []models.Room{
+		{ID: "a", Rect: models.Rect{0, 0, 10, 10}},
+	}}
+	ring := GeoJSON(fp).Features[1].Geometry.Coordinates[0]
+
+	if signedArea(ring) <= 0 {
+		t.Errorf("ring = %v, want counterclockwise after the y flip", ring)
+	}
+	minLon, maxLat := math.Inf(1), math.Inf(-1)
+	for _, p := range ring {
+		minLon, maxLat = math.Min(minLon, p[0]), math.Max(maxLat, p[1])
+	}
+	if math.Abs(minLon-100.5) > 1e-9 || math.Abs(maxLat-13.75) > 1e-9 {
+		t.Errorf("ring = %v, want the top-left corner at the transform origin", ring)
+	}
+}
+
+func TestGeoJSON_Polygon(t *testing.T) {
+	polygon := []models.Point{{0, 0}, {0, 20}, {20, 20}, {20, 10}, {10, 10}, {10, 0}} // Clockwise in y-up coordinates
+	fp := &models.Floorplan{ID: "fp", Width: 100, Height: 50, Rooms: []models.Room{
+		{ID: "l", Rect: models.Rect{0, 0, 20, 20}, Polygon: polygon},
+	}}
+	ring := GeoJSON(fp).Features[1].Geometry.Coordinates[0]
+	if len(ring) != 7 || ring[0] != ring[6] {
+		t.Fatalf("ring = %v, want the closed polygon", ring)
+	}
+	if signedArea(ring) != 300 {
+		t.Errorf("ring = %v, want counterclockwise with the L's area", ring)
+	}
+	if polygon[0] != (models.Point{0, 0}) || len(polygon) != 6 {
+		t.Errorf("GeoJSON modified the room's polygon: %v", polygon)
+	}
+}
+
+func TestGeoJSON_Calibrated(t *testing.T) {
+	fp := &models.Floorplan{ID: "fp", Width: 100, Height: 50, PixelsPerMeter: 10, Rooms: []models.Room{
+		{ID: "a", Rect: models.Rect{10, 20, 30, 15}},
+	}}
+	fc := GeoJSON(fp)
+	if fc.Features[0].Properties["pixels_per_meter"] != 10.0 {
+		t.Errorf("level = %+v", fc.Features[0])
+	}
+	unit := fc.Features[1]
+	if unit.Properties["width_m"] != 3.0 || unit.Properties["height_m"] != 1.5 || unit.Properties["area_m2"] != 4.5 {
+		t.Errorf("unit = %+v, want 3 x 1.5 m", unit)
+	}
+
+	fp.PixelsPerMeter = 0
+	if _, ok := GeoJSON(fp).Features[1].Properties["area_m2"]; ok {
+		t.Error("uncalibrated unit has an area")
+	}
+}
@@ -0,0 +1,56 @@
+package export
+
+import (
+	"sort"
+
+	"floorplan-whiteboard/models"
+)
+
+// layer groups the rooms of one RoomType in CAD exports.
+type layer struct {
+	name  string
+	color int    // AutoCAD color index
+	hex   string // SVG stroke color
+	rooms []models.Room
+}
+
+// layerStyles are the colors of the known room types; other types are drawn
+// in white/black (ACI 7).
+var layerStyles = map[models.RoomType]struct {
+	color int
+	hex   string
+}{
+	models.RoomTypeOffice:  {5, "#3498db"},
+	models.RoomTypeMeeting: {6, "#9b59b6"},
+	models.RoomTypeHallway: {30, "#e67e22"},
+	models.RoomTypeUnknown: {8, "#95a5a6"},
+}
+
+// layers groups the rooms of fp with a rect by type, sorted by layer name.
+// Rooms without a type go on the UNKNOWN layer.
+func layers(fp *models.Floorplan) []*layer {
+	byName := make(map[string]*layer)
+	var list []*layer
+	for _, room := range fp.Rooms {
+		if len(room.Rect) != 4 {
+			continue
+		}
+		roomType := room.Type
+		if roomType == "" {
+			roomType = models.RoomTypeUnknown
+		}
+		l, ok := byName[string(roomType)]
+		if !ok {
+			style, known := layerStyles[roomType]
+			if !known {
+				style.color, style.hex = 7, "#000000"
+			}
+			l = &layer{name: string(roomType), color: style.color, hex: style.hex}
+			byName[l.name] = l
+			list = append(list, l)
+		}
+		l.rooms = append(l.rooms, room)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
@@ -0,0 +1,89 @@
+package export
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"floorplan-whiteboard/models"
+)
+
+// SVGOptions controls how SVG draws a floorplan.
+type SVGOptions struct {
+	// PixelsPerMeter converts the cropped image coordinates rooms are stored
+	// in to meters. 0 draws in image pixels instead.
+	PixelsPerMeter float64
+	// Measured adds each room's size and area in meters, as data-width-m,
+	// data-height-m and data-area-m2 attributes. Leave it off when
+	// PixelsPerMeter is only an assumed scale.
+	Measured bool
+	// IncludeImage embeds the stored floorplan image underneath the rooms.
+	IncludeImage bool
+	// RoomColor, when set, colors each room ("#rrggbb") instead of its layer.
+	RoomColor func(models.Room) string
+}
+
+// SVG converts fp into an SVG drawing with one group per room type holding
+// each room's polygon or rectangle and name. In meters, the document's
+// physical size is set in millimeters so CAD and vector tools import it at
+// 1:1 scale; in pixels, it matches the stored image, for overlays.
+func SVG(fp *models.Floorplan, opts SVGOptions) []byte {
+	// Drawing units per pixel, and the document size per drawing unit.
+	scale, size, unit := 1.0, 1.0, ""
+	strokeWidth, fontSize := 2.0, 12.0
+	if opts.PixelsPerMeter > 0 {
+		scale, size, unit = 1/opts.PixelsPerMeter, 1000, "mm"
+		strokeWidth, fontSize = 0.05, 0.3
+	}
+	m := func(px float64) string { return decimal(px * scale) }
+	width, height := float64(fp.Width)*scale, float64(fp.Height)*scale
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%s%s" height="%s%s" viewBox="0 0 %s %s">`+"\n",
+		decimal(width*size), unit, decimal(height*size), unit, decimal(width), decimal(height))
+	if opts.IncludeImage && fp.ImageURL != "" {
+		fmt.Fprintf(&b, `<image id="floorplan" href="%s" x="0" y="0" width="%s" height="%s" preserveAspectRatio="none"/>`+"\n",
+			html.EscapeString(fp.ImageURL), decimal(width), decimal(height))
+	}
+
+	for _, l := range layers(fp) {
+		fmt.Fprintf(&b, `<g id="%s" fill="%s" fill-opacity="0.15" stroke="%s" stroke-width="%s">`+"\n",
+			html.EscapeString(l.name), l.hex, l.hex, decimal(strokeWidth))
+		for _, room := range l.rooms {
+			x, y, w, h := float64(room.Rect[0]), float64(room.Rect[1]), float64(room.Rect[2]), float64(room.Rect[3])
+			fmt.Fprintf(&b, `<g id="%s"`, html.EscapeString(room.ID))
+			if opts.RoomColor != nil {
+				c := html.EscapeString(opts.RoomColor(room))
+				fmt.Fprintf(&b, ` fill="%s" stroke="%s"`, c, c)
+			}
+			if opts.Measured {
+				room.Measure(opts.PixelsPerMeter)
+				fmt.Fprintf(&b, ` data-width-m="%s" data-height-m="%s" data-area-m2="%s"`,
+					decimal(room.WidthM), decimal(room.HeightM), decimal(room.AreaM2))
+			}
+			b.WriteString(">")
+			if len(room.Polygon) >= 3 {
+				points := make([]string, len(room.Polygon))
+				for i, p := range room.Polygon {
+					points[i] = m(float64(p[0])) + "," + m(float64(p[1]))
+				}
+				fmt.Fprintf(&b, `<polygon points="%s"/>`, strings.Join(points, " "))
+			} else {
+				fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s"/>`, m(x), m(y), m(w), m(h))
+			}
+			// The white halo keeps labels legible over the image.
+			fmt.Fprintf(&b, `<text x="%s" y="%s" text-anchor="middle" dominant-baseline="middle" font-family="sans-serif" font-size="%s" fill="#212121" fill-opacity="1" stroke="#ffffff" stroke-width="%s" paint-order="stroke">%s</text></g>`+"\n",
+				m(x+w/2), m(y+h/2), decimal(fontSize), decimal(strokeWidth*1.5), html.EscapeString(room.Label()))
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// decimal formats v with three decimals (millimeters, for lengths in meters)
+// and no trailing zeros.
+func decimal(v float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.3f", v), "0")
+	return strings.TrimSuffix(s, ".")
+}
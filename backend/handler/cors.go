@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"net/http"
+
+	"floorplan-whiteboard/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORS returns middleware that allows cross-origin requests from
+// cfg.AllowedOrigins and answers preflight requests. With "*" in the list any
+// origin may call the API, but without credentials: browsers refuse a
+// wildcard combined with Access-Control-Allow-Credentials.
+func CORS(cfg config.ServerConfig) gin.HandlerFunc {
+	anyOrigin := cfg.AllowsAnyOrigin()
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		if anyOrigin {
+			header.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			header.Add("Vary", "Origin")
+			if origin := c.Request.Header.Get("Origin"); origin != "" && cfg.AllowsOrigin(origin) {
+				header.Set("Access-Control-Allow-Origin", origin)
+				header.Set("Access-Control-Allow-Credentials", "true")
+			}
+		}
+		header.Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		header.Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+
+		if c.Request.Method == http.MethodOptions {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"floorplan-whiteboard/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	request := func(origins []string, origin string) http.Header {
+		r := gin.New()
+		r.Use(CORS(config.ServerConfig{AllowedOrigins: origins}))
+		r.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Header()
+	}
+
+	h := request([]string{"*"}, "https://any.example.com")
+	if h.Get("Access-Control-Allow-Origin") != "*" || h.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("wildcard headers = %v, want literal * without credentials", h)
+	}
+
+	h = request([]string{"https://twin.example.com"}, "https://twin.example.com")
+	if h.Get("Access-Control-Allow-Origin") != "https://twin.example.com" || h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Vary") != "Origin" {
+		t.Errorf("listed origin headers = %v, want the origin echoed with credentials", h)
+	}
+
+	h = request([]string{"https://twin.example.com"}, "https://evil.example.com")
+	if h.Get("Access-Control-Allow-Origin") != "" || h.Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("unlisted origin headers = %v, want no CORS grant", h)
+	}
+}
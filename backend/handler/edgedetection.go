@@ -18,6 +18,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// EdgeDetectionHandler serves the stateless image-processing endpoints.
+type EdgeDetectionHandler struct {
+	defaults ai.EdgeDetectionOptions
+}
+
+// NewEdgeDetectionHandler creates a handler that starts from the given default
+// options and applies per-request overrides on top.
+func NewEdgeDetectionHandler(defaults ai.EdgeDetectionOptions) *EdgeDetectionHandler {
+	return &EdgeDetectionHandler{defaults: defaults}
+}
+
 // EdgeDetectionRequest contains parameters for edge detection
 type EdgeDetectionRequest struct {
 	BlurRadius     float64 `json:"blur_radius" default:"1.2"`
@@ -60,7 +71,7 @@ type CropFloorplanResponse struct {
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/process/edges [post]
-func ProcessFloorplanEdges(c *gin.Context) {
+func (h *EdgeDetectionHandler) ProcessFloorplanEdges(c *gin.Context) {
 	// 1. Get file from request
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
@@ -93,7 +104,7 @@ func ProcessFloorplanEdges(c *gin.Context) {
 	}
 
 	// 4. Parse edge detection options from query params
-	opts := ai.DefaultEdgeDetectionOptions()
+	opts := h.defaults
 
 	// Allow optional override of parameters via query string
 	if blurStr := c.Query("blur_radius"); blurStr != "" {
@@ -152,7 +163,7 @@ func ProcessFloorplanEdges(c *gin.Context) {
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/process/edges-json [post]
-func ProcessFloorplanWithJSON(c *gin.Context) {
+func (h *EdgeDetectionHandler) ProcessFloorplanWithJSON(c *gin.Context) {
 	// Parse JSON body for base64 image
 	var req struct {
 		Image   string                `json:"image" binding:"required"` // base64 or data:image/...
@@ -179,7 +190,7 @@ func ProcessFloorplanWithJSON(c *gin.Context) {
 	}
 
 	// Build options
-	opts := ai.DefaultEdgeDetectionOptions()
+	opts := h.defaults
 	if req.Options != nil {
 		if req.Options.BlurRadius > 0 {
 			opts.BlurRadius = req.Options.BlurRadius
@@ -239,6 +250,34 @@ func detectFloorplanEdges(img image.Image, opts ai.EdgeDetectionOptions) (image.
 	return edgeImg, nil
 }
 
+// mainContentRect finds the main content (floorplan) in edgeImg, the edge map
+// of img, and returns its bounding box in img's pixels.
+func mainContentRect(img, edgeImg image.Image) image.Rectangle {
+	// Use GetMainContentBoundingBox which uses dilation to group nearby objects (walls)
+	// This helps detect the full floorplan area rather than just a single wall
+	cropRect := ai.GetMainContentBoundingBox(edgeImg)
+
+	// Scale cropRect to original image size
+	// edgeImg might be resized if ResizeMaxWidth was set
+	origBounds := img.Bounds()
+	edgeBounds := edgeImg.Bounds()
+
+	// Calculate scale factors
+	scaleX := float64(origBounds.Dx()) / float64(edgeBounds.Dx())
+	scaleY := float64(origBounds.Dy()) / float64(edgeBounds.Dy())
+
+	// Scale the rectangle
+	finalRect := image.Rect(
+		int(float64(cropRect.Min.X)*scaleX),
+		int(float64(cropRect.Min.Y)*scaleY),
+		int(float64(cropRect.Max.X)*scaleX),
+		int(float64(cropRect.Max.Y)*scaleY),
+	)
+
+	// Ensure the rectangle is within the original image bounds
+	return finalRect.Intersect(origBounds)
+}
+
 // CropFloorplanHandler godoc
 // @Summary Automatic crop floorplan from paper
 // @Description Detect and crop the floorplan area from a paper document image
@@ -251,7 +290,7 @@ func detectFloorplanEdges(img image.Image, opts ai.EdgeDetectionOptions) (image.
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/process/crop [post]
-func CropFloorplanHandler(c *gin.Context) {
+func (h *EdgeDetectionHandler) CropFloorplanHandler(c *gin.Context) {
 	var req CropFloorplanRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -274,7 +313,7 @@ func CropFloorplanHandler(c *gin.Context) {
 	}
 
 	// Build edge detection options
-	opts := ai.DefaultEdgeDetectionOptions()
+	opts := h.defaults
 	if req.Options != nil {
 		if req.Options.BlurRadius > 0 {
 			opts.BlurRadius = req.Options.BlurRadius
@@ -300,30 +339,7 @@ func CropFloorplanHandler(c *gin.Context) {
 	}
 
 	// ========== AI PROCESSING STEP 2: CROP CONTENT ==========
-	// Find the bounding box of the content in the edge image
-	// Use GetMainContentBoundingBox which uses dilation to group nearby objects (walls)
-	// This helps detect the full floorplan area rather than just a single wall
-	cropRect := ai.GetMainContentBoundingBox(edgeImg)
-
-	// Scale cropRect to original image size
-	// edgeImg might be resized if ResizeMaxWidth was set
-	origBounds := img.Bounds()
-	edgeBounds := edgeImg.Bounds()
-
-	// Calculate scale factors
-	scaleX := float64(origBounds.Dx()) / float64(edgeBounds.Dx())
-	scaleY := float64(origBounds.Dy()) / float64(edgeBounds.Dy())
-
-	// Scale the rectangle
-	finalRect := image.Rect(
-		int(float64(cropRect.Min.X)*scaleX),
-		int(float64(cropRect.Min.Y)*scaleY),
-		int(float64(cropRect.Max.X)*scaleX),
-		int(float64(cropRect.Max.Y)*scaleY),
-	)
-
-	// Ensure the rectangle is within the original image bounds
-	finalRect = finalRect.Intersect(origBounds)
+	finalRect := mainContentRect(img, edgeImg)
 
 	// Crop the original image
 	cropped := ai.CropImage(img, finalRect)
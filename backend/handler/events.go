@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/realtime"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventsHandler streams realtime updates as server-sent events, for clients
+// behind proxies that block websocket upgrades.
+type EventsHandler struct {
+	hub *realtime.Hub
+	cfg config.RealtimeConfig
+}
+
+// NewEventsHandler creates an EventsHandler fed by hub. cfg supplies the
+// keepalive interval and write timeout.
+func NewEventsHandler(hub *realtime.Hub, cfg config.RealtimeConfig) *EventsHandler {
+	return &EventsHandler{hub: hub, cfg: cfg}
+}
+
+// StreamEvents godoc
+// @Summary Stream a floorplan's live updates
+// @Description Server-sent events carrying the same JSON messages as the websocket, named by their type: a subscribed acknowledgement, a snapshot of the rooms' statuses, then status_update messages and broadcasts. Snapshots and status updates have their seq as event ID, so a reconnecting client sending Last-Event-ID gets the updates it missed.
+// @ID streamFloorplanEvents
+// @Tags status
+// @Produce text/event-stream
+// @Param id path string true "Floorplan ID"
+// @Param Last-Event-ID header string false "Seq of the last event received"
+// @Success 200 {string} string "Event stream"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/events [get]
+func (h *EventsHandler) StreamEvents(c *gin.Context) {
+	var lastSeq *uint64
+	if raw := c.GetHeader("Last-Event-ID"); raw != "" {
+		seq, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid Last-Event-ID %q", raw)})
+			return
+		}
+		lastSeq = &seq
+	}
+
+	sub, err := h.hub.Subscribe(c.Param("id"), lastSeq, c.ClientIP())
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floorplan not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to subscribe: %v", err)})
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+
+	rc := http.NewResponseController(c.Writer)
+	send := func(frame string) bool {
+		rc.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout))
+		if _, err := io.WriteString(c.Writer, frame); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	// Commit the headers right away so clients see the stream open.
+	if !send(": connected\n\n") {
+		return
+	}
+
+	ticker := time.NewTicker(h.cfg.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case data := <-sub.Messages():
+			frame, err := formatEvent(data)
+			if err != nil || !send(frame) {
+				return
+			}
+		case <-ticker.C:
+			if !send(": ping\n\n") {
+				return
+			}
+		case <-sub.Done():
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// formatEvent frames one hub message as a server-sent event named after its
+// type, with its seq, if any, as the event ID.
+func formatEvent(data []byte) (string, error) {
+	var header struct {
+		Type string  `json:"type"`
+		Seq  *uint64 `json:"seq"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return "", err
+	}
+	id := ""
+	if header.Seq != nil {
+		id = fmt.Sprintf("id: %d\n", *header.Seq)
+	}
+	return fmt.Sprintf("%sevent: %s\ndata: %s\n\n", id, header.Type, data), nil
+}
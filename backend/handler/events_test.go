@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/realtime"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type sseEvent struct {
+	id, event, data string
+}
+
+func newEventsServer(t *testing.T) (*httptest.Server, *realtime.Hub, store.FloorplanRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	cfg := config.Default().Realtime
+	hub, err := realtime.NewHub(cfg, repo, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.GET("/floorplans/:id/events", NewEventsHandler(hub, cfg).StreamEvents)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return srv, hub, repo
+}
+
+// openStream starts an event stream and returns a function reading the next
+// event, skipping comments.
+func openStream(t *testing.T, url, lastEventID string) func() sseEvent {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s error = %v", url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("status = %d, content type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	return func() sseEvent {
+		t.Helper()
+		var ev sseEvent
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v", err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && ev.event != "":
+				return ev
+			case strings.HasPrefix(line, "id: "):
+				ev.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				ev.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				ev.data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	srv, hub, repo := newEventsServer(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	roomID := fp.Rooms[0].ID
+	url := srv.URL + "/floorplans/" + fp.ID + "/events"
+
+	next := openStream(t, url, "")
+	if ev := next(); ev.event != realtime.MessageSubscribed {
+		t.Fatalf("first event = %+v, want subscribed", ev)
+	}
+	if ev := next(); ev.event != realtime.MessageSnapshot || ev.id != "0" || !strings.Contains(ev.data, `"room_id":"`+roomID+`"`) {
+		t.Errorf("second event = %+v, want snapshot", ev)
+	}
+
+	hub.PublishStatus(realtime.StatusUpdate{FloorplanID: fp.ID, RoomID: roomID, Status: models.RoomStatusBusy})
+	hub.PublishStatus(realtime.StatusUpdate{FloorplanID: fp.ID, RoomID: roomID, Status: models.RoomStatusOffline})
+	ev := next()
+	if ev.event != realtime.MessageStatusUpdate || ev.id != "1" || !strings.Contains(ev.data, `"status":"BUSY"`) {
+		t.Errorf("event = %+v, want status_update 1", ev)
+	}
+
+	// Resuming after 1 replays 2 without a snapshot.
+	resumed := openStream(t, url, "1")
+	resumed() // subscribed
+	if ev := resumed(); ev.event != realtime.MessageStatusUpdate || ev.id != "2" || !strings.Contains(ev.data, `"status":"OFFLINE"`) {
+		t.Errorf("resumed event = %+v, want replayed status_update 2", ev)
+	}
+}
+
+func TestStreamEvents_Errors(t *testing.T) {
+	srv, _, repo := newEventsServer(t)
+	fp := seedFloorplan(t, repo, time.Now())
+
+	resp, err := http.Get(srv.URL + "/floorplans/missing/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing floorplan status = %d, want 404", resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/floorplans/"+fp.ID+"/events", nil)
+	req.Header.Set("Last-Event-ID", "abc")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("bad Last-Event-ID status = %d, want 400", resp.StatusCode)
+	}
+}
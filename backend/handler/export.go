@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/export"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler serves floorplans in formats for GIS, wayfinding and CAD tools.
+type ExportHandler struct {
+	floorplans *FloorplanHandler
+	cfg        config.ExportConfig
+}
+
+// NewExportHandler creates an ExportHandler that loads floorplans through
+// floorplans and scales CAD exports by the floorplan's calibrated scale, or
+// cfg.PixelsPerMeter for uncalibrated floorplans, unless a request overrides it.
+func NewExportHandler(floorplans *FloorplanHandler, cfg config.ExportConfig) *ExportHandler {
+	return &ExportHandler{floorplans: floorplans, cfg: cfg}
+}
+
+// ExportFloorplan godoc
+// @Summary Export a floorplan
+// @Description Export a floorplan for GIS, wayfinding and CAD tools.
+// @Description geojson returns an IMDF-style feature collection with a level feature for the floorplan and a unit polygon per room (name, type, status, category, and width_m, height_m and area_m2 once the floorplan is calibrated); coordinates are WGS 84 when the floorplan has a geo_transform and cropped image pixels otherwise.
+// @Description svg and dxf return the rooms in meters, scaled by pixels_per_meter, grouped in layers named by room type and labeled with their names, and with their areas once the floorplan is calibrated or pixels_per_meter is given; svg can embed the floorplan image underneath.
+// @ID exportFloorplan
+// @Tags floorplans
+// @Produce json
+// @Produce image/svg+xml
+// @Produce image/vnd.dxf
+// @Param id path string true "Floorplan ID"
+// @Param format query string false "Export format: geojson, svg or dxf (default: geojson)"
+// @Param pixels_per_meter query number false "Image scale for svg and dxf (default: the floorplan's calibrated scale, else export.pixels_per_meter)"
+// @Param include_image query boolean false "Embed the floorplan image in svg exports (default: false)"
+// @Success 200 {object} export.FeatureCollection "GeoJSON feature collection, or an SVG or DXF file"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/export [get]
+func (h *ExportHandler) ExportFloorplan(c *gin.Context) {
+	format := c.DefaultQuery("format", "geojson")
+	if format != "geojson" && format != "svg" && format != "dxf" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be geojson, svg or dxf"})
+		return
+	}
+	var pixelsPerMeter float64
+	if raw := c.Query("pixels_per_meter"); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || !(v > 0) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "pixels_per_meter must be a positive number"})
+			return
+		}
+		pixelsPerMeter = v
+	}
+	includeImage, err := strconv.ParseBool(c.DefaultQuery("include_image", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "include_image must be true or false"})
+		return
+	}
+	fp, ok := h.floorplans.loadFloorplan(c)
+	if !ok {
+		return
+	}
+	if pixelsPerMeter == 0 {
+		pixelsPerMeter = fp.PixelsPerMeter
+	}
+	// The configured default only lays the drawing out; sizes at an assumed
+	// scale would be made up.
+	measured := pixelsPerMeter > 0
+	if !measured {
+		pixelsPerMeter = h.cfg.PixelsPerMeter
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+fp.ID+`.`+format+`"`)
+	switch format {
+	case "svg":
+		c.Data(http.StatusOK, "image/svg+xml", export.SVG(fp, export.SVGOptions{PixelsPerMeter: pixelsPerMeter, Measured: measured, IncludeImage: includeImage}))
+	case "dxf":
+		c.Data(http.StatusOK, "image/vnd.dxf", export.DXF(fp, pixelsPerMeter, measured))
+	default:
+		c.Render(http.StatusOK, geoJSON{export.GeoJSON(fp)})
+	}
+}
+
+// geoJSON renders JSON with the application/geo+json content type.
+type geoJSON struct {
+	data any
+}
+
+func (r geoJSON) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return json.NewEncoder(w).Encode(r.data)
+}
+
+func (r geoJSON) WriteContentType(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/geo+json")
+}
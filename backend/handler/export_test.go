@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/export"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newExportRouter(t *testing.T) (*gin.Engine, store.FloorplanRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	repo := store.NewMemoryRepository()
+	floorplans := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": ai.StaticDetector{}}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	h := NewExportHandler(floorplans, config.Default().Export)
+
+	r := gin.New()
+	r.PUT("/floorplans/:id", floorplans.UpdateFloorplan)
+	r.GET("/floorplans/:id/export", h.ExportFloorplan)
+	return r, repo
+}
+
+func TestExportFloorplan_GeoJSON(t *testing.T) {
+	r, repo := newExportRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+
+	transform := models.GeoTransform{1e-5, 0, 100.5, 0, -1e-5, 13.75}
+	w := doJSON(r, http.MethodPut, "/floorplans/"+fp.ID, UpdateFloorplanRequest{Filename: fp.Filename, GeoTransform: &transform})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, body = %s", w.Code, w.Body.String())
+	}
+	if stored, _ := repo.Get(context.Background(), fp.ID); stored.GeoTransform == nil || *stored.GeoTransform != transform {
+		t.Fatalf("stored geo_transform = %v", stored.GeoTransform)
+	}
+
+	w = doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/export?format=geojson", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/geo+json" {
+		t.Fatalf("export status = %d, content type = %q, body = %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	var fc export.FeatureCollection
+	if err := json.Unmarshal(w.Body.Bytes(), &fc); err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 2 || fc.Features[1].Properties["name"] != "Office 101" || fc.Features[0].Properties["georeferenced"] != true {
+		t.Errorf("features = %+v", fc.Features)
+	}
+	if lon := fc.Features[1].Geometry.Coordinates[0][0][0]; lon < 100 || lon > 101 {
+		t.Errorf("unit longitude = %v, want georeferenced coordinates", lon)
+	}
+
+	singular := models.GeoTransform{1, 2, 0, 2, 4, 0}
+	for _, tc := range []struct {
+		method, path string
+		body         interface{}
+		want         int
+	}{
+		{http.MethodPut, "/floorplans/" + fp.ID, UpdateFloorplanRequest{Filename: "x", GeoTransform: &singular}, http.StatusBadRequest},
+		{http.MethodGet, "/floorplans/" + fp.ID + "/export?format=kml", nil, http.StatusBadRequest},
+		{http.MethodGet, "/floorplans/" + fp.ID + "/export?format=dxf&pixels_per_meter=0", nil, http.StatusBadRequest},
+		{http.MethodGet, "/floorplans/" + fp.ID + "/export?format=svg&include_image=maybe", nil, http.StatusBadRequest},
+		{http.MethodGet, "/floorplans/missing/export", nil, http.StatusNotFound},
+	} {
+		if w := doJSON(r, tc.method, tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.path, w.Code, tc.want)
+		}
+	}
+}
+
+func TestExportFloorplan_CAD(t *testing.T) {
+	r, repo := newExportRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+
+	w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/export?format=svg&pixels_per_meter=10&include_image=true", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("SVG status = %d, content type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{`<g id="OFFICE"`, `<rect x="0" y="0" width="1" height="1"/>`, "Office 101", `<image id="floorplan"`} {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("SVG does not contain %s:\n%s", want, w.Body.String())
+		}
+	}
+
+	w = doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/export?format=dxf", nil)
+	if w.Code != http.StatusOK || !strings.Contains(w.Header().Get("Content-Disposition"), fp.ID+".dxf") {
+		t.Fatalf("DXF status = %d, headers = %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), "LAYER\n  2\nOFFICE\n") {
+		t.Errorf("DXF has no OFFICE layer:\n%s", w.Body.String())
+	}
+	// The floorplan is uncalibrated, so the default scale gives no areas.
+	if strings.Contains(w.Body.String(), " m2\n") {
+		t.Errorf("DXF prints areas at the default scale:\n%s", w.Body.String())
+	}
+	w = doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/export?format=dxf&pixels_per_meter=10", nil)
+	if !strings.Contains(w.Body.String(), " m2\n") {
+		t.Errorf("DXF has no areas at a requested scale:\n%s", w.Body.String())
+	}
+}
+
+func TestExportFloorplan_CalibratedScale(t *testing.T) {
+	r, repo := newExportRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	fp.PixelsPerMeter = 5
+	if err := repo.Update(context.Background(), fp); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/export?format=svg", nil)
+	if !strings.Contains(w.Body.String(), `<rect x="0" y="0" width="2" height="2"/>`) || !strings.Contains(w.Body.String(), `data-area-m2="4"`) {
+		t.Errorf("SVG is not at the calibrated scale:\n%s", w.Body.String())
+	}
+	w = doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/export?format=svg&pixels_per_meter=10", nil)
+	if !strings.Contains(w.Body.String(), `<rect x="0" y="0" width="1" height="1"/>`) {
+		t.Errorf("SVG ignores the pixels_per_meter override:\n%s", w.Body.String())
+	}
+}
@@ -0,0 +1,612 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// FloorplanListResponse is a single page of stored floorplans.
+// Items omit image_url to keep the payload small; fetch a floorplan by ID for its image.
+type FloorplanListResponse struct {
+	Items    []models.Floorplan `json:"items"`
+	Total    int                `json:"total"`
+	Page     int                `json:"page"`
+	PageSize int                `json:"page_size"`
+}
+
+// UpdateFloorplanRequest replaces the editable fields of a floorplan.
+// Omitting rooms or geo_transform keeps the stored value unchanged. Room IDs
+// must be unique; rooms that keep their ID keep their occupancy, and their
+// status must be omitted or unchanged (see errStatusEdit).
+type UpdateFloorplanRequest struct {
+	Filename     string               `json:"filename" binding:"required"`
+	Rooms        []models.Room        `json:"rooms"`
+	GeoTransform *models.GeoTransform `json:"geo_transform"`
+}
+
+// CalibrationRequest sets a floorplan's scale from two points a known
+// distance apart, such as the ends of a scale bar or of a dimensioned wall.
+type CalibrationRequest struct {
+	Start  models.Point `json:"start"` // [x, y] in cropped image pixels
+	End    models.Point `json:"end"`
+	Meters float64      `json:"meters" binding:"required,gt=0"` // Real distance between the points
+}
+
+// CreateRoomRequest adds a room that detection missed. Either rect or
+// polygon is required; a polygon's bounding box replaces rect.
+type CreateRoomRequest struct {
+	Name    string            `json:"name" binding:"required"`
+	Type    models.RoomType   `json:"type"`
+	Rect    models.Rect       `json:"rect"`
+	Polygon []models.Point    `json:"polygon"`
+	Status  models.RoomStatus `json:"status"`
+}
+
+// PatchRoomRequest corrects individual fields of a room; omitted fields are left unchanged.
+// Setting rect alone turns a polygon room back into a rectangle; an empty
+// polygon does the same while keeping rect.
+type PatchRoomRequest struct {
+	Name    *string            `json:"name"`
+	Type    *models.RoomType   `json:"type"`
+	Rect    models.Rect        `json:"rect"`
+	Polygon []models.Point     `json:"polygon"`
+	Status  *models.RoomStatus `json:"status"` // Must equal the current status (see errStatusEdit)
+}
+
+// errStatusEdit is returned when a room edit changes a room's status. Status
+// changes go through the status endpoints, which publish them to realtime
+// subscribers and record them in the history.
+const errStatusEdit = "status is changed with POST /api/v1/floorplans/{id}/rooms/{roomId}/status"
+
+// ListFloorplans godoc
+// @Summary List floorplans
+// @Description List stored floorplans, paginated and sorted by creation time
+// @ID listFloorplans
+// @Tags floorplans
+// @Produce json
+// @Param page query integer false "Page number (1-based)" default(1)
+// @Param page_size query integer false "Items per page (max 100)" default(20)
+// @Param sort query string false "Sort order: created_at or -created_at" default(-created_at)
+// @Success 200 {object} FloorplanListResponse "Page of floorplans"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans [get]
+func (h *FloorplanHandler) ListFloorplans(c *gin.Context) {
+	page, err := positiveQueryInt(c, "page", 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pageSize, err := positiveQueryInt(c, "page_size", defaultPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	descending := true
+	switch c.DefaultQuery("sort", "-created_at") {
+	case "created_at":
+		descending = false
+	case "-created_at":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be created_at or -created_at"})
+		return
+	}
+
+	// Pages past the end are empty; checking before multiplying keeps a huge
+	// page number from overflowing.
+	offset := math.MaxInt
+	if page-1 <= math.MaxInt/pageSize {
+		offset = (page - 1) * pageSize
+	}
+	items, total, err := h.repo.ListPage(c.Request.Context(), store.ListOptions{Offset: offset, Limit: pageSize, Descending: descending})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list floorplans: " + err.Error()})
+		return
+	}
+	for i := range items {
+		items[i].Measure()
+	}
+
+	c.JSON(http.StatusOK, FloorplanListResponse{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// GetFloorplan godoc
+// @Summary Get a floorplan
+// @Description Get a stored floorplan including its image and rooms
+// @ID getFloorplan
+// @Tags floorplans
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Success 200 {object} models.Floorplan "Floorplan"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id} [get]
+func (h *FloorplanHandler) GetFloorplan(c *gin.Context) {
+	fp, ok := h.loadFloorplan(c)
+	if !ok {
+		return
+	}
+	fp.Measure()
+	c.JSON(http.StatusOK, fp)
+}
+
+// GetFloorplanOverlay godoc
+// @Summary Render detected rooms over a floorplan
+// @Description Render the stored cropped image with every room outlined, color-coded by its type or status, and labeled with its name, for reviewing detection results
+// @ID getFloorplanOverlay
+// @Tags floorplans
+// @Produce png
+// @Produce image/svg+xml
+// @Param id path string true "Floorplan ID"
+// @Param format query string false "Output format: png or svg (default: png)"
+// @Param color_by query string false "Color rooms by type or status (default: type)"
+// @Success 200 {file} file "Annotated image"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/overlay [get]
+func (h *FloorplanHandler) GetFloorplanOverlay(c *gin.Context) {
+	format := c.DefaultQuery("format", "png")
+	if format != "png" && format != "svg" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be png or svg"})
+		return
+	}
+	colorBy := OverlayColorBy(c.DefaultQuery("color_by", string(ColorByType)))
+	if colorBy != ColorByType && colorBy != ColorByStatus {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "color_by must be type or status"})
+		return
+	}
+	fp, ok := h.loadFloorplan(c)
+	if !ok {
+		return
+	}
+
+	if format == "svg" {
+		c.Data(http.StatusOK, "image/svg+xml", RenderOverlaySVG(fp, colorBy))
+		return
+	}
+	data, err := RenderOverlayPNG(fp, colorBy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render overlay: " + err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", data)
+}
+
+// UpdateFloorplan godoc
+// @Summary Update a floorplan
+// @Description Replace the filename and (optionally) the full room list and georeferencing transform of a floorplan. Room IDs must be unique. Rooms that keep their ID keep their occupancy, and their status must be omitted or unchanged: status is changed with POST /api/v1/floorplans/{id}/rooms/{roomId}/status.
+// @ID updateFloorplan
+// @Tags floorplans
+// @Accept json
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param request body UpdateFloorplanRequest true "Updated floorplan fields"
+// @Success 200 {object} models.Floorplan "Updated floorplan"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id} [put]
+func (h *FloorplanHandler) UpdateFloorplan(c *gin.Context) {
+	var req UpdateFloorplanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	if req.GeoTransform != nil && !req.GeoTransform.Invertible() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "geo_transform must be invertible"})
+		return
+	}
+	seen := make(map[string]bool, len(req.Rooms))
+	statusOmitted := make([]bool, len(req.Rooms))
+	for i := range req.Rooms {
+		room := &req.Rooms[i]
+		statusOmitted[i] = room.Status == ""
+		if err := normalizeRoom(room); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid room %d: %v", i, err)})
+			return
+		}
+		if room.ID == "" {
+			room.ID = store.NewID()
+		}
+		if seen[room.ID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid room %d: duplicate id %q", i, room.ID)})
+			return
+		}
+		seen[room.ID] = true
+	}
+
+	fp, ok := h.modifyFloorplan(c, func(fp *models.Floorplan) bool {
+		fp.Filename = req.Filename
+		if req.GeoTransform != nil {
+			fp.GeoTransform = req.GeoTransform
+		}
+		if req.Rooms != nil {
+			stored := make(map[string]models.Room, len(fp.Rooms))
+			for _, room := range fp.Rooms {
+				stored[room.ID] = room
+			}
+			for i := range req.Rooms {
+				room := &req.Rooms[i]
+				if before, ok := stored[room.ID]; ok {
+					if statusOmitted[i] {
+						room.Status = before.Status
+					}
+					if room.Status != before.Status {
+						c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid room %d: %s", i, errStatusEdit)})
+						return false
+					}
+					room.Occupancy = before.Occupancy
+				}
+				room.FloorplanID = fp.ID
+			}
+			fp.Rooms = req.Rooms
+			pruneRoomLinks(fp)
+		}
+		return true
+	})
+	if !ok {
+		return
+	}
+	fp.Measure()
+	c.JSON(http.StatusOK, fp)
+}
+
+// CalibrateFloorplan godoc
+// @Summary Calibrate a floorplan's scale
+// @Description Set the floorplan's pixels_per_meter from two points on the cropped image and the real distance between them, e.g. the ends of a scale bar. Floorplan and room responses then include each room's width_m, height_m and area_m2, and svg and dxf exports use this scale by default.
+// @ID calibrateFloorplan
+// @Tags floorplans
+// @Accept json
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param request body CalibrationRequest true "Two points and their distance in meters"
+// @Success 200 {object} models.Floorplan "Calibrated floorplan"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/calibration [post]
+func (h *FloorplanHandler) CalibrateFloorplan(c *gin.Context) {
+	var req CalibrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	if req.Start == req.End {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start and end must be different points"})
+		return
+	}
+
+	fp, ok := h.modifyFloorplan(c, func(fp *models.Floorplan) bool {
+		fp.PixelsPerMeter = math.Hypot(float64(req.End[0]-req.Start[0]), float64(req.End[1]-req.Start[1])) / req.Meters
+		return true
+	})
+	if !ok {
+		return
+	}
+	fp.Measure()
+	c.JSON(http.StatusOK, fp)
+}
+
+// DeleteFloorplan godoc
+// @Summary Delete a floorplan
+// @Description Delete a stored floorplan and all of its rooms
+// @ID deleteFloorplan
+// @Tags floorplans
+// @Param id path string true "Floorplan ID"
+// @Success 204 "Deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id} [delete]
+func (h *FloorplanHandler) DeleteFloorplan(c *gin.Context) {
+	err := h.repo.Delete(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floorplan not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete floorplan: " + err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CreateRoom godoc
+// @Summary Add a room to a floorplan
+// @Description Add a room that was missed by detection
+// @ID createRoom
+// @Tags floorplans
+// @Accept json
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param request body CreateRoomRequest true "Room to add"
+// @Success 201 {object} models.Room "Created room"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/rooms [post]
+func (h *FloorplanHandler) CreateRoom(c *gin.Context) {
+	var req CreateRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	room := models.Room{
+		Name:    req.Name,
+		Type:    req.Type,
+		Rect:    req.Rect,
+		Polygon: req.Polygon,
+		Status:  req.Status,
+	}
+	if err := normalizeRoom(&room); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	room.ID = store.NewID()
+	fp, ok := h.modifyFloorplan(c, func(fp *models.Floorplan) bool {
+		room.FloorplanID = fp.ID
+		fp.Rooms = append(fp.Rooms, room)
+		return true
+	})
+	if !ok {
+		return
+	}
+	room.Measure(fp.PixelsPerMeter)
+	c.JSON(http.StatusCreated, room)
+}
+
+// PatchRoom godoc
+// @Summary Correct a room
+// @Description Update the name, type, rect or polygon of a detected room. Status is changed with POST /api/v1/floorplans/{id}/rooms/{roomId}/status; a status here must equal the current one.
+// @ID patchRoom
+// @Tags floorplans
+// @Accept json
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param roomId path string true "Room ID"
+// @Param request body PatchRoomRequest true "Fields to change"
+// @Success 200 {object} models.Room "Updated room"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/rooms/{roomId} [patch]
+func (h *FloorplanHandler) PatchRoom(c *gin.Context) {
+	var req PatchRoomRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	var room models.Room
+	fp, ok := h.modifyFloorplan(c, func(fp *models.Floorplan) bool {
+		idx, ok := findRoom(c, fp)
+		if !ok {
+			return false
+		}
+
+		room = fp.Rooms[idx]
+		if req.Name != nil {
+			room.Name = *req.Name
+		}
+		if req.Type != nil {
+			room.Type = *req.Type
+		}
+		if req.Rect != nil {
+			room.Rect = req.Rect
+			room.Polygon = nil
+		}
+		if req.Polygon != nil {
+			room.Polygon = req.Polygon
+		}
+		if req.Status != nil && *req.Status != room.Status {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errStatusEdit})
+			return false
+		}
+		if err := normalizeRoom(&room); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return false
+		}
+		fp.Rooms[idx] = room
+		return true
+	})
+	if !ok {
+		return
+	}
+	room.Measure(fp.PixelsPerMeter)
+	c.JSON(http.StatusOK, room)
+}
+
+// DeleteRoom godoc
+// @Summary Remove a room
+// @Description Remove a falsely detected room from a floorplan
+// @ID deleteRoom
+// @Tags floorplans
+// @Param id path string true "Floorplan ID"
+// @Param roomId path string true "Room ID"
+// @Success 204 "Deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/rooms/{roomId} [delete]
+func (h *FloorplanHandler) DeleteRoom(c *gin.Context) {
+	_, ok := h.modifyFloorplan(c, func(fp *models.Floorplan) bool {
+		idx, ok := findRoom(c, fp)
+		if !ok {
+			return false
+		}
+		fp.Rooms = append(fp.Rooms[:idx], fp.Rooms[idx+1:]...)
+		pruneRoomLinks(fp)
+		return true
+	})
+	if !ok {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// pruneRoomLinks drops the IDs of rooms that no longer exist from fp's doors
+// and windows.
+func pruneRoomLinks(fp *models.Floorplan) {
+	exists := make(map[string]bool, len(fp.Rooms))
+	for _, room := range fp.Rooms {
+		exists[room.ID] = true
+	}
+	prune := func(ids []string) []string {
+		out := ids[:0]
+		for _, id := range ids {
+			if exists[id] {
+				out = append(out, id)
+			}
+		}
+		return out
+	}
+	for i := range fp.Doors {
+		fp.Doors[i].RoomIDs = prune(fp.Doors[i].RoomIDs)
+	}
+	for i := range fp.Windows {
+		fp.Windows[i].RoomIDs = prune(fp.Windows[i].RoomIDs)
+	}
+}
+
+// loadFloorplan fetches the floorplan named by the :id path parameter,
+// writing a 404/500 response and returning false when it cannot.
+func (h *FloorplanHandler) loadFloorplan(c *gin.Context) (*models.Floorplan, bool) {
+	fp, err := h.repo.Get(c.Request.Context(), c.Param("id"))
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floorplan not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load floorplan: " + err.Error()})
+		return nil, false
+	}
+	return fp, true
+}
+
+// errEditAborted tells modifyFloorplan that the edit wrote its own response.
+var errEditAborted = errors.New("edit aborted")
+
+// modifyFloorplan applies edit to the floorplan named by the :id path
+// parameter and stores the result through the repository's Modify, so the
+// edit cannot interleave with status updates or other edits. edit writes an
+// error response and returns false to abort without storing anything. It
+// returns the stored floorplan, or writes a 404/500 response and returns
+// false.
+func (h *FloorplanHandler) modifyFloorplan(c *gin.Context, edit func(fp *models.Floorplan) bool) (*models.Floorplan, bool) {
+	fp, err := h.repo.Modify(c.Request.Context(), c.Param("id"), func(fp *models.Floorplan) error {
+		if !edit(fp) {
+			return errEditAborted
+		}
+		return nil
+	})
+	if errors.Is(err, errEditAborted) {
+		return nil, false
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floorplan not found"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save floorplan: " + err.Error()})
+		return nil, false
+	}
+	return fp, true
+}
+
+// findRoom returns the index of the room named by the :roomId path parameter.
+func findRoom(c *gin.Context, fp *models.Floorplan) (int, bool) {
+	roomID := c.Param("roomId")
+	for i := range fp.Rooms {
+		if fp.Rooms[i].ID == roomID {
+			return i, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+	return -1, false
+}
+
+// normalizeRoom validates a user-supplied room and fills in default type and status.
+func normalizeRoom(room *models.Room) error {
+	if room.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(room.Polygon) == 0 {
+		room.Polygon = nil
+	} else {
+		if len(room.Polygon) < 3 {
+			return errors.New("polygon must have at least 3 points")
+		}
+		for _, p := range room.Polygon {
+			if p[0] < 0 || p[1] < 0 {
+				return errors.New("polygon points must not be negative")
+			}
+		}
+		fitRect(room)
+	}
+	if len(room.Rect) != 4 {
+		return errors.New("rect must be [x, y, w, h]")
+	}
+	// Sizes in meters are computed for responses, never stored.
+	room.Measure(0)
+	if room.Rect[0] < 0 || room.Rect[1] < 0 || room.Rect[2] <= 0 || room.Rect[3] <= 0 {
+		return errors.New("rect must have non-negative origin and positive size")
+	}
+
+	switch room.Type {
+	case "":
+		room.Type = models.RoomTypeUnknown
+	case models.RoomTypeOffice, models.RoomTypeMeeting, models.RoomTypeHallway, models.RoomTypeUnknown:
+	default:
+		return fmt.Errorf("invalid room type %q", room.Type)
+	}
+
+	switch room.Status {
+	case "":
+		room.Status = models.RoomStatusAvailable
+	case models.RoomStatusAvailable, models.RoomStatusBusy, models.RoomStatusOffline:
+	default:
+		return fmt.Errorf("invalid room status %q", room.Status)
+	}
+	if room.Occupancy < 0 {
+		return errors.New("occupancy must not be negative")
+	}
+
+	return nil
+}
+
+// positiveQueryInt parses an optional positive integer query parameter.
+func positiveQueryInt(c *gin.Context, key string, fallback int) (int, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return fallback, nil
+	}
+	v, err := strconv.Atoi(raw)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("%s must be a positive integer", key)
+	}
+	return v, nil
+}
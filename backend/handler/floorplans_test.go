@@ -0,0 +1,359 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newTestRouter(t *testing.T) (*gin.Engine, store.FloorplanRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": ai.StaticDetector{}}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+
+	r := gin.New()
+	r.GET("/floorplans", h.ListFloorplans)
+	r.GET("/floorplans/:id", h.GetFloorplan)
+	r.GET("/floorplans/:id/overlay", h.GetFloorplanOverlay)
+	r.PUT("/floorplans/:id", h.UpdateFloorplan)
+	r.POST("/floorplans/:id/calibration", h.CalibrateFloorplan)
+	r.DELETE("/floorplans/:id", h.DeleteFloorplan)
+	r.POST("/floorplans/:id/rooms", h.CreateRoom)
+	r.PATCH("/floorplans/:id/rooms/:roomId", h.PatchRoom)
+	r.DELETE("/floorplans/:id/rooms/:roomId", h.DeleteRoom)
+	return r, repo
+}
+
+func doJSON(r http.Handler, method, path string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func seedFloorplan(t *testing.T, repo store.FloorplanRepository, createdAt time.Time) *models.Floorplan {
+	t.Helper()
+	fp := &models.Floorplan{
+		Filename:  "plan.png",
+		ImageURL:  "data:image/png;base64,AAAA",
+		CreatedAt: createdAt,
+		Rooms: []models.Room{
+			{Name: "Office 101", Type: models.RoomTypeOffice, Rect: models.Rect{0, 0, 10, 10}, Status: models.RoomStatusAvailable},
+		},
+	}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return fp
+}
+
+func TestListFloorplans_PaginatesNewestFirst(t *testing.T) {
+	r, repo := newTestRouter(t)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, seedFloorplan(t, repo, base.Add(time.Duration(i)*time.Hour)).ID)
+	}
+
+	w := doJSON(r, http.MethodGet, "/floorplans?page=1&page_size=2", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp FloorplanListResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if resp.Total != 3 || len(resp.Items) != 2 {
+		t.Fatalf("got total=%d items=%d, want 3 and 2", resp.Total, len(resp.Items))
+	}
+	if resp.Items[0].ID != ids[2] || resp.Items[1].ID != ids[1] {
+		t.Fatalf("items not sorted newest first")
+	}
+	if resp.Items[0].ImageURL != "" {
+		t.Fatalf("list items should omit image_url")
+	}
+
+	w = doJSON(r, http.MethodGet, "/floorplans?page=2&page_size=2&sort=created_at", nil)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Items) != 1 || resp.Items[0].ID != ids[2] {
+		t.Fatalf("ascending page 2 = %+v, want newest floorplan only", resp.Items)
+	}
+
+	if w := doJSON(r, http.MethodGet, "/floorplans?sort=name", nil); w.Code != http.StatusBadRequest {
+		t.Fatalf("unsupported sort status = %d, want 400", w.Code)
+	}
+
+	// Pages past the end are empty, even where page*page_size overflows.
+	for _, query := range []string{"page=3&page_size=2", "page=9223372036854775807&page_size=100"} {
+		w := doJSON(r, http.MethodGet, "/floorplans?"+query, nil)
+		resp = FloorplanListResponse{}
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if w.Code != http.StatusOK || resp.Total != 3 || len(resp.Items) != 0 {
+			t.Errorf("%s: status = %d, body = %s, want an empty page", query, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestUpdateAndDeleteFloorplan(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+
+	w := doJSON(r, http.MethodPut, "/floorplans/"+fp.ID, UpdateFloorplanRequest{Filename: "renamed.png"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, body = %s", w.Code, w.Body.String())
+	}
+	got, _ := repo.Get(context.Background(), fp.ID)
+	if got.Filename != "renamed.png" || len(got.Rooms) != 1 {
+		t.Fatalf("PUT without rooms should keep rooms, got %+v", got)
+	}
+
+	if w := doJSON(r, http.MethodDelete, "/floorplans/"+fp.ID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d", w.Code)
+	}
+	if w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("GET after delete status = %d, want 404", w.Code)
+	}
+}
+
+func TestRoomCRUD(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	roomID := fp.Rooms[0].ID
+
+	w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/rooms", CreateRoomRequest{
+		Name: "Meeting Room A", Type: models.RoomTypeMeeting, Rect: models.Rect{20, 20, 30, 30},
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var created models.Room
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ID == "" || created.FloorplanID != fp.ID || created.Status != models.RoomStatusAvailable {
+		t.Fatalf("created room = %+v", created)
+	}
+
+	name := "Office 102"
+	w = doJSON(r, http.MethodPatch, "/floorplans/"+fp.ID+"/rooms/"+roomID, PatchRoomRequest{Name: &name})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body = %s", w.Code, w.Body.String())
+	}
+	got, _ := repo.Get(context.Background(), fp.ID)
+	if got.Rooms[0].Name != "Office 102" || got.Rooms[0].Type != models.RoomTypeOffice {
+		t.Fatalf("PATCH should only change name, got %+v", got.Rooms[0])
+	}
+
+	badType := models.RoomType("KITCHEN")
+	if w := doJSON(r, http.MethodPatch, "/floorplans/"+fp.ID+"/rooms/"+roomID, PatchRoomRequest{Type: &badType}); w.Code != http.StatusBadRequest {
+		t.Fatalf("PATCH invalid type status = %d, want 400", w.Code)
+	}
+
+	if w := doJSON(r, http.MethodDelete, "/floorplans/"+fp.ID+"/rooms/"+roomID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE room status = %d", w.Code)
+	}
+	got, _ = repo.Get(context.Background(), fp.ID)
+	if len(got.Rooms) != 1 || got.Rooms[0].ID != created.ID {
+		t.Fatalf("expected only created room to remain, got %+v", got.Rooms)
+	}
+
+	if w := doJSON(r, http.MethodDelete, "/floorplans/"+fp.ID+"/rooms/"+roomID, nil); w.Code != http.StatusNotFound {
+		t.Fatalf("DELETE missing room status = %d, want 404", w.Code)
+	}
+}
+
+func TestRoomEdits_LeaveStatusToStatusEndpoints(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	roomID := fp.Rooms[0].ID
+	repo.Modify(context.Background(), fp.ID, func(fp *models.Floorplan) error {
+		fp.Rooms[0].Status, fp.Rooms[0].Occupancy = models.RoomStatusBusy, 3
+		return nil
+	})
+
+	offline, busy := models.RoomStatusOffline, models.RoomStatusBusy
+	if w := doJSON(r, http.MethodPatch, "/floorplans/"+fp.ID+"/rooms/"+roomID, PatchRoomRequest{Status: &offline}); w.Code != http.StatusBadRequest {
+		t.Errorf("PATCH status change = %d, want 400", w.Code)
+	}
+	if w := doJSON(r, http.MethodPatch, "/floorplans/"+fp.ID+"/rooms/"+roomID, PatchRoomRequest{Status: &busy}); w.Code != http.StatusOK {
+		t.Errorf("PATCH unchanged status = %d, want 200", w.Code)
+	}
+
+	room := models.Room{ID: roomID, Name: "Office 101", Rect: models.Rect{0, 0, 10, 10}}
+	w := doJSON(r, http.MethodPut, "/floorplans/"+fp.ID, UpdateFloorplanRequest{Filename: "plan.png", Rooms: []models.Room{room}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PUT status = %d, body = %s", w.Code, w.Body.String())
+	}
+	got, _ := repo.Get(context.Background(), fp.ID)
+	if got.Rooms[0].Status != models.RoomStatusBusy || got.Rooms[0].Occupancy != 3 {
+		t.Errorf("PUT without status = %+v, want stored status and occupancy kept", got.Rooms[0])
+	}
+
+	room.Status = models.RoomStatusOffline
+	if w := doJSON(r, http.MethodPut, "/floorplans/"+fp.ID, UpdateFloorplanRequest{Filename: "plan.png", Rooms: []models.Room{room}}); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT status change = %d, want 400", w.Code)
+	}
+
+	room.Status = ""
+	if w := doJSON(r, http.MethodPut, "/floorplans/"+fp.ID, UpdateFloorplanRequest{Filename: "plan.png", Rooms: []models.Room{room, room}}); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT duplicate room IDs = %d, want 400", w.Code)
+	}
+}
+
+func TestDeleteRoom_UnlinksDoors(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	fp.Rooms = append(fp.Rooms, models.Room{ID: "hall", Name: "Hall", Rect: models.Rect{10, 0, 10, 10}})
+	fp.Doors = []models.Door{{ID: "door-1", Position: models.Point{10, 5}, Width: 4, RoomIDs: []string{fp.Rooms[0].ID, "hall"}}}
+	fp.Windows = []models.Window{{ID: "window-1", Position: models.Point{0, 5}, Width: 4, RoomIDs: []string{fp.Rooms[0].ID}}}
+	if err := repo.Update(context.Background(), fp); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	if w := doJSON(r, http.MethodDelete, "/floorplans/"+fp.ID+"/rooms/"+fp.Rooms[0].ID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("DELETE room status = %d", w.Code)
+	}
+	got, _ := repo.Get(context.Background(), fp.ID)
+	if !reflect.DeepEqual(got.Doors[0].RoomIDs, []string{"hall"}) || len(got.Windows[0].RoomIDs) != 0 {
+		t.Fatalf("expected the deleted room to be unlinked, got doors %+v windows %+v", got.Doors, got.Windows)
+	}
+}
+
+func TestGetFloorplanOverlay(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := &models.Floorplan{ImageURL: whitePNG(t, 100, 50), Width: 100, Height: 50, Rooms: []models.Room{
+		{ID: "a", Name: "Office <1>", Type: models.RoomTypeOffice, Status: models.RoomStatusBusy, Rect: models.Rect{10, 10, 40, 30}},
+	}}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+
+	w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/overlay?color_by=status", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("PNG status = %d, content type = %q, body = %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.NRGBAModel.Convert(img.At(11, 12)).(color.NRGBA); c != statusColors[models.RoomStatusBusy] {
+		t.Errorf("outline pixel = %v, want the busy color", c)
+	}
+
+	w = doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/overlay?format=svg", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/svg+xml" {
+		t.Fatalf("SVG status = %d, content type = %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	svg := w.Body.String()
+	for _, want := range []string{`<rect x="10" y="10" width="40" height="30"`, `stroke="#3498db"`, "Office &lt;1&gt;", `<image id="floorplan" href="data:image/png;base64,`, `<g id="a" fill="#3498db"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("SVG does not contain %s:\n%s", want, svg)
+		}
+	}
+
+	for path, want := range map[string]int{
+		"/floorplans/" + fp.ID + "/overlay?format=jpg":     http.StatusBadRequest,
+		"/floorplans/" + fp.ID + "/overlay?color_by=floor": http.StatusBadRequest,
+		"/floorplans/missing/overlay":                      http.StatusNotFound,
+	} {
+		if w := doJSON(r, http.MethodGet, path, nil); w.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestRoomPolygons(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+
+	lShape := []models.Point{{10, 10}, {50, 10}, {50, 40}, {30, 40}, {30, 25}, {10, 25}}
+	w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/rooms", CreateRoomRequest{Name: "Office L", Type: models.RoomTypeOffice, Polygon: lShape})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("POST status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var created models.Room
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if !reflect.DeepEqual(created.Rect, models.Rect{10, 10, 40, 30}) || len(created.Polygon) != 6 {
+		t.Fatalf("created room = %+v, want the polygon and its bounding rect", created)
+	}
+
+	w = doJSON(r, http.MethodPatch, "/floorplans/"+fp.ID+"/rooms/"+created.ID, PatchRoomRequest{Rect: models.Rect{0, 0, 20, 20}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH status = %d, body = %s", w.Code, w.Body.String())
+	}
+	got, _ := repo.Get(context.Background(), fp.ID)
+	if room := got.Rooms[1]; room.Polygon != nil || !reflect.DeepEqual(room.Rect, models.Rect{0, 0, 20, 20}) {
+		t.Fatalf("PATCH rect should replace the polygon, got %+v", room)
+	}
+
+	for _, polygon := range [][]models.Point{{{0, 0}, {10, 10}}, {{0, 0}, {-5, 10}, {10, 10}}} {
+		req := CreateRoomRequest{Name: "Bad", Polygon: polygon}
+		if w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/rooms", req); w.Code != http.StatusBadRequest {
+			t.Errorf("POST polygon %v status = %d, want 400", polygon, w.Code)
+		}
+	}
+	if w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/rooms", CreateRoomRequest{Name: "No geometry"}); w.Code != http.StatusBadRequest {
+		t.Errorf("POST without rect or polygon status = %d, want 400", w.Code)
+	}
+}
+
+func TestCalibrateFloorplan(t *testing.T) {
+	r, repo := newTestRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+
+	// 50 pixels over 10 meters: the 10 x 10 pixel office is 2 x 2 meters.
+	w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/calibration", CalibrationRequest{Start: models.Point{0, 0}, End: models.Point{30, 40}, Meters: 10})
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var calibrated models.Floorplan
+	json.Unmarshal(w.Body.Bytes(), &calibrated)
+	if calibrated.PixelsPerMeter != 5 {
+		t.Fatalf("pixels_per_meter = %v, want 5", calibrated.PixelsPerMeter)
+	}
+	if room := calibrated.Rooms[0]; room.WidthM != 2 || room.HeightM != 2 || room.AreaM2 != 4 {
+		t.Fatalf("room = %+v, want 2 x 2 m", room)
+	}
+
+	var got models.Floorplan
+	json.Unmarshal(doJSON(r, http.MethodGet, "/floorplans/"+fp.ID, nil).Body.Bytes(), &got)
+	if got.PixelsPerMeter != 5 || got.Rooms[0].AreaM2 != 4 {
+		t.Fatalf("GET floorplan = %+v, want the calibrated sizes", got)
+	}
+	stored, _ := repo.Get(context.Background(), fp.ID)
+	if stored.Rooms[0].AreaM2 != 0 {
+		t.Fatalf("stored room = %+v, sizes should not be stored", stored.Rooms[0])
+	}
+
+	for _, req := range []CalibrationRequest{
+		{Start: models.Point{5, 5}, End: models.Point{5, 5}, Meters: 1},
+		{Start: models.Point{0, 0}, End: models.Point{10, 0}},
+		{Start: models.Point{0, 0}, End: models.Point{10, 0}, Meters: -1},
+	} {
+		if w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/calibration", req); w.Code != http.StatusBadRequest {
+			t.Errorf("POST %+v status = %d, want 400", req, w.Code)
+		}
+	}
+	if w := doJSON(r, http.MethodPost, "/floorplans/missing/calibration", CalibrationRequest{End: models.Point{1, 0}, Meters: 1}); w.Code != http.StatusNotFound {
+		t.Errorf("POST missing floorplan status = %d, want 404", w.Code)
+	}
+}
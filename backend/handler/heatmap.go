@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"net/http"
+
+	"floorplan-whiteboard/occupancy"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HeatmapHandler renders room utilization onto floorplan images.
+type HeatmapHandler struct {
+	floorplans *FloorplanHandler
+	service    *occupancy.Service
+}
+
+// NewHeatmapHandler creates a HeatmapHandler that loads floorplans through
+// floorplans and reads utilization from service.
+func NewHeatmapHandler(floorplans *FloorplanHandler, service *occupancy.Service) *HeatmapHandler {
+	return &HeatmapHandler{floorplans: floorplans, service: service}
+}
+
+// GetHeatmap godoc
+// @Summary Render a utilization heatmap
+// @Description Render the stored floorplan image with each room filled by how busy it was between from and to, from green (never) through yellow to red (always), labeled with the busy percentage. Rooms without recorded status are grey.
+// @ID getFloorplanHeatmap
+// @Tags status
+// @Produce png
+// @Param id path string true "Floorplan ID"
+// @Param from query string false "Start, RFC 3339 (default: 24 hours before to)"
+// @Param to query string false "End, RFC 3339 (default: now)"
+// @Success 200 {file} file "PNG image"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/heatmap.png [get]
+func (h *HeatmapHandler) GetHeatmap(c *gin.Context) {
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	fp, ok := h.floorplans.loadFloorplan(c)
+	if !ok {
+		return
+	}
+
+	utilization, err := h.service.FloorplanUtilization(c.Request.Context(), fp, from, to)
+	if errors.Is(err, occupancy.ErrInvalidRange) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load status history: " + err.Error()})
+		return
+	}
+
+	img, err := floorplanImage(fp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load floorplan image: " + err.Error()})
+		return
+	}
+	RenderHeatmap(img, fp.Rooms, utilization)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encode heatmap: " + err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "image/png", buf.Bytes())
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"net/http"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/occupancy"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// whitePNG returns a data URI of a white PNG of the given size.
+func whitePNG(t *testing.T, w, h int) string {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestGetHeatmap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx := context.Background()
+	repo := store.NewMemoryRepository()
+	floorplans := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": ai.StaticDetector{}}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	h := NewHeatmapHandler(floorplans, occupancy.NewService(repo, repo, nil))
+	r := gin.New()
+	r.GET("/floorplans/:id/heatmap.png", h.GetHeatmap)
+
+	fp := &models.Floorplan{ImageURL: whitePNG(t, 300, 100), Width: 300, Height: 100, Rooms: []models.Room{
+		{ID: "busy", Rect: models.Rect{0, 0, 100, 60}},
+		{ID: "free", Rect: models.Rect{100, 0, 100, 60}},
+		{ID: "unknown", Rect: models.Rect{200, 0, 100, 60}},
+	}}
+	if err := repo.Create(ctx, fp); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	repo.AppendHistory(ctx, []models.StatusChange{
+		{FloorplanID: fp.ID, RoomID: "busy", Status: models.RoomStatusBusy, Timestamp: start},
+		{FloorplanID: fp.ID, RoomID: "free", Status: models.RoomStatusAvailable, Timestamp: start},
+	})
+
+	w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/heatmap.png?from=2026-03-02T09:00:00Z&to=2026-03-02T10:00:00Z", nil)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("status = %d, content type = %q, body = %s", w.Code, w.Header().Get("Content-Type"), w.Body.String())
+	}
+	img, err := png.Decode(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 300 || b.Dy() != 100 {
+		t.Fatalf("heatmap size = %v, want the floorplan's", b)
+	}
+
+	rgb := func(x, y int) (int, int, int) {
+		c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+		return int(c.R), int(c.G), int(c.B)
+	}
+	if r, g, _ := rgb(5, 5); r <= g {
+		t.Errorf("busy room pixel = %v, %v: want red", r, g)
+	}
+	if r, g, _ := rgb(105, 5); g <= r {
+		t.Errorf("free room pixel = %v, %v: want green", r, g)
+	}
+	if r, g, b := rgb(205, 5); r == 255 || abs(r-g) > 20 || abs(g-b) > 20 {
+		t.Errorf("room without data = %v, %v, %v: want grey", r, g, b)
+	}
+
+	for path, want := range map[string]int{
+		"/floorplans/missing/heatmap.png": http.StatusNotFound,
+		"/floorplans/" + fp.ID + "/heatmap.png?from=2026-03-02T10:00:00Z&to=2026-03-02T09:00:00Z": http.StatusBadRequest,
+	} {
+		if w := doJSON(r, http.MethodGet, path, nil); w.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
+
+func TestHeatColor(t *testing.T) {
+	if heatColor(0) != heatLow || heatColor(50) != heatMid || heatColor(100) != heatHigh || heatColor(150) != heatHigh {
+		t.Errorf("heatColor endpoints = %v %v %v", heatColor(0), heatColor(50), heatColor(100))
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func TestDrawRoom_Polygon(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	red := color.NRGBA{255, 0, 0, 255}
+	room := models.Room{Rect: models.Rect{0, 0, 40, 40}, Polygon: []models.Point{{0, 0}, {40, 0}, {0, 40}}}
+	r, _ := roomRect(img, room)
+	(&canvas{img: img}).drawRoom(room, r, red, red, 1)
+
+	if c := img.NRGBAAt(10, 10); c != red {
+		t.Errorf("pixel inside the triangle = %v, want filled", c)
+	}
+	if c := img.NRGBAAt(35, 35); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("pixel outside the triangle = %v, want untouched", c)
+	}
+
+	// Far off the image, where the rasterizer's fixed-point math would overflow.
+	huge := models.Room{Rect: models.Rect{0, 0, 1 << 30, 1 << 30}, Polygon: []models.Point{{0, 0}, {1 << 30, 0}, {0, 1 << 30}}}
+	blue := color.NRGBA{0, 0, 255, 255}
+	r, _ = roomRect(img, huge)
+	(&canvas{img: img}).drawRoom(huge, r, blue, blue, 1)
+	if c := img.NRGBAAt(35, 35); c != blue {
+		t.Errorf("pixel inside the huge triangle = %v, want filled", c)
+	}
+}
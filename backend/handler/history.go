@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"floorplan-whiteboard/occupancy"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultHistoryRange is the range queried when from is omitted.
+const defaultHistoryRange = 24 * time.Hour
+
+// GetRoomHistory godoc
+// @Summary Get a room's status history
+// @Description List the recorded status and occupancy changes of a room with from <= timestamp < to, oldest first. initial is the last change before from, i.e. the room's state at from.
+// @ID getRoomHistory
+// @Tags status
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param roomId path string true "Room ID"
+// @Param from query string false "Start, RFC 3339 (default: 24 hours before to)"
+// @Param to query string false "End, RFC 3339 (default: now)"
+// @Success 200 {object} occupancy.RoomHistory "Status changes"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/rooms/{roomId}/history [get]
+func (h *StatusHandler) GetRoomHistory(c *gin.Context) {
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.service.History(c.Request.Context(), c.Param("id"), c.Param("roomId"), from, to)
+	if !h.historyOK(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, history)
+}
+
+// GetRoomUtilization godoc
+// @Summary Get a room's utilization
+// @Description Aggregate a room's status history into hourly or daily buckets with the percentage of time it was busy, available, offline or unknown, and its peak occupancy.
+// @ID getRoomUtilization
+// @Tags status
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param roomId path string true "Room ID"
+// @Param from query string false "Start, RFC 3339 (default: 24 hours before to)"
+// @Param to query string false "End, RFC 3339 (default: now)"
+// @Param interval query string false "Bucket size: hour (default) or day"
+// @Param tz query string false "IANA time zone the buckets are aligned in (default: UTC)"
+// @Success 200 {object} occupancy.Utilization "Utilization per bucket"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/rooms/{roomId}/utilization [get]
+func (h *StatusHandler) GetRoomUtilization(c *gin.Context) {
+	from, to, ok := timeRange(c)
+	if !ok {
+		return
+	}
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid tz: %v", err)})
+		return
+	}
+
+	interval := c.DefaultQuery("interval", occupancy.IntervalHour)
+	u, err := h.service.Utilization(c.Request.Context(), c.Param("id"), c.Param("roomId"), from, to, interval, loc)
+	if !h.historyOK(c, err) {
+		return
+	}
+	c.JSON(http.StatusOK, u)
+}
+
+// timeRange parses the from and to query parameters, writing an error
+// response and returning false when they are invalid.
+func timeRange(c *gin.Context) (from, to time.Time, ok bool) {
+	to = time.Now().UTC()
+	if raw := c.Query("to"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid to: %v", err)})
+			return from, to, false
+		}
+		to = t
+	}
+	from = to.Add(-defaultHistoryRange)
+	if raw := c.Query("from"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid from: %v", err)})
+			return from, to, false
+		}
+		from = t
+	}
+	return from, to, true
+}
+
+// historyOK writes the error response for a failed history query and returns
+// false, or returns true when err is nil.
+func (h *StatusHandler) historyOK(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floorplan not found"})
+	case errors.Is(err, occupancy.ErrRoomNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+	case errors.Is(err, occupancy.ErrInvalidRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load status history: " + err.Error()})
+	}
+	return false
+}
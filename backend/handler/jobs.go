@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"floorplan-whiteboard/jobs"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JobHandler serves the asynchronous variant of the upload endpoint.
+type JobHandler struct {
+	floorplans *FloorplanHandler
+	manager    *jobs.Manager
+}
+
+// NewJobHandler creates a JobHandler that runs uploads through floorplans on manager's workers.
+func NewJobHandler(floorplans *FloorplanHandler, manager *jobs.Manager) *JobHandler {
+	return &JobHandler{floorplans: floorplans, manager: manager}
+}
+
+// CreateJob godoc
+// @Summary Submit a floorplan for asynchronous analysis
+// @Description Accepts the same upload as /api/v1/upload but returns immediately with a job ID. Poll GET /api/v1/jobs/{id}, or subscribe to the job ID on /ws for job_progress messages; on success the job result is {"floorplan_ids": [...]}, the saved floorplans (one per PDF page, in page order) to load from GET /api/v1/floorplans/{id}.
+// @ID createJob
+// @Tags jobs
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Floorplan image or PDF file"
+// @Param detector query string false "Room detector: gemini (Vertex AI) or classical (offline edge-based)"
+// @Success 202 {object} jobs.Job "Queued job"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 503 {object} map[string]string "Job queue is full"
+// @Router /api/v1/jobs [post]
+func (h *JobHandler) CreateJob(c *gin.Context) {
+	upload, ok := h.floorplans.readUpload(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.manager.Submit(func(ctx context.Context, progress func(stage string)) (interface{}, error) {
+		floorplans, err := h.floorplans.analyzeUpload(ctx, upload, progress)
+		if err != nil {
+			return nil, err
+		}
+		// Jobs are kept in memory for polling, so keep their results small:
+		// the floorplans themselves, images included, are in the repository.
+		ids := make([]string, len(floorplans))
+		for i, floorplan := range floorplans {
+			ids[i] = floorplan.ID
+		}
+		return gin.H{"floorplan_ids": ids}, nil
+	})
+	if errors.Is(err, jobs.ErrQueueFull) || errors.Is(err, jobs.ErrStopped) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", "/api/v1/jobs/"+job.ID)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetJob godoc
+// @Summary Get job status
+// @Description Report the state (queued, running, succeeded, failed) of an analysis job and, once finished, its result or error
+// @ID getJob
+// @Tags jobs
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} jobs.Job "Job"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/jobs/{id} [get]
+func (h *JobHandler) GetJob(c *gin.Context) {
+	job, err := h.manager.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/jobs"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestJobs_UploadRunsAsynchronously(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	detector := ai.StaticDetector{Rooms: []ai.GeminiRoom{
+		{Name: "Office 101", Type: "OFFICE", Rect: []int{0, 0, 500, 500}},
+	}}
+	repo := store.NewMemoryRepository()
+	floorplans := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+
+	manager := jobs.NewManager(config.Default().Jobs, nil)
+	manager.Start()
+	defer manager.Stop()
+	h := NewJobHandler(floorplans, manager)
+
+	r := gin.New()
+	r.POST("/jobs", h.CreateJob)
+	r.GET("/jobs/:id", h.GetJob)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/jobs", "plan.png", 100, 100))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var job jobs.Job
+	json.Unmarshal(w.Body.Bytes(), &job)
+	if job.ID == "" || w.Header().Get("Location") != "/api/v1/jobs/"+job.ID {
+		t.Fatalf("unexpected job response %+v, Location %q", job, w.Header().Get("Location"))
+	}
+
+	var polled struct {
+		State  jobs.State `json:"state"`
+		Result struct {
+			FloorplanIDs []string `json:"floorplan_ids"`
+		} `json:"result"`
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/"+job.ID, nil))
+		json.Unmarshal(w.Body.Bytes(), &polled)
+		if polled.State == jobs.StateSucceeded || polled.State == jobs.StateFailed {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if polled.State != jobs.StateSucceeded {
+		t.Fatalf("job state = %s, body = %s", polled.State, w.Body.String())
+	}
+	if len(polled.Result.FloorplanIDs) != 1 {
+		t.Fatalf("job result = %s", w.Body.String())
+	}
+	if fp, err := repo.Get(context.Background(), polled.Result.FloorplanIDs[0]); err != nil || len(fp.Rooms) != 1 {
+		t.Fatalf("saved floorplan = %+v, %v", fp, err)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/jobs/missing", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("missing job status = %d, want 404", w.Code)
+	}
+}
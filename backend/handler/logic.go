@@ -1,30 +1,69 @@
 package handler
 
 import (
-	"floorplan-whiteboard/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"image"
+	"math"
+	"sort"
+
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/models"
 )
 
-// GeminiRoom represents the room structure returned by Gemini (0-1000 coordinates).
-type GeminiRoom struct {
-	Name string `json:"name"`
-	Type string `json:"type"`
-	Rect []int  `json:"rect"` // [ymin, xmin, ymax, xmax] 0-1000
+// ImageKey is the content hash of an uploaded image that detected IDs are
+// derived from. Floorplans get a new ID on every upload, so the image, not
+// the floorplan, is what makes re-detecting the same drawing stable.
+func ImageKey(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// RoomID derives a stable room ID from the image it was detected in (see
+// ImageKey) and the room's normalized 0-1000 rect, so re-running detection on
+// the same image yields the same IDs. seq disambiguates rooms that share an
+// identical rect; it is 0 for the first.
+func RoomID(imageKey string, rect []int, seq int) string {
+	return stableID("room", imageKey, rect, seq)
+}
+
+// stableID hashes the image key and detected 0-1000 coordinates into an ID
+// with the given prefix, as RoomID does for rooms.
+func stableID(prefix, imageKey string, coords []int, seq int) string {
+	key := fmt.Sprintf("%s|%v", imageKey, coords)
+	if seq > 0 {
+		key = fmt.Sprintf("%s|%d", key, seq)
+	}
+	sum := sha256.Sum256([]byte(key))
+	return prefix + "-" + hex.EncodeToString(sum[:8])
 }
 
 // CalculateCropAndRemap computes the crop rectangle and remaps room coordinates.
-func CalculateCropAndRemap(imgW, imgH int, geminiRooms []GeminiRoom) (image.Rectangle, []models.Room, error) {
+// The crop is contentBox ([ymin, xmin, ymax, xmax], 0-1000), grown to cover
+// every detected room so none is cut off; an invalid or nil contentBox crops
+// to the full image. Room rects are remapped relative to the crop and clamped
+// to it. Each room gets a deterministic ID from imageKey, its detected rect
+// and its seq among identical rects (see RoomID); floorplanID only sets
+// Room.FloorplanID. Polygons are remapped alongside rects and, like edited
+// rooms, replace the rect with their bounding box (see fitRect); malformed or
+// empty ones are dropped and the room keeps only its rect.
+func CalculateCropAndRemap(floorplanID, imageKey string, imgW, imgH int, contentBox []int, geminiRooms []ai.GeminiRoom) (image.Rectangle, []models.Room, error) {
 	// Helper to scale 0-1000 to pixels
 	scaleY := func(v int) int { return int(float64(v) / 1000.0 * float64(imgH)) }
 	scaleX := func(v int) int { return int(float64(v) / 1000.0 * float64(imgW)) }
 
-	var cYMin, cXMin, cYMax, cXMax int
-
 	// Default to full image
-	cYMin = 0
-	cXMin = 0
-	cYMax = imgH
-	cXMax = imgW
+	cYMin, cXMin, cYMax, cXMax := 0, 0, imgH, imgW
+	if validBox(contentBox) {
+		cYMin, cXMin, cYMax, cXMax = scaleY(contentBox[0]), scaleX(contentBox[1]), scaleY(contentBox[2]), scaleX(contentBox[3])
+		for _, room := range geminiRooms {
+			if validBox(room.Rect) {
+				cYMin, cXMin = min(cYMin, scaleY(room.Rect[0])), min(cXMin, scaleX(room.Rect[1]))
+				cYMax, cXMax = max(cYMax, scaleY(room.Rect[2])), max(cXMax, scaleX(room.Rect[3]))
+			}
+		}
+	}
 
 	// Validate bounds
 	if cXMin < 0 {
@@ -44,16 +83,17 @@ func CalculateCropAndRemap(imgW, imgH int, geminiRooms []GeminiRoom) (image.Rect
 	cropRect := image.Rect(cXMin, cYMin, cXMax, cYMax)
 
 	var remappedRooms []models.Room
+	seen := make(map[string]int)
 	for _, room := range geminiRooms {
 		if len(room.Rect) != 4 {
 			continue
 		}
 
-		// Original Room Coords: [ymin, xmin, ymax, xmax]
-		rYMin := scaleY(room.Rect[0])
-		rXMin := scaleX(room.Rect[1])
-		rYMax := scaleY(room.Rect[2])
-		rXMax := scaleX(room.Rect[3])
+		// Original Room Coords: [ymin, xmin, ymax, xmax], clamped to the crop
+		rYMin := min(max(scaleY(room.Rect[0]), cYMin), cYMax)
+		rXMin := min(max(scaleX(room.Rect[1]), cXMin), cXMax)
+		rYMax := min(max(scaleY(room.Rect[2]), cYMin), cYMax)
+		rXMax := min(max(scaleX(room.Rect[3]), cXMin), cXMax)
 
 		// Remap relative to crop
 		newX := rXMin - cXMin
@@ -75,13 +115,204 @@ func CalculateCropAndRemap(imgW, imgH int, geminiRooms []GeminiRoom) (image.Rect
 			roomType = models.RoomTypeMeeting
 		}
 
-		remappedRooms = append(remappedRooms, models.Room{
-			Name:   room.Name,
-			Type:   roomType,
-			Rect:   []int{newX, newY, newW, newH},
-			Status: models.RoomStatusAvailable, // Default status
-		})
+		rectKey := fmt.Sprint(room.Rect)
+		seq := seen[rectKey]
+		seen[rectKey]++
+
+		remapped := models.Room{
+			ID:          RoomID(imageKey, room.Rect, seq),
+			FloorplanID: floorplanID,
+			Name:        room.Name,
+			Type:        roomType,
+			Rect:        []int{newX, newY, newW, newH},
+			Polygon:     remapPolygon(room.Polygon, scaleX, scaleY, cropRect),
+			Status:      models.RoomStatusAvailable, // Default status
+		}
+		if remapped.Polygon != nil {
+			if box := models.BoundingRect(remapped.Polygon); box[2] > 0 && box[3] > 0 {
+				fitRect(&remapped)
+			} else {
+				remapped.Polygon = nil
+			}
+		}
+		remappedRooms = append(remappedRooms, remapped)
 	}
 
 	return cropRect, remappedRooms, nil
 }
+
+// RemapStructure converts detected walls, doors and windows into pixels
+// relative to crop, as CalculateCropAndRemap does for rooms, and gives each a
+// stable ID derived from imageKey. Segments are clamped to the crop; openings
+// whose center falls outside it are dropped. Doors and windows are linked to
+// the (at most two) rooms within half their width, so that a doorway in a
+// wall between two rooms connects both.
+func RemapStructure(imageKey string, imgW, imgH int, crop image.Rectangle, detection *ai.Detection, rooms []models.Room) ([]models.Wall, []models.Door, []models.Window) {
+	scaleY := func(v int) int { return int(float64(v) / 1000.0 * float64(imgH)) }
+	scaleX := func(v int) int { return int(float64(v) / 1000.0 * float64(imgW)) }
+	point := func(y, x int) models.Point {
+		return models.Point{
+			min(max(scaleX(x), crop.Min.X), crop.Max.X) - crop.Min.X,
+			min(max(scaleY(y), crop.Min.Y), crop.Max.Y) - crop.Min.Y,
+		}
+	}
+
+	var walls []models.Wall
+	seen := make(map[string]int)
+	for _, w := range detection.Walls {
+		if !validLine(w.Line) {
+			continue
+		}
+		start, end := point(w.Line[0], w.Line[1]), point(w.Line[2], w.Line[3])
+		if start == end {
+			continue
+		}
+		// Thickness is on the scale of the axis it is measured along.
+		thickness := scaleX(w.Thickness)
+		if dx, dy := end[0]-start[0], end[1]-start[1]; dx*dx >= dy*dy {
+			thickness = scaleY(w.Thickness)
+		}
+		key := fmt.Sprint(w.Line)
+		walls = append(walls, models.Wall{
+			ID:        stableID("wall", imageKey, w.Line, seen[key]),
+			Start:     start,
+			End:       end,
+			Thickness: max(thickness, 1),
+		})
+		seen[key]++
+	}
+
+	type opening struct {
+		id       string
+		position models.Point
+		width    int
+		roomIDs  []string
+	}
+	remap := func(prefix string, detected []ai.GeminiOpening) []opening {
+		var out []opening
+		seen := make(map[string]int)
+		for _, o := range detected {
+			if !validLine(o.Line) {
+				continue
+			}
+			y1, x1, y2, x2 := scaleY(o.Line[0]), scaleX(o.Line[1]), scaleY(o.Line[2]), scaleX(o.Line[3])
+			center := image.Pt((x1+x2)/2, (y1+y2)/2)
+			if !center.In(crop) {
+				continue
+			}
+			position := models.Point{center.X - crop.Min.X, center.Y - crop.Min.Y}
+			width := int(math.Round(math.Hypot(float64(x2-x1), float64(y2-y1))))
+			key := fmt.Sprint(o.Line)
+			out = append(out, opening{
+				id:       stableID(prefix, imageKey, o.Line, seen[key]),
+				position: position,
+				width:    width,
+				roomIDs:  nearbyRooms(rooms, position, max(float64(width)/2, 2), 2),
+			})
+			seen[key]++
+		}
+		return out
+	}
+
+	var doors []models.Door
+	for _, o := range remap("door", detection.Doors) {
+		doors = append(doors, models.Door{ID: o.id, Position: o.position, Width: o.width, RoomIDs: o.roomIDs})
+	}
+	var windows []models.Window
+	for _, o := range remap("window", detection.Windows) {
+		windows = append(windows, models.Window{ID: o.id, Position: o.position, Width: o.width, RoomIDs: o.roomIDs})
+	}
+	return walls, doors, windows
+}
+
+// scalePixelsPerMeter converts a detected scale into pixels per meter of an
+// imgW x imgH image, returning 0 when scale is nil or unusable. Cropping does
+// not change the scale.
+func scalePixelsPerMeter(imgW, imgH int, scale *ai.GeminiScale) float64 {
+	if scale == nil || !validLine(scale.Line) || !(scale.Meters > 0) {
+		return 0
+	}
+	dy := float64(scale.Line[2]-scale.Line[0]) / 1000 * float64(imgH)
+	dx := float64(scale.Line[3]-scale.Line[1]) / 1000 * float64(imgW)
+	return math.Hypot(dx, dy) / scale.Meters
+}
+
+// nearbyRooms returns the IDs of up to limit rooms whose outline lies within
+// reach pixels of p, nearest first.
+func nearbyRooms(rooms []models.Room, p models.Point, reach float64, limit int) []string {
+	type candidate struct {
+		id   string
+		dist float64
+	}
+	var near []candidate
+	for _, room := range rooms {
+		if d := room.Distance(float64(p[0]), float64(p[1])); d <= reach {
+			near = append(near, candidate{room.ID, d})
+		}
+	}
+	sort.SliceStable(near, func(i, j int) bool { return near[i].dist < near[j].dist })
+
+	ids := []string{}
+	for i := 0; i < len(near) && i < limit; i++ {
+		ids = append(ids, near[i].id)
+	}
+	return ids
+}
+
+// fitRect makes a polygon room's rect the bounding box of its polygon, which
+// is how rooms are stored whether detected or edited. Rooms without a polygon
+// keep their rect.
+func fitRect(room *models.Room) {
+	if len(room.Polygon) >= 3 {
+		room.Rect = models.BoundingRect(room.Polygon)
+	}
+}
+
+// remapPolygon converts a Gemini [[y, x], ...] 0-1000 outline into points
+// relative to crop, clamped to it. It returns nil unless the outline has at
+// least three well-formed vertices.
+func remapPolygon(polygon [][]int, scaleX, scaleY func(int) int, crop image.Rectangle) []models.Point {
+	if len(polygon) < 3 {
+		return nil
+	}
+	points := make([]models.Point, 0, len(polygon))
+	for _, p := range polygon {
+		if len(p) != 2 {
+			return nil
+		}
+		x := min(max(scaleX(p[1]), crop.Min.X), crop.Max.X)
+		y := min(max(scaleY(p[0]), crop.Min.Y), crop.Max.Y)
+		points = append(points, models.Point{x - crop.Min.X, y - crop.Min.Y})
+	}
+	return points
+}
+
+// relativeBox converts r, in pixels of a w x h image, into a
+// [ymin, xmin, ymax, xmax] 0-1000 box that still covers it.
+func relativeBox(r image.Rectangle, w, h int) []int {
+	floor := func(v, size int) int { return v * 1000 / size }
+	ceil := func(v, size int) int { return (v*1000 + size - 1) / size }
+	return []int{floor(r.Min.Y, h), floor(r.Min.X, w), ceil(r.Max.Y, h), ceil(r.Max.X, w)}
+}
+
+// validLine reports whether line is a [y1, x1, y2, x2] segment within the
+// 0-1000 scale.
+func validLine(line []int) bool {
+	if len(line) != 4 {
+		return false
+	}
+	for _, v := range line {
+		if v < 0 || v > 1000 {
+			return false
+		}
+	}
+	return true
+}
+
+// validBox reports whether box is a non-empty [ymin, xmin, ymax, xmax] box
+// within the 0-1000 scale.
+func validBox(box []int) bool {
+	return len(box) == 4 &&
+		box[0] >= 0 && box[1] >= 0 && box[2] <= 1000 && box[3] <= 1000 &&
+		box[0] < box[2] && box[1] < box[3]
+}
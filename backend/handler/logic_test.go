@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"floorplan-whiteboard/ai"
 	"floorplan-whiteboard/models"
 	"image"
 	"reflect"
@@ -11,7 +12,8 @@ func TestCalculateCropAndRemap(t *testing.T) {
 	tests := []struct {
 		name         string
 		imgW, imgH   int
-		geminiRooms  []GeminiRoom
+		contentBox   []int
+		geminiRooms  []ai.GeminiRoom
 		wantCropRect image.Rectangle // Pixels
 		wantRooms    []models.Room   // [x, y, w, h] relative to full image
 		wantErr      bool
@@ -20,7 +22,7 @@ func TestCalculateCropAndRemap(t *testing.T) {
 			name: "Simple Case: Full size image",
 			imgW: 1000,
 			imgH: 1000,
-			geminiRooms: []GeminiRoom{
+			geminiRooms: []ai.GeminiRoom{
 				{Name: "R1", Type: "OFFICE", Rect: []int{100, 100, 200, 200}}, // 100,100 -> 200,200
 			},
 			wantCropRect: image.Rect(0, 0, 1000, 1000),
@@ -32,7 +34,7 @@ func TestCalculateCropAndRemap(t *testing.T) {
 			name: "Bottom Right Quadrant Room",
 			imgW: 1000,
 			imgH: 1000,
-			geminiRooms: []GeminiRoom{
+			geminiRooms: []ai.GeminiRoom{
 				{Name: "R2", Type: "MEETING", Rect: []int{600, 600, 800, 800}},
 			},
 			wantCropRect: image.Rect(0, 0, 1000, 1000),
@@ -44,7 +46,7 @@ func TestCalculateCropAndRemap(t *testing.T) {
 			name: "Non-Square Aspect Ratio: 2000x1000",
 			imgW: 2000,
 			imgH: 1000,
-			geminiRooms: []GeminiRoom{
+			geminiRooms: []ai.GeminiRoom{
 				{Name: "R3", Type: "HALLWAY", Rect: []int{100, 100, 200, 200}}, // hallways normalize to UNKNOWN
 			},
 			wantCropRect: image.Rect(0, 0, 2000, 1000),
@@ -55,11 +57,81 @@ func TestCalculateCropAndRemap(t *testing.T) {
 				{Name: "R3", Type: models.RoomTypeUnknown, Rect: []int{200, 100, 200, 100}, Status: models.RoomStatusAvailable},
 			},
 		},
+		{
+			name: "Polygons remap with rects, malformed ones are dropped",
+			imgW: 2000,
+			imgH: 1000,
+			geminiRooms: []ai.GeminiRoom{
+				{Name: "L", Type: "OFFICE", Rect: []int{100, 100, 300, 300}, Polygon: [][]int{{100, 100}, {100, 300}, {300, 300}, {300, 200}, {200, 200}, {200, 100}}},
+				{Name: "Bad", Type: "OFFICE", Rect: []int{500, 500, 600, 600}, Polygon: [][]int{{500, 500}, {600}, {600, 600}}},
+			},
+			wantCropRect: image.Rect(0, 0, 2000, 1000),
+			wantRooms: []models.Room{
+				{Name: "L", Type: models.RoomTypeOffice, Rect: []int{200, 100, 400, 200}, Polygon: []models.Point{{200, 100}, {600, 100}, {600, 300}, {400, 300}, {400, 200}, {200, 200}}},
+				{Name: "Bad", Type: models.RoomTypeOffice, Rect: []int{1000, 500, 200, 100}},
+			},
+		},
+		{
+			name: "Rects are clamped to the crop and polygons set the rect",
+			imgW: 1000,
+			imgH: 1000,
+			geminiRooms: []ai.GeminiRoom{
+				{Name: "Edge", Type: "OFFICE", Rect: []int{900, 900, 1200, 1100}},
+				{Name: "Tri", Type: "OFFICE", Rect: []int{0, 0, 500, 500}, Polygon: [][]int{{100, 100}, {100, 300}, {300, 100}}},
+				{Name: "Flat", Type: "OFFICE", Rect: []int{600, 600, 700, 700}, Polygon: [][]int{{600, 600}, {600, 700}, {600, 650}}},
+			},
+			wantCropRect: image.Rect(0, 0, 1000, 1000),
+			wantRooms: []models.Room{
+				{Name: "Edge", Type: models.RoomTypeOffice, Rect: []int{900, 900, 100, 100}},
+				{Name: "Tri", Type: models.RoomTypeOffice, Rect: []int{100, 100, 200, 200}, Polygon: []models.Point{{100, 100}, {300, 100}, {100, 300}}},
+				{Name: "Flat", Type: models.RoomTypeOffice, Rect: []int{600, 600, 100, 100}},
+			},
+		},
+		{
+			name:       "Content box crops and shifts rooms",
+			imgW:       2000,
+			imgH:       1000,
+			contentBox: []int{100, 200, 900, 800},
+			geminiRooms: []ai.GeminiRoom{
+				{Name: "R4", Type: "OFFICE", Rect: []int{200, 300, 400, 500}},
+			},
+			wantCropRect: image.Rect(400, 100, 1600, 900),
+			wantRooms: []models.Room{
+				// x: 600-400=200, y: 200-100=100
+				{Name: "R4", Type: models.RoomTypeOffice, Rect: []int{200, 100, 400, 200}},
+			},
+		},
+		{
+			name:       "Content box grows to cover rooms outside it",
+			imgW:       1000,
+			imgH:       1000,
+			contentBox: []int{200, 200, 800, 800},
+			geminiRooms: []ai.GeminiRoom{
+				{Name: "R5", Type: "OFFICE", Rect: []int{100, 300, 300, 900}},
+			},
+			wantCropRect: image.Rect(200, 100, 900, 800),
+			wantRooms: []models.Room{
+				{Name: "R5", Type: models.RoomTypeOffice, Rect: []int{100, 0, 600, 200}},
+			},
+		},
+		{
+			name:       "Invalid content box crops to the full image",
+			imgW:       1000,
+			imgH:       1000,
+			contentBox: []int{500, 500, 400, 900},
+			geminiRooms: []ai.GeminiRoom{
+				{Name: "R6", Type: "OFFICE", Rect: []int{100, 100, 200, 200}},
+			},
+			wantCropRect: image.Rect(0, 0, 1000, 1000),
+			wantRooms: []models.Room{
+				{Name: "R6", Type: models.RoomTypeOffice, Rect: []int{100, 100, 100, 100}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotCropRect, gotRooms, err := CalculateCropAndRemap(tt.imgW, tt.imgH, tt.geminiRooms)
+			gotCropRect, gotRooms, err := CalculateCropAndRemap("fp-1", "image-1", tt.imgW, tt.imgH, tt.contentBox, tt.geminiRooms)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("CalculateCropAndRemap() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -79,7 +151,106 @@ func TestCalculateCropAndRemap(t *testing.T) {
 				if !reflect.DeepEqual(gotRooms[i].Rect, tt.wantRooms[i].Rect) {
 					t.Errorf("Room[%d].Rect = %v, want %v", i, gotRooms[i].Rect, tt.wantRooms[i].Rect)
 				}
+				if !reflect.DeepEqual(gotRooms[i].Polygon, tt.wantRooms[i].Polygon) {
+					t.Errorf("Room[%d].Polygon = %v, want %v", i, gotRooms[i].Polygon, tt.wantRooms[i].Polygon)
+				}
+				if gotRooms[i].FloorplanID != "fp-1" {
+					t.Errorf("Room[%d].FloorplanID = %v, want fp-1", i, gotRooms[i].FloorplanID)
+				}
 			}
 		})
 	}
 }
+
+func TestCalculateCropAndRemap_StableRoomIDs(t *testing.T) {
+	rooms := []ai.GeminiRoom{
+		{Name: "Office 101", Type: "OFFICE", Rect: []int{100, 100, 200, 200}},
+		{Name: "Office 101", Type: "OFFICE", Rect: []int{300, 300, 400, 400}},
+		{Name: "Duplicate", Type: "OFFICE", Rect: []int{100, 100, 200, 200}},
+	}
+
+	// Re-detecting the same image gives the same IDs, though each upload is
+	// a new floorplan.
+	_, first, _ := CalculateCropAndRemap("fp-1", ImageKey([]byte("plan")), 1000, 1000, nil, rooms)
+	_, second, _ := CalculateCropAndRemap("fp-2", ImageKey([]byte("plan")), 1000, 1000, nil, rooms)
+	_, other, _ := CalculateCropAndRemap("fp-3", ImageKey([]byte("other plan")), 1000, 1000, nil, rooms)
+
+	seen := make(map[string]bool)
+	for i := range first {
+		if first[i].ID == "" {
+			t.Fatalf("Room[%d] has empty ID", i)
+		}
+		if first[i].ID != second[i].ID {
+			t.Errorf("Room[%d] ID not stable: %s vs %s", i, first[i].ID, second[i].ID)
+		}
+		if first[i].ID == other[i].ID {
+			t.Errorf("Room[%d] ID should depend on the image", i)
+		}
+		if seen[first[i].ID] {
+			t.Errorf("Room[%d] ID %s is not unique", i, first[i].ID)
+		}
+		seen[first[i].ID] = true
+	}
+}
+
+func TestRelativeBox(t *testing.T) {
+	// 3 px of 7 is 428.57 per mille: mins round down, maxes up.
+	got := relativeBox(image.Rect(3, 3, 3, 3), 7, 7)
+	if want := []int{428, 428, 429, 429}; !reflect.DeepEqual(got, want) {
+		t.Errorf("relativeBox() = %v, want %v", got, want)
+	}
+	if got := relativeBox(image.Rect(0, 0, 2000, 1000), 2000, 1000); !reflect.DeepEqual(got, []int{0, 0, 1000, 1000}) {
+		t.Errorf("relativeBox(full image) = %v", got)
+	}
+}
+
+func TestRemapStructure(t *testing.T) {
+	crop := image.Rect(100, 100, 900, 900)
+	rooms := []models.Room{
+		{ID: "a", Rect: models.Rect{0, 0, 300, 400}},
+		{ID: "b", Rect: models.Rect{300, 0, 300, 400}},
+	}
+	detection := &ai.Detection{
+		Walls: []ai.GeminiWall{
+			{Line: []int{100, 400, 500, 400}, Thickness: 10}, // between a and b
+			{Line: []int{500, 0, 500, 1000}, Thickness: 20},  // clamped to the crop
+			{Line: []int{0, 0, 1200, 0}, Thickness: 5},       // invalid
+		},
+		Doors: []ai.GeminiOpening{
+			{Line: []int{300, 400, 380, 400}}, // in the wall between a and b
+		},
+		Windows: []ai.GeminiOpening{
+			{Line: []int{300, 100, 400, 100}}, // a's outer wall
+			{Line: []int{0, 0, 50, 50}},       // outside the crop
+		},
+	}
+
+	walls, doors, windows := RemapStructure("image-1", 1000, 1000, crop, detection, rooms)
+
+	if len(walls) != 2 {
+		t.Fatalf("walls = %+v, want 2", walls)
+	}
+	if w := walls[0]; w.Start != (models.Point{300, 0}) || w.End != (models.Point{300, 400}) || w.Thickness != 10 {
+		t.Errorf("walls[0] = %+v", w)
+	}
+	if w := walls[1]; w.Start != (models.Point{0, 400}) || w.End != (models.Point{800, 400}) || w.Thickness != 20 {
+		t.Errorf("walls[1] = %+v", w)
+	}
+	if walls[0].ID == "" || walls[0].ID == walls[1].ID {
+		t.Errorf("walls need distinct IDs, got %q and %q", walls[0].ID, walls[1].ID)
+	}
+
+	if len(doors) != 1 {
+		t.Fatalf("doors = %+v, want 1", doors)
+	}
+	if d := doors[0]; d.Position != (models.Point{300, 240}) || d.Width != 80 || !reflect.DeepEqual(d.RoomIDs, []string{"a", "b"}) {
+		t.Errorf("doors[0] = %+v, want the doorway between a and b", d)
+	}
+
+	if len(windows) != 1 {
+		t.Fatalf("windows = %+v, want the one inside the crop", windows)
+	}
+	if w := windows[0]; w.Position != (models.Point{0, 250}) || w.Width != 100 || !reflect.DeepEqual(w.RoomIDs, []string{"a"}) {
+		t.Errorf("windows[0] = %+v", w)
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"floorplan-whiteboard/navigation"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeViaAny lets a route use every edge of the graph.
+const routeViaAny = "any"
+
+// NavigationHandler serves room adjacency graphs and routes for wayfinding.
+type NavigationHandler struct {
+	floorplans *FloorplanHandler
+}
+
+// NewNavigationHandler creates a NavigationHandler that loads floorplans
+// through floorplans.
+func NewNavigationHandler(floorplans *FloorplanHandler) *NavigationHandler {
+	return &NavigationHandler{floorplans: floorplans}
+}
+
+// GetGraph godoc
+// @Summary Get the room adjacency graph
+// @Description List the floorplan's rooms as nodes and the connections between them as edges. Rooms linked by a door connect through it; other rooms connect where their outlines run alongside each other, no further apart than the thickest wall (at least 10 pixels) for at least 10 pixels. Distances are in cropped image pixels, from room center to room center through the connection.
+// @ID getFloorplanGraph
+// @Tags navigation
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Success 200 {object} navigation.Graph
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/graph [get]
+func (h *NavigationHandler) GetGraph(c *gin.Context) {
+	fp, ok := h.floorplans.loadFloorplan(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, navigation.Build(fp))
+}
+
+// GetRoute godoc
+// @Summary Find a route between rooms
+// @Description Find the shortest path through the room adjacency graph (see getFloorplanGraph) from one room to another, for wayfinding. By default a floorplan with doors is only crossed through doors, since rooms that merely share a boundary are separated by a wall; a floorplan without doors is crossed at shared boundaries. via=door or via=any picks the edges explicitly.
+// @ID getFloorplanRoute
+// @Tags navigation
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param from query string true "Start room ID"
+// @Param to query string true "Destination room ID"
+// @Param via query string false "Edges the route may use: door or any; default doors when the floorplan has any, else any"
+// @Success 200 {object} navigation.Route
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Floorplan or room not found, or no route"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/route [get]
+func (h *NavigationHandler) GetRoute(c *gin.Context) {
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" || to == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to room IDs are required"})
+		return
+	}
+	via := c.Query("via")
+	if via != "" && via != navigation.ViaDoor && via != routeViaAny {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "via must be door or any"})
+		return
+	}
+	fp, ok := h.floorplans.loadFloorplan(c)
+	if !ok {
+		return
+	}
+
+	graph := navigation.Build(fp)
+	switch via {
+	case "":
+		graph = graph.Walkable()
+	case navigation.ViaDoor:
+		graph = graph.Only(navigation.ViaDoor)
+	}
+	route, err := graph.ShortestPath(from, to)
+	if errors.Is(err, navigation.ErrUnknownRoom) || errors.Is(err, navigation.ErrNoRoute) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find route: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, route)
+}
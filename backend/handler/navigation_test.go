@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/navigation"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNavigation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := store.NewMemoryRepository()
+	floorplans := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": ai.StaticDetector{}}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	h := NewNavigationHandler(floorplans)
+	r := gin.New()
+	r.GET("/floorplans/:id/graph", h.GetGraph)
+	r.GET("/floorplans/:id/route", h.GetRoute)
+
+	fp := &models.Floorplan{
+		Rooms: []models.Room{
+			{ID: "office", Name: "Office 101", Rect: models.Rect{0, 0, 100, 100}},
+			{ID: "hall", Name: "Hall", Rect: models.Rect{100, 0, 50, 300}},
+			{ID: "meeting", Name: "Meeting Room A", Rect: models.Rect{150, 200, 100, 100}},
+			{ID: "closet", Name: "Closet", Rect: models.Rect{500, 500, 20, 20}},
+		},
+		Doors: []models.Door{{ID: "door-1", Position: models.Point{150, 250}, Width: 20, RoomIDs: []string{"hall", "meeting"}}},
+	}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+
+	w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/graph", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("graph status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var graph navigation.Graph
+	json.Unmarshal(w.Body.Bytes(), &graph)
+	if len(graph.Nodes) != 4 || len(graph.Edges) != 2 || graph.Edges[1].Via != navigation.ViaDoor || graph.Edges[1].DoorID != "door-1" {
+		t.Fatalf("graph = %s", w.Body.String())
+	}
+
+	// The office has no door, so by default it is walled in.
+	if w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/route?from=office&to=meeting", nil); w.Code != http.StatusNotFound {
+		t.Errorf("route out of a room without doors status = %d, want 404", w.Code)
+	}
+	if w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/route?from=hall&to=meeting&via=door", nil); w.Code != http.StatusOK {
+		t.Errorf("route through a door status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/route?from=office&to=meeting&via=any", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("route status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var route navigation.Route
+	json.Unmarshal(w.Body.Bytes(), &route)
+	if len(route.Rooms) != 3 || route.Rooms[1].Name != "Hall" || route.Edges[1].To != "meeting" || route.Distance <= 0 {
+		t.Fatalf("route = %s", w.Body.String())
+	}
+
+	for _, tc := range []struct {
+		query string
+		code  int
+	}{
+		{"?from=office", http.StatusBadRequest},
+		{"?from=office&to=meeting&via=window", http.StatusBadRequest},
+		{"?from=office&to=missing", http.StatusNotFound},
+		{"?from=office&to=closet", http.StatusNotFound},
+	} {
+		if w := doJSON(r, http.MethodGet, "/floorplans/"+fp.ID+"/route"+tc.query, nil); w.Code != tc.code {
+			t.Errorf("route%s status = %d, want %d", tc.query, w.Code, tc.code)
+		}
+	}
+	if w := doJSON(r, http.MethodGet, "/floorplans/missing/graph", nil); w.Code != http.StatusNotFound {
+		t.Errorf("graph of missing floorplan status = %d, want 404", w.Code)
+	}
+}
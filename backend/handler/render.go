@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/occupancy"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// heatAlpha is the opacity of the utilization overlay on each room.
+const heatAlpha = 140
+
+var (
+	heatLow   = color.NRGBA{46, 204, 113, 255} // 0% busy
+	heatMid   = color.NRGBA{241, 196, 15, 255} // 50%
+	heatHigh  = color.NRGBA{231, 76, 60, 255}  // 100%
+	noData    = color.NRGBA{149, 165, 166, 255}
+	labelText = color.NRGBA{33, 33, 33, 255}
+
+	typeColors = map[models.RoomType]color.NRGBA{
+		models.RoomTypeOffice:  {52, 152, 219, 255},
+		models.RoomTypeMeeting: {155, 89, 182, 255},
+		models.RoomTypeHallway: {230, 126, 34, 255},
+	}
+	statusColors = map[models.RoomStatus]color.NRGBA{
+		models.RoomStatusAvailable: heatLow,
+		models.RoomStatusBusy:      heatHigh,
+		models.RoomStatusOffline:   {52, 73, 94, 255},
+	}
+)
+
+// OverlayColorBy selects what annotated overlays color-code rooms by.
+type OverlayColorBy string
+
+const (
+	ColorByType   OverlayColorBy = "type"
+	ColorByStatus OverlayColorBy = "status"
+)
+
+// roomColor returns the overlay color of room; unknown types and statuses are grey.
+func roomColor(room models.Room, colorBy OverlayColorBy) color.NRGBA {
+	var c color.NRGBA
+	var ok bool
+	if colorBy == ColorByStatus {
+		c, ok = statusColors[room.Status]
+	} else {
+		c, ok = typeColors[room.Type]
+	}
+	if !ok {
+		return noData
+	}
+	return c
+}
+
+// floorplanImage decodes the stored cropped image of fp. Floorplans without
+// an embedded image get a blank canvas of their size.
+func floorplanImage(fp *models.Floorplan) (*image.NRGBA, error) {
+	if data, ok := strings.CutPrefix(fp.ImageURL, "data:"); ok {
+		_, encoded, found := strings.Cut(data, ",")
+		if !found {
+			return nil, errors.New("malformed image data URI")
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("image data URI: %w", err)
+		}
+		img, _, err := image.Decode(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("image decode error: %w", err)
+		}
+		dst := image.NewNRGBA(image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy()))
+		draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Src)
+		return dst, nil
+	}
+
+	if fp.Width <= 0 || fp.Height <= 0 {
+		return nil, errors.New("floorplan has no image and no size")
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, fp.Width, fp.Height))
+	draw.Draw(dst, dst.Bounds(), image.White, image.Point{}, draw.Src)
+	return dst, nil
+}
+
+// RenderHeatmap draws each room of rooms onto img, filled with a color from
+// green (never busy) through yellow to red (always busy) and labeled with its
+// busy percentage, and adds a legend. Rooms without recorded status are grey.
+// utilization is in the order of rooms, as returned by
+// occupancy.Service.FloorplanUtilization.
+func RenderHeatmap(img *image.NRGBA, rooms []models.Room, utilization []occupancy.RoomUtilization) {
+	cv := &canvas{img: img}
+	for i, room := range rooms {
+		r, ok := roomRect(img, room)
+		if !ok || i >= len(utilization) {
+			continue
+		}
+		u := utilization[i]
+		fill, label := noData, "n/a"
+		if u.UnknownPercent < 100 {
+			fill, label = heatColor(u.BusyPercent), fmt.Sprintf("%.0f%%", u.BusyPercent)
+		}
+		cv.drawRoom(room, r, withAlpha(fill, heatAlpha), fill, 1)
+		drawLabel(img, label, center(r))
+	}
+	drawHeatLegend(img)
+}
+
+// heatColor maps a busy percentage onto the green-yellow-red scale.
+func heatColor(percent float64) color.NRGBA {
+	t := max(0, min(percent, 100)) / 100
+	if t < 0.5 {
+		return lerp(heatLow, heatMid, t*2)
+	}
+	return lerp(heatMid, heatHigh, (t-0.5)*2)
+}
+
+func lerp(a, b color.NRGBA, t float64) color.NRGBA {
+	mix := func(x, y uint8) uint8 { return uint8(float64(x) + (float64(y)-float64(x))*t + 0.5) }
+	return color.NRGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// drawHeatLegend draws the color scale in the bottom-left corner, if it fits.
+func drawHeatLegend(img *image.NRGBA) {
+	const width, height, margin = 120, 10, 8
+	b := img.Bounds()
+	if b.Dx() < width+2*margin+60 || b.Dy() < height+2*margin+16 {
+		return
+	}
+	bar := image.Rect(margin+24, b.Max.Y-margin-height-4, margin+24+width, b.Max.Y-margin-4)
+	fillRect(img, image.Rect(margin, bar.Min.Y-4, bar.Max.X+36, bar.Max.Y+4), color.NRGBA{255, 255, 255, 220})
+	for x := bar.Min.X; x < bar.Max.X; x++ {
+		fillRect(img, image.Rect(x, bar.Min.Y, x+1, bar.Max.Y), heatColor(float64(x-bar.Min.X)/float64(width-1)*100))
+	}
+	drawText(img, "0%", image.Pt(margin+2, bar.Max.Y))
+	drawText(img, "100%", image.Pt(bar.Max.X+4, bar.Max.Y))
+}
+
+// roomRect returns room's rectangle clipped to img.
+func roomRect(img image.Image, room models.Room) (image.Rectangle, bool) {
+	if len(room.Rect) != 4 {
+		return image.Rectangle{}, false
+	}
+	r := image.Rect(room.Rect[0], room.Rect[1], room.Rect[0]+room.Rect[2], room.Rect[1]+room.Rect[3]).Intersect(img.Bounds())
+	return r, !r.Empty()
+}
+
+func withAlpha(c color.NRGBA, alpha uint8) color.NRGBA {
+	c.A = alpha
+	return c
+}
+
+func center(r image.Rectangle) image.Point {
+	return image.Pt((r.Min.X+r.Max.X)/2, (r.Min.Y+r.Max.Y)/2)
+}
+
+// fillRect blends c over r.
+func fillRect(img draw.Image, r image.Rectangle, c color.NRGBA) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// strokeRect outlines r with lines of the given width, inside r.
+func strokeRect(img draw.Image, r image.Rectangle, c color.NRGBA, width int) {
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+width), c)
+	fillRect(img, image.Rect(r.Min.X, r.Max.Y-width, r.Max.X, r.Max.Y), c)
+	fillRect(img, image.Rect(r.Min.X, r.Min.Y+width, r.Min.X+width, r.Max.Y-width), c)
+	fillRect(img, image.Rect(r.Max.X-width, r.Min.Y+width, r.Max.X, r.Max.Y-width), c)
+}
+
+// canvas is an image being annotated. One rasterizer is reused for every
+// filled shape and sized to the shape's bounding box, so outlining many small
+// rooms on a large plan stays cheap.
+type canvas struct {
+	img    *image.NRGBA
+	raster vector.Rasterizer
+}
+
+// vec is a point in image pixels.
+type vec struct{ x, y float64 }
+
+// drawRoom blends fill over room, whose rect clipped to the image is r, and
+// outlines it with stroke. Polygon rooms are drawn along their polygon.
+func (cv *canvas) drawRoom(room models.Room, r image.Rectangle, fill, stroke color.NRGBA, width int) {
+	if len(room.Polygon) < 3 {
+		fillRect(cv.img, r, fill)
+		strokeRect(cv.img, r, stroke, width)
+		return
+	}
+	outline := make([]vec, len(room.Polygon))
+	for i, p := range room.Polygon {
+		outline[i] = vec{float64(p[0]), float64(p[1])}
+	}
+	cv.fill(fill, [][]vec{outline})
+
+	// Each edge becomes a quad of the given width, centered on the edge and
+	// extended past its ends so corners join; all are filled at once.
+	half := float64(width) / 2
+	var quads [][]vec
+	for i, a := range outline {
+		b := outline[(i+1)%len(outline)]
+		dx, dy := b.x-a.x, b.y-a.y
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		nx, ny := -dy/length*half, dx/length*half
+		ex, ey := dx/length*half, dy/length*half
+		quads = append(quads, []vec{
+			{a.x - ex + nx, a.y - ey + ny}, {b.x + ex + nx, b.y + ey + ny},
+			{b.x + ex - nx, b.y + ey - ny}, {a.x - ex - nx, a.y - ey - ny},
+		})
+	}
+	cv.fill(stroke, quads)
+}
+
+// maxOffImage is how far outside the image, in pixels, points are clamped
+// before rasterizing: the rasterizer's fixed-point math overflows on
+// coordinates much further out, and no real room outline reaches this far.
+const maxOffImage = 1 << 20
+
+// fill blends c over the union of polys.
+func (cv *canvas) fill(c color.NRGBA, polys [][]vec) {
+	b := cv.img.Bounds()
+	clamp := func(p vec) vec {
+		return vec{
+			min(max(p.x, float64(b.Min.X-maxOffImage)), float64(b.Max.X+maxOffImage)),
+			min(max(p.y, float64(b.Min.Y-maxOffImage)), float64(b.Max.Y+maxOffImage)),
+		}
+	}
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polys {
+		for i, p := range poly {
+			p = clamp(p)
+			poly[i] = p
+			minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+			maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+		}
+	}
+	box := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).Intersect(b)
+	if box.Empty() {
+		return
+	}
+
+	ox, oy := float64(box.Min.X), float64(box.Min.Y)
+	cv.raster.Reset(box.Dx(), box.Dy())
+	cv.raster.DrawOp = draw.Over
+	for _, poly := range polys {
+		if len(poly) < 3 {
+			continue
+		}
+		cv.raster.MoveTo(float32(poly[0].x-ox), float32(poly[0].y-oy))
+		for _, p := range poly[1:] {
+			cv.raster.LineTo(float32(p.x-ox), float32(p.y-oy))
+		}
+		cv.raster.ClosePath()
+	}
+	cv.raster.Draw(cv.img, box, image.NewUniform(c), box.Min)
+}
+
+// labelFace is the built-in bitmap font used for labels.
+var labelFace = basicfont.Face7x13
+
+// drawLabel draws text centered on at, on a translucent white box so it stays
+// readable over any background.
+func drawLabel(img draw.Image, text string, at image.Point) {
+	width := font.MeasureString(labelFace, text).Ceil()
+	height := labelFace.Metrics().Height.Ceil()
+	box := image.Rect(at.X-width/2-2, at.Y-height/2-1, at.X+width/2+3, at.Y+height/2+1)
+	fillRect(img, box, color.NRGBA{255, 255, 255, 200})
+	drawText(img, text, image.Pt(box.Min.X+2, box.Max.Y-3))
+}
+
+// drawText draws text with its baseline starting at dot.
+func drawText(img draw.Image, text string, dot image.Point) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(labelText), Face: labelFace, Dot: fixed.P(dot.X, dot.Y)}
+	d.DrawString(text)
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/occupancy"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RoomStatusRequest reports the live state of one room. At least one field is required.
+type RoomStatusRequest struct {
+	Status    models.RoomStatus `json:"status"`
+	Occupancy *int              `json:"occupancy"`
+}
+
+// BulkRoomStatusRequest reports the live state of several rooms of one floorplan.
+type BulkRoomStatusRequest struct {
+	Rooms []occupancy.Change `json:"rooms" binding:"required,min=1"`
+}
+
+// BulkRoomStatusResponse lists the rooms after applying a bulk update.
+type BulkRoomStatusResponse struct {
+	Rooms []models.Room `json:"rooms"`
+}
+
+// StatusHandler serves the live status ingestion endpoints used by sensors
+// and booking systems.
+type StatusHandler struct {
+	service *occupancy.Service
+}
+
+// NewStatusHandler creates a StatusHandler that applies changes through service.
+func NewStatusHandler(service *occupancy.Service) *StatusHandler {
+	return &StatusHandler{service: service}
+}
+
+// SetRoomStatus godoc
+// @Summary Report a room's live status
+// @Description Set the status and/or occupancy count of a room. The stored room is updated and a status_update is pushed to websocket clients subscribed to the floorplan.
+// @ID setRoomStatus
+// @Tags status
+// @Accept json
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param roomId path string true "Room ID"
+// @Param request body RoomStatusRequest true "New status and/or occupancy"
+// @Success 200 {object} models.Room "Updated room"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/rooms/{roomId}/status [post]
+func (h *StatusHandler) SetRoomStatus(c *gin.Context) {
+	var req RoomStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	rooms, ok := h.apply(c, []occupancy.Change{{RoomID: c.Param("roomId"), Status: req.Status, Occupancy: req.Occupancy}})
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rooms[0])
+}
+
+// SetRoomStatuses godoc
+// @Summary Report several rooms' live status
+// @Description Set the status and/or occupancy of several rooms at once. All changes are validated and stored together; each changed room is pushed to subscribed websocket clients.
+// @ID setRoomStatuses
+// @Tags status
+// @Accept json
+// @Produce json
+// @Param id path string true "Floorplan ID"
+// @Param request body BulkRoomStatusRequest true "Changes by room ID"
+// @Success 200 {object} BulkRoomStatusResponse "Updated rooms"
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /api/v1/floorplans/{id}/status [post]
+func (h *StatusHandler) SetRoomStatuses(c *gin.Context) {
+	var req BulkRoomStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	rooms, ok := h.apply(c, req.Rooms)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, BulkRoomStatusResponse{Rooms: rooms})
+}
+
+// apply runs changes against the :id floorplan, writing an error response and
+// returning false on failure.
+func (h *StatusHandler) apply(c *gin.Context, changes []occupancy.Change) ([]models.Room, bool) {
+	rooms, err := h.service.Apply(c.Request.Context(), c.Param("id"), changes)
+	switch {
+	case err == nil:
+		return rooms, true
+	case errors.Is(err, store.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Floorplan not found"})
+	case errors.Is(err, occupancy.ErrRoomNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Room not found"})
+	case errors.Is(err, occupancy.ErrInvalidChange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update room status: " + err.Error()})
+	}
+	return nil, false
+}
@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/occupancy"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newStatusRouter(t *testing.T) (*gin.Engine, store.FloorplanRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	h := NewStatusHandler(occupancy.NewService(repo, repo, nil))
+
+	r := gin.New()
+	r.POST("/floorplans/:id/rooms/:roomId/status", h.SetRoomStatus)
+	r.POST("/floorplans/:id/status", h.SetRoomStatuses)
+	r.GET("/floorplans/:id/rooms/:roomId/history", h.GetRoomHistory)
+	r.GET("/floorplans/:id/rooms/:roomId/utilization", h.GetRoomUtilization)
+	return r, repo
+}
+
+func TestSetRoomStatus(t *testing.T) {
+	r, repo := newStatusRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	roomPath := "/floorplans/" + fp.ID + "/rooms/" + fp.Rooms[0].ID + "/status"
+
+	w := doJSON(r, http.MethodPost, roomPath, gin.H{"status": "BUSY", "occupancy": 3})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var room models.Room
+	json.Unmarshal(w.Body.Bytes(), &room)
+	if room.Status != models.RoomStatusBusy || room.Occupancy != 3 || room.Name != "Office 101" {
+		t.Errorf("room = %+v", room)
+	}
+
+	cases := []struct {
+		path string
+		body gin.H
+		want int
+	}{
+		{roomPath, gin.H{"status": "ON_FIRE"}, http.StatusBadRequest},
+		{roomPath, gin.H{}, http.StatusBadRequest},
+		{"/floorplans/" + fp.ID + "/rooms/missing/status", gin.H{"status": "BUSY"}, http.StatusNotFound},
+		{"/floorplans/missing/rooms/x/status", gin.H{"status": "BUSY"}, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		if w := doJSON(r, http.MethodPost, tc.path, tc.body); w.Code != tc.want {
+			t.Errorf("POST %s %v: status = %d, want %d", tc.path, tc.body, w.Code, tc.want)
+		}
+	}
+}
+
+func TestSetRoomStatuses_Bulk(t *testing.T) {
+	r, repo := newStatusRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	roomID := fp.Rooms[0].ID
+
+	w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/status", gin.H{"rooms": []gin.H{
+		{"room_id": roomID, "status": "OFFLINE", "occupancy": 0},
+	}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var resp BulkRoomStatusResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Rooms) != 1 || resp.Rooms[0].Status != models.RoomStatusOffline {
+		t.Errorf("response = %+v", resp)
+	}
+
+	if w := doJSON(r, http.MethodPost, "/floorplans/"+fp.ID+"/status", gin.H{"rooms": []gin.H{}}); w.Code != http.StatusBadRequest {
+		t.Errorf("empty bulk request status = %d, want 400", w.Code)
+	}
+}
+
+func TestGetRoomHistoryAndUtilization(t *testing.T) {
+	r, repo := newStatusRouter(t)
+	fp := seedFloorplan(t, repo, time.Now())
+	roomPath := "/floorplans/" + fp.ID + "/rooms/" + fp.Rooms[0].ID
+
+	if w := doJSON(r, http.MethodPost, roomPath+"/status", gin.H{"status": "BUSY", "occupancy": 2}); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	w := doJSON(r, http.MethodGet, roomPath+"/history", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("history status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var history occupancy.RoomHistory
+	json.Unmarshal(w.Body.Bytes(), &history)
+	if len(history.Changes) != 1 || history.Changes[0].Status != models.RoomStatusBusy || history.Changes[0].Occupancy != 2 {
+		t.Errorf("history = %+v", history)
+	}
+
+	w = doJSON(r, http.MethodGet, roomPath+"/utilization?interval=day&tz=Europe/Berlin", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("utilization status = %d, body = %s", w.Code, w.Body.String())
+	}
+	var u occupancy.Utilization
+	json.Unmarshal(w.Body.Bytes(), &u)
+	if u.Interval != occupancy.IntervalDay || len(u.Buckets) == 0 || len(u.Buckets) > 2 {
+		t.Errorf("utilization = %+v, want one or two day buckets", u)
+	}
+
+	for path, want := range map[string]int{
+		roomPath + "/history?from=yesterday":                                    http.StatusBadRequest,
+		roomPath + "/history?from=2026-03-02T10:00:00Z&to=2026-03-02T09:00:00Z": http.StatusBadRequest,
+		roomPath + "/utilization?interval=week":                                 http.StatusBadRequest,
+		roomPath + "/utilization?tz=Mars/Olympus":                               http.StatusBadRequest,
+		"/floorplans/" + fp.ID + "/rooms/missing/history":                       http.StatusNotFound,
+		"/floorplans/missing/rooms/x/utilization":                               http.StatusNotFound,
+	} {
+		if w := doJSON(r, http.MethodGet, path, nil); w.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
@@ -2,8 +2,8 @@ package handler
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
@@ -12,33 +12,112 @@ import (
 	"io"
 	"net/http"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/export"
 	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
 
 	"github.com/disintegration/imaging"
 	"github.com/gin-gonic/gin"
 )
 
+// FloorplanHandler serves the endpoints that create and read persisted floorplans.
+type FloorplanHandler struct {
+	repo            store.FloorplanRepository
+	detectors       map[string]ai.RoomDetector
+	defaultDetector string
+	pdf             pdfraster.Options
+	edge            ai.EdgeDetectionOptions
+}
+
+// NewFloorplanHandler creates a FloorplanHandler backed by the given repository.
+// detectors maps the names accepted by the upload "detector" query parameter to
+// providers; defaultDetector names the one used when the parameter is omitted.
+// PDF uploads are rasterized with pdf before detection, and edge finds the
+// content box of uploads whose detector does not report one.
+func NewFloorplanHandler(repo store.FloorplanRepository, detectors map[string]ai.RoomDetector, defaultDetector string, pdf pdfraster.Options, edge ai.EdgeDetectionOptions) *FloorplanHandler {
+	return &FloorplanHandler{repo: repo, detectors: detectors, defaultDetector: defaultDetector, pdf: pdf, edge: edge}
+}
+
+// selectDetector resolves the "detector" query parameter, writing a 400
+// response and returning false when the name is unknown.
+func (h *FloorplanHandler) selectDetector(c *gin.Context) (ai.RoomDetector, bool) {
+	name := c.DefaultQuery("detector", h.defaultDetector)
+	detector, ok := h.detectors[name]
+	if !ok {
+		names := make([]string, 0, len(h.detectors))
+		for n := range h.detectors {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown detector %q. Available: %s", name, strings.Join(names, ", "))})
+		return nil, false
+	}
+	return detector, true
+}
+
+// errRasterize marks analysis failures caused by an unreadable or oversized PDF.
+var errRasterize = errors.New("failed to rasterize PDF")
+
 // UploadFloorplan godoc
-// @Summary Upload a floorplan image
-// @Description Upload a floorplan image (PNG, JPG, JPEG) and return the detected rooms
+// @Summary Upload a floorplan image or PDF
+// @Description Upload a floorplan image (PNG, JPG, JPEG) or PDF, detect its rooms and persist the result. Each PDF page is rasterized server-side, text included, and becomes its own floorplan; if any page fails, none are saved. PDF uploads respond with {"floorplans": [...]}, one entry per page in the image response shape plus "page".
 // @ID uploadFloorplan
 // @Tags upload
 // @Accept multipart/form-data
 // @Produce json
-// @Param file formData file true "Floorplan image file"
+// @Param file formData file true "Floorplan image or PDF file"
+// @Param detector query string false "Room detector: gemini (Vertex AI) or classical (offline edge-based)"
 // @Success 200 {object} map[string]interface{} "Detection results with rooms"
 // @Failure 400 {object} map[string]string "Bad request"
 // @Failure 500 {object} map[string]string "Internal server error"
 // @Router /api/v1/upload [post]
-func UploadFloorplan(c *gin.Context) {
+func (h *FloorplanHandler) UploadFloorplan(c *gin.Context) {
+	upload, ok := h.readUpload(c)
+	if !ok {
+		return
+	}
+
+	floorplans, err := h.analyzeUpload(c.Request.Context(), upload, nil)
+	if errors.Is(err, errRasterize) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, analysisResponse(upload, floorplans))
+}
+
+// floorplanUpload is a validated upload waiting to be analyzed.
+type floorplanUpload struct {
+	filename string
+	data     []byte
+	mimeType string
+	pdf      bool // Rasterize and analyze each page separately
+	detector ai.RoomDetector
+}
+
+// readUpload reads and validates the multipart "file" field and the detector
+// selection, writing an error response and returning false when invalid.
+func (h *FloorplanHandler) readUpload(c *gin.Context) (*floorplanUpload, bool) {
+	detector, ok := h.selectDetector(c)
+	if !ok {
+		return nil, false
+	}
+
 	// 1. Get file from request
 	file, header, err := c.Request.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-		return
+		return nil, false
 	}
 	defer file.Close()
 
@@ -46,7 +125,7 @@ func UploadFloorplan(c *gin.Context) {
 	buf := bytes.NewBuffer(nil)
 	if _, err := io.Copy(buf, file); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-		return
+		return nil, false
 	}
 
 	fileBytes := buf.Bytes()
@@ -54,253 +133,271 @@ func UploadFloorplan(c *gin.Context) {
 	mimeType := header.Header.Get("Content-Type")
 
 	// Validate extension/mime
-	isValid := false
+	isValid, isPDF := false, false
 	if ext == ".png" || ext == ".jpg" || ext == ".jpeg" {
 		isValid = true
 		if mimeType == "" {
 			mimeType = "image/png" // Default fallback
 		}
 	} else if ext == ".pdf" {
-		// DEPRECATED: PDF should be converted on frontend now.
-		// But if sent, we reject it as we removed backend rendering.
-		c.JSON(http.StatusBadRequest, gin.H{"error": "PDF files should be processed by client. Please retry."})
-		return
+		if !pdfraster.IsPDF(fileBytes) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid PDF file."})
+			return nil, false
+		}
+		isValid, isPDF = true, true
+		mimeType = "application/pdf"
 	}
 
 	if !isValid {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only PNG and JPG are supported."})
-		return
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file type. Only PNG, JPG and PDF are supported."})
+		return nil, false
 	}
 
-	// 3. Call AI Service
-	jsonResponse, err := ai.AnalyzeFloorplan(c.Request.Context(), fileBytes, mimeType)
-	if err != nil {
-		fmt.Printf("AI Error: %v\n", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze floorplan: " + err.Error()})
-		return
+	return &floorplanUpload{
+		filename: header.Filename,
+		data:     fileBytes,
+		mimeType: mimeType,
+		pdf:      isPDF,
+		detector: detector,
+	}, true
+}
+
+// analyzeUpload detects rooms, crops and remaps the image and persists the
+// resulting floorplan. A PDF yields one floorplan per page, in page order;
+// pages are rendered and analyzed one at a time, and either all of them are
+// saved or none. progress, when non-nil, is told which stage is running.
+// Returned errors are already phrased for API clients.
+func (h *FloorplanHandler) analyzeUpload(ctx context.Context, upload *floorplanUpload, progress func(stage string)) ([]*models.Floorplan, error) {
+	if progress == nil {
+		progress = func(string) {}
 	}
 
-	// 4. Process Image and Remap Coordinates
-	finalImageBase64, remappedRooms, err := processAndRemap(fileBytes, jsonResponse, mimeType)
-	if err != nil {
-		// Send a specific error message back to the frontend
-		errorMsg := "Failed to process image after analysis: " + err.Error()
-		fmt.Println("Processing Error:", errorMsg) // Keep server log
-		c.JSON(http.StatusInternalServerError, gin.H{"error": errorMsg})
-		return
+	pageCount := 1
+	var doc *pdfraster.Document
+	if upload.pdf {
+		var err error
+		doc, err = pdfraster.Open(upload.data, h.pdf)
+		if err != nil {
+			fmt.Printf("PDF Error: %v\n", err)
+			return nil, fmt.Errorf("%w: %w", errRasterize, err)
+		}
+		pageCount = doc.PageCount()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"rooms": remappedRooms,
-		"image": finalImageBase64,
-	})
-}
+	floorplans := make([]*models.Floorplan, 0, pageCount)
+	for i := 0; i < pageCount; i++ {
+		stage := func(name string) string {
+			if !upload.pdf {
+				return name
+			}
+			return fmt.Sprintf("%s page %d/%d", name, i+1, pageCount)
+		}
 
-// GeminiResponse matches the JSON structure returned by Gemini
-type GeminiResponse struct {
-	Rooms []GeminiRoom `json:"rooms"`
-}
+		page, mimeType := upload.data, upload.mimeType
+		if doc != nil {
+			progress(stage("rasterizing"))
+			var err error
+			page, err = renderPNG(doc, i+1)
+			if err != nil {
+				fmt.Printf("PDF Error: %v\n", err)
+				return nil, fmt.Errorf("%w: %w", errRasterize, err)
+			}
+			mimeType = "image/png"
+		}
 
-func processAndRemap(fileBytes []byte, jsonStr string, mimeType string) (string, []models.Room, error) {
-	// A. Parse JSON
-	aiData, err := parseGeminiResponse(jsonStr)
-	if err != nil {
-		return "", nil, fmt.Errorf("json parse error: %w", err)
-	}
+		// 3. Detect rooms
+		progress(stage("detecting"))
+		detected, err := upload.detector.DetectRooms(ctx, page, mimeType)
+		if err != nil {
+			fmt.Printf("AI Error: %v\n", err)
+			return nil, fmt.Errorf("failed to analyze floorplan: %w", err)
+		}
 
-	// B. Decode Image
-	img, _, err := image.Decode(bytes.NewReader(fileBytes))
-	if err != nil {
-		return "", nil, fmt.Errorf("image decode error: %w", err)
+		// 4. Process Image and Remap Coordinates
+		progress(stage("processing"))
+		floorplan, err := processAndRemap(store.NewID(), page, detected, h.edge)
+		if err != nil {
+			fmt.Printf("Processing Error: %v\n", err)
+			return nil, fmt.Errorf("failed to process image after analysis: %w", err)
+		}
+		if upload.pdf {
+			floorplan.Page = i + 1
+		}
+		floorplans = append(floorplans, floorplan)
 	}
 
-	// C. Calculate Crop and Remap
-	bounds := img.Bounds()
-	cropRect, remappedRooms, err := CalculateCropAndRemap(bounds.Dx(), bounds.Dy(), aiData.Rooms)
-	if err != nil {
-		return "", nil, fmt.Errorf("remap error: %w", err)
+	// 5. Persist the floorplans so they can be reloaded later
+	progress("saving")
+	now := time.Now().UTC()
+	for _, floorplan := range floorplans {
+		floorplan.Filename = upload.filename
+		floorplan.CreatedAt = now
+	}
+	if err := h.repo.Create(ctx, floorplans...); err != nil {
+		fmt.Printf("Store Error: %v\n", err)
+		return nil, fmt.Errorf("failed to save floorplan: %w", err)
 	}
 
-	// D. Crop Image
-	croppedImg := imaging.Crop(img, cropRect)
+	return floorplans, nil
+}
 
-	// E. Encode Cropped Image to Base64
-	var buf bytes.Buffer
-	err = png.Encode(&buf, croppedImg)
+// renderPNG renders one page of a PDF and encodes it as PNG.
+func renderPNG(doc *pdfraster.Document, pageNr int) ([]byte, error) {
+	img, err := doc.Render(pageNr)
 	if err != nil {
-		return "", nil, fmt.Errorf("image encode error: %w", err)
+		return nil, err
 	}
-	encodedString := base64.StdEncoding.EncodeToString(buf.Bytes())
 
-	return "data:image/png;base64," + encodedString, remappedRooms, nil
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("image encode error: %w", err)
+	}
+	return buf.Bytes(), nil
 }
 
-func parseGeminiResponse(jsonStr string) (GeminiResponse, error) {
-	clean := cleanAIJSON(jsonStr)
-
-	// Pass 1: clean JSON parses directly.
-	var aiData GeminiResponse
-	if err := json.Unmarshal([]byte(clean), &aiData); err == nil {
-		return aiData, nil
+// uploadResponse is the body returned by /upload for a single image and the
+// per-page entry for PDFs.
+func uploadResponse(floorplan *models.Floorplan) gin.H {
+	rooms := append([]models.Room(nil), floorplan.Rooms...)
+	for i := range rooms {
+		rooms[i].Measure(floorplan.PixelsPerMeter)
 	}
-
-	// Pass 2: strip leading/trailing noise then parse.
-	if objectJSON, err := extractFirstJSONObject(clean); err == nil {
-		if err2 := json.Unmarshal([]byte(objectJSON), &aiData); err2 == nil {
-			return aiData, nil
-		}
+	resp := gin.H{
+		"id":     floorplan.ID,
+		"width":  floorplan.Width,
+		"height": floorplan.Height,
+		"rooms":  rooms,
+		"image":  floorplan.ImageURL,
 	}
-
-	// Pass 3: repair truncated brackets/braces then re-try passes 1+2.
-	if repairedJSON, err := repairTruncatedJSONObject(clean); err == nil {
-		if err2 := json.Unmarshal([]byte(repairedJSON), &aiData); err2 == nil {
-			return aiData, nil
-		}
-		if objectJSON, err2 := extractFirstJSONObject(repairedJSON); err2 == nil {
-			if err3 := json.Unmarshal([]byte(objectJSON), &aiData); err3 == nil {
-				return aiData, nil
-			}
-		}
+	if floorplan.Walls != nil {
+		resp["walls"] = floorplan.Walls
 	}
-
-	// Pass 4: last resort — scan rooms array and keep each fully-parsed object,
-	// discarding only the final truncated entry. Returns a partial result rather
-	// than a hard failure so the caller always gets whatever rooms were complete.
-	if rooms := extractPartialRooms(clean); len(rooms) > 0 {
-		fmt.Printf("[parser] recovered %d partial room(s) from truncated response\n", len(rooms))
-		return GeminiResponse{Rooms: rooms}, nil
+	if floorplan.Doors != nil {
+		resp["doors"] = floorplan.Doors
 	}
-
-	return GeminiResponse{}, errors.New("unable to parse model JSON response")
-}
-
-// extractPartialRooms scans the raw string for the rooms array and decodes
-// each element individually with json.Decoder, stopping at the first error.
-// This recovers all complete room objects even when the response is truncated
-// mid-way through the last entry.
-func extractPartialRooms(value string) []GeminiRoom {
-	// Locate the start of the rooms array.
-	idx := strings.Index(value, `"rooms"`)
-	if idx == -1 {
-		return nil
+	if floorplan.Windows != nil {
+		resp["windows"] = floorplan.Windows
 	}
-	arrayStart := strings.Index(value[idx:], "[")
-	if arrayStart == -1 {
-		return nil
+	if floorplan.ContentBox != nil {
+		resp["content_box"] = floorplan.ContentBox
 	}
-	arrayStart += idx
-
-	dec := json.NewDecoder(strings.NewReader(value[arrayStart:]))
-
-	// Consume the opening '['
-	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
-		return nil
+	if floorplan.PixelsPerMeter > 0 {
+		resp["pixels_per_meter"] = floorplan.PixelsPerMeter
 	}
-
-	var rooms []GeminiRoom
-	for dec.More() {
-		var room GeminiRoom
-		if err := dec.Decode(&room); err != nil {
-			break // truncated object — stop, keep what we have
-		}
-		if len(room.Rect) == 4 && room.Name != "" {
-			rooms = append(rooms, room)
-		}
+	if floorplan.Page > 0 {
+		resp["page"] = floorplan.Page
 	}
-	return rooms
+	return resp
 }
 
-func cleanAIJSON(jsonStr string) string {
-	clean := strings.TrimSpace(jsonStr)
-	clean = strings.ReplaceAll(clean, "```json", "")
-	clean = strings.ReplaceAll(clean, "```", "")
-	return strings.TrimSpace(clean)
+// analysisResponse is the body returned by /upload:
+// the single floorplan for images, {"floorplans": [...]} for PDFs.
+func analysisResponse(upload *floorplanUpload, floorplans []*models.Floorplan) gin.H {
+	if !upload.pdf {
+		return uploadResponse(floorplans[0])
+	}
+	pages := make([]gin.H, 0, len(floorplans))
+	for _, floorplan := range floorplans {
+		pages = append(pages, uploadResponse(floorplan))
+	}
+	return gin.H{"floorplans": pages}
 }
 
-func extractFirstJSONObject(value string) (string, error) {
-	start := strings.Index(value, "{")
-	if start == -1 {
-		return "", fmt.Errorf("no JSON object found in model response")
+// processAndRemap crops the uploaded image to its content box, the
+// detector's or else one found from its edges, and remaps the detection onto
+// it. The caller fills in Filename and CreatedAt.
+func processAndRemap(floorplanID string, fileBytes []byte, detection *ai.Detection, edge ai.EdgeDetectionOptions) (*models.Floorplan, error) {
+	// A. Decode Image
+	img, _, err := image.Decode(bytes.NewReader(fileBytes))
+	if err != nil {
+		return nil, fmt.Errorf("image decode error: %w", err)
 	}
+	bounds := img.Bounds()
 
-	decoder := json.NewDecoder(strings.NewReader(value[start:]))
-	var raw json.RawMessage
-	if err := decoder.Decode(&raw); err != nil {
-		return "", fmt.Errorf("incomplete JSON object in model response")
+	// B. Find the content box, falling back to edge detection
+	contentBox := detection.ContentBox
+	if !validBox(contentBox) {
+		edgeImg, err := detectFloorplanEdges(img, edge)
+		if err != nil {
+			return nil, fmt.Errorf("edge detection error: %w", err)
+		}
+		contentBox = relativeBox(mainContentRect(img, edgeImg).Sub(bounds.Min), bounds.Dx(), bounds.Dy())
 	}
 
-	if len(raw) == 0 || raw[0] != '{' {
-		return "", fmt.Errorf("first JSON value is not an object")
+	// C. Calculate Crop and Remap
+	imageKey := ImageKey(fileBytes)
+	cropRect, remappedRooms, err := CalculateCropAndRemap(floorplanID, imageKey, bounds.Dx(), bounds.Dy(), contentBox, detection.Rooms)
+	if err != nil {
+		return nil, fmt.Errorf("remap error: %w", err)
 	}
 
-	return strings.TrimSpace(string(raw)), nil
-}
+	walls, doors, windows := RemapStructure(imageKey, bounds.Dx(), bounds.Dy(), cropRect, detection, remappedRooms)
 
-func repairTruncatedJSONObject(value string) (string, error) {
-	start := strings.Index(value, "{")
-	if start == -1 {
-		return "", fmt.Errorf("no JSON object found in model response")
-	}
+	// D. Crop Image
+	croppedImg := imaging.Crop(img, cropRect.Add(bounds.Min))
 
-	candidate := strings.TrimSpace(value[start:])
-	if candidate == "" {
-		return "", fmt.Errorf("empty JSON candidate in model response")
+	// E. Encode Cropped Image to Base64
+	var buf bytes.Buffer
+	err = png.Encode(&buf, croppedImg)
+	if err != nil {
+		return nil, fmt.Errorf("image encode error: %w", err)
 	}
+	encodedString := base64.StdEncoding.EncodeToString(buf.Bytes())
 
-	// stack tracks closing tokens needed in reverse open order.
-	stack := make([]byte, 0, 16)
-	inString := false
-	escaped := false
-
-	for i := 0; i < len(candidate); i++ {
-		ch := candidate[i]
+	croppedBounds := croppedImg.Bounds()
+	return &models.Floorplan{
+		ID:             floorplanID,
+		ImageURL:       "data:image/png;base64," + encodedString,
+		Width:          croppedBounds.Dx(),
+		Height:         croppedBounds.Dy(),
+		Rooms:          remappedRooms,
+		Walls:          walls,
+		Doors:          doors,
+		Windows:        windows,
+		PixelsPerMeter: scalePixelsPerMeter(bounds.Dx(), bounds.Dy(), detection.Scale),
+		ContentBox: &models.ContentBox{
+			OriginalWidth:  bounds.Dx(),
+			OriginalHeight: bounds.Dy(),
+			Bounds:         relativeBox(cropRect, bounds.Dx(), bounds.Dy()),
+			Crop:           models.Rect{cropRect.Min.X, cropRect.Min.Y, cropRect.Dx(), cropRect.Dy()},
+		},
+	}, nil
+}
 
-		if inString {
-			if escaped {
-				escaped = false
-				continue
-			}
-			if ch == '\\' {
-				escaped = true
-				continue
-			}
-			if ch == '"' {
-				inString = false
-			}
+// RenderOverlayPNG draws fp's cropped image with every room outlined in the
+// color of its type or status (see colorBy) and labeled with its name.
+func RenderOverlayPNG(fp *models.Floorplan, colorBy OverlayColorBy) ([]byte, error) {
+	img, err := floorplanImage(fp)
+	if err != nil {
+		return nil, err
+	}
+	cv := &canvas{img: img}
+	for _, room := range fp.Rooms {
+		r, ok := roomRect(img, room)
+		if !ok {
 			continue
 		}
-
-		switch ch {
-		case '"':
-			inString = true
-		case '{':
-			stack = append(stack, '}')
-		case '}':
-			if len(stack) == 0 || stack[len(stack)-1] != '}' {
-				return "", fmt.Errorf("invalid JSON object nesting in model response")
-			}
-			stack = stack[:len(stack)-1]
-		case '[':
-			stack = append(stack, ']')
-		case ']':
-			if len(stack) == 0 || stack[len(stack)-1] != ']' {
-				return "", fmt.Errorf("invalid JSON array nesting in model response")
-			}
-			stack = stack[:len(stack)-1]
-		}
+		c := roomColor(room, colorBy)
+		cv.drawRoom(room, r, withAlpha(c, 48), c, 2)
+		drawLabel(img, room.Label(), center(r))
 	}
 
-	if inString {
-		// Close the truncated string before closing open structures.
-		candidate += `"`
-	}
-
-	candidate = strings.TrimRight(candidate, " \t\r\n,")
-
-	// Close in reverse stack order so brackets/braces interleave correctly.
-	for i := len(stack) - 1; i >= 0; i-- {
-		candidate += string(stack[i])
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("image encode error: %w", err)
 	}
+	return buf.Bytes(), nil
+}
 
-	return candidate, nil
+// RenderOverlaySVG is RenderOverlayPNG as an SVG document in image pixels:
+// the stored image is embedded as the background and rooms are drawn as
+// vectors, so labels stay sharp at any zoom.
+func RenderOverlaySVG(fp *models.Floorplan, colorBy OverlayColorBy) []byte {
+	return export.SVG(fp, export.SVGOptions{
+		IncludeImage: true,
+		RoomColor: func(room models.Room) string {
+			c := roomColor(room, colorBy)
+			return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+		},
+	})
 }
@@ -1,91 +1,486 @@
 package handler
 
-import "testing"
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
 
-func TestParseGeminiResponse_CleanJSON(t *testing.T) {
-	input := "```json\n{\"rooms\":[{\"name\":\"Office 101\",\"type\":\"OFFICE\",\"rect\":[10,20,100,200]}]}\n```"
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/pdfraster"
+	"floorplan-whiteboard/store"
 
-	got, err := parseGeminiResponse(input)
+	"github.com/gin-gonic/gin"
+)
+
+// newUploadRequest builds a multipart upload of a blank PNG of the given size.
+func newUploadRequest(t *testing.T, path, filename string, w, h int) *http.Request {
+	t.Helper()
+
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatalf("png.Encode() error = %v", err)
+	}
+	return newFileUploadRequest(path, filename, img.Bytes())
+}
+
+// newFileUploadRequest builds a multipart upload of data as the "file" field.
+func newFileUploadRequest(path, filename string, data []byte) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, _ := mw.CreateFormFile("file", filename)
+	part.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestUploadFloorplan_WithStaticDetector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	detector := ai.StaticDetector{Rooms: []ai.GeminiRoom{
+		{Name: "Office 101", Type: "OFFICE", Rect: []int{0, 0, 500, 500}},
+		{Name: "Meeting Room A", Type: "MEETING", Rect: []int{500, 500, 1000, 1000}},
+	}}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/upload", "plan.png", 200, 100))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		ID     string `json:"id"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+		Rooms  []struct {
+			ID          string `json:"id"`
+			FloorplanID string `json:"floorplan_id"`
+			Rect        []int  `json:"rect"`
+		} `json:"rooms"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	if resp.ID == "" || resp.Width != 200 || resp.Height != 100 || len(resp.Rooms) != 2 {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+	if resp.Rooms[1].FloorplanID != resp.ID || resp.Rooms[1].ID == "" {
+		t.Fatalf("room not linked to floorplan: %+v", resp.Rooms[1])
+	}
+	if got := resp.Rooms[1].Rect; got[0] != 100 || got[1] != 50 || got[2] != 100 || got[3] != 50 {
+		t.Fatalf("room rect = %v, want [100 50 100 50]", got)
+	}
+
+	stored, err := repo.Get(context.Background(), resp.ID)
 	if err != nil {
-		t.Fatalf("parseGeminiResponse() error = %v", err)
+		t.Fatalf("uploaded floorplan was not persisted: %v", err)
+	}
+	if stored.Filename != "plan.png" || len(stored.Rooms) != 2 {
+		t.Fatalf("stored floorplan = %+v", stored)
+	}
+}
+
+func TestUploadFloorplan_CropsToContentBox(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	detector := ai.StaticDetector{
+		ContentBox: []int{100, 250, 900, 750},
+		Rooms:      []ai.GeminiRoom{{Name: "Office 101", Type: "OFFICE", Rect: []int{200, 300, 600, 500}}},
+	}
+	h := NewFloorplanHandler(store.NewMemoryRepository(), map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/upload", "plan.png", 200, 100))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
 	}
 
-	if len(got.Rooms) != 1 {
-		t.Fatalf("expected 1 room, got %d", len(got.Rooms))
+	var resp struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+		Rooms  []struct {
+			Rect []int `json:"rect"`
+		} `json:"rooms"`
+		ContentBox struct {
+			OriginalWidth int   `json:"original_width"`
+			Bounds        []int `json:"bounds"`
+			Crop          []int `json:"crop"`
+		} `json:"content_box"`
 	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
 
-	if got.Rooms[0].Name != "Office 101" {
-		t.Fatalf("expected room name Office 101, got %q", got.Rooms[0].Name)
+	// The box spans x 50-150 and y 10-90 of the 200x100 image.
+	if resp.Width != 100 || resp.Height != 80 || len(resp.Rooms) != 1 {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+	if got := resp.Rooms[0].Rect; fmt.Sprint(got) != "[10 10 40 40]" {
+		t.Fatalf("room rect = %v, want [10 10 40 40]", got)
+	}
+	if resp.ContentBox.OriginalWidth != 200 || fmt.Sprint(resp.ContentBox.Bounds) != "[100 250 900 750]" || fmt.Sprint(resp.ContentBox.Crop) != "[50 10 100 80]" {
+		t.Fatalf("content_box = %+v", resp.ContentBox)
 	}
 }
 
-func TestParseGeminiResponse_ExtractFirstObject(t *testing.T) {
-	input := "Result:\n{\"rooms\":[]}\nThanks"
+func TestUploadFloorplan_ReturnsStructure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	got, err := parseGeminiResponse(input)
-	if err != nil {
-		t.Fatalf("parseGeminiResponse() error = %v", err)
+	repo := store.NewMemoryRepository()
+	detector := ai.StaticDetector{
+		Rooms: []ai.GeminiRoom{
+			{Name: "Office 101", Type: "OFFICE", Rect: []int{0, 0, 1000, 500}},
+			{Name: "Office 102", Type: "OFFICE", Rect: []int{0, 500, 1000, 1000}},
+		},
+		Walls: []ai.GeminiWall{{Line: []int{0, 500, 1000, 500}, Thickness: 20}},
+		Doors: []ai.GeminiOpening{{Line: []int{400, 500, 600, 500}}},
+	}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/upload", "plan.png", 200, 100))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		ID    string `json:"id"`
+		Walls []struct {
+			Start     [2]int `json:"start"`
+			End       [2]int `json:"end"`
+			Thickness int    `json:"thickness"`
+		} `json:"walls"`
+		Doors []struct {
+			Position [2]int   `json:"position"`
+			Width    int      `json:"width"`
+			RoomIDs  []string `json:"room_ids"`
+		} `json:"doors"`
+		Windows []struct{} `json:"windows"`
 	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
 
-	if len(got.Rooms) != 0 {
-		t.Fatalf("expected empty rooms, got %d", len(got.Rooms))
+	// 20 of 200 pixels across a vertical wall.
+	if len(resp.Walls) != 1 || resp.Walls[0].Start != [2]int{100, 0} || resp.Walls[0].End != [2]int{100, 100} || resp.Walls[0].Thickness != 4 {
+		t.Fatalf("walls = %+v", resp.Walls)
+	}
+	if len(resp.Doors) != 1 || resp.Doors[0].Position != [2]int{100, 50} || resp.Doors[0].Width != 20 || len(resp.Doors[0].RoomIDs) != 2 {
+		t.Fatalf("doors = %+v", resp.Doors)
+	}
+	if resp.Windows != nil {
+		t.Fatalf("expected no windows key, got %s", w.Body.String())
+	}
+
+	stored, _ := repo.Get(context.Background(), resp.ID)
+	if len(stored.Walls) != 1 || len(stored.Doors) != 1 || len(stored.Doors[0].RoomIDs) != 2 {
+		t.Fatalf("stored structure = %+v %+v", stored.Walls, stored.Doors)
 	}
 }
 
-func TestParseGeminiResponse_TruncatedObjectRecovered(t *testing.T) {
-	input := "{\"rooms\":[{\"name\":\"Office 7\",\"type\":\"OFFICE\",\"rect\":[10,20,50,70]}]"
+func TestUploadFloorplan_StableIDsAcrossUploads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	got, err := parseGeminiResponse(input)
-	if err != nil {
-		t.Fatalf("parseGeminiResponse() error = %v", err)
+	repo := store.NewMemoryRepository()
+	detector := ai.StaticDetector{
+		Rooms: []ai.GeminiRoom{{Name: "Office 101", Type: "OFFICE", Rect: []int{0, 0, 1000, 500}}},
+		Doors: []ai.GeminiOpening{{Line: []int{400, 500, 600, 500}}},
 	}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
 
-	if len(got.Rooms) != 1 {
-		t.Fatalf("expected 1 room, got %d", len(got.Rooms))
+	upload := func(w, h int) (fp models.Floorplan) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, newUploadRequest(t, "/upload", "plan.png", w, h))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+		}
+		json.Unmarshal(rec.Body.Bytes(), &fp)
+		return fp
 	}
 
-	if got.Rooms[0].Name != "Office 7" {
-		t.Fatalf("expected room name Office 7, got %q", got.Rooms[0].Name)
+	// Re-uploading the same drawing keeps room and door IDs, so sensor
+	// mappings and history carry over to the new floorplan.
+	first, again, other := upload(200, 100), upload(200, 100), upload(300, 100)
+	if first.ID == again.ID {
+		t.Fatal("re-upload reused the floorplan ID")
+	}
+	if first.Rooms[0].ID != again.Rooms[0].ID || first.Doors[0].ID != again.Doors[0].ID {
+		t.Errorf("IDs changed on re-upload: %s, %s then %s, %s", first.Rooms[0].ID, first.Doors[0].ID, again.Rooms[0].ID, again.Doors[0].ID)
+	}
+	if first.Rooms[0].ID == other.Rooms[0].ID {
+		t.Errorf("a different image got the same room ID %s", other.Rooms[0].ID)
 	}
 }
 
-func TestParseGeminiResponse_TruncatedMidString(t *testing.T) {
-	// Second room is truncated mid-name; only first room should be recovered.
-	input := `{"rooms":[{"name":"Office 1","type":"OFFICE","rect":[10,20,50,70]},{"name":"Off`
+func TestUploadFloorplan_DetectedScale(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	got, err := parseGeminiResponse(input)
-	if err != nil {
-		t.Fatalf("parseGeminiResponse() error = %v", err)
+	repo := store.NewMemoryRepository()
+	detector := ai.StaticDetector{
+		Rooms: []ai.GeminiRoom{{Name: "Office 101", Type: "OFFICE", Rect: []int{0, 0, 1000, 500}}},
+		// A scale bar across the whole 200 pixel width, labeled 20 m.
+		Scale: &ai.GeminiScale{Line: []int{950, 0, 950, 1000}, Meters: 20},
+	}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/upload", "plan.png", 200, 100))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		ID             string        `json:"id"`
+		PixelsPerMeter float64       `json:"pixels_per_meter"`
+		Rooms          []models.Room `json:"rooms"`
 	}
-	if len(got.Rooms) < 1 {
-		t.Fatalf("expected at least 1 room, got %d", len(got.Rooms))
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.PixelsPerMeter != 10 {
+		t.Fatalf("pixels_per_meter = %v, want 10", resp.PixelsPerMeter)
 	}
-	if got.Rooms[0].Name != "Office 1" {
-		t.Fatalf("expected room name Office 1, got %q", got.Rooms[0].Name)
+	if len(resp.Rooms) != 1 || resp.Rooms[0].WidthM != 10 || resp.Rooms[0].HeightM != 10 || resp.Rooms[0].AreaM2 != 100 {
+		t.Fatalf("rooms = %+v, want 10 x 10 m", resp.Rooms)
+	}
+
+	stored, _ := repo.Get(context.Background(), resp.ID)
+	if stored.PixelsPerMeter != 10 || stored.Rooms[0].AreaM2 != 0 {
+		t.Fatalf("stored floorplan = %+v", stored)
 	}
 }
 
-func TestParseGeminiResponse_TruncatedMidRect(t *testing.T) {
-	// Second room's rect is incomplete; only first room should survive.
-	input := `{"rooms":[{"name":"Office 1","type":"OFFICE","rect":[10,20,50,70]},{"name":"Office 2","type":"MEETING","rect":[80,`
+func TestUploadFloorplan_FindsContentBoxFromEdges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
 
-	got, err := parseGeminiResponse(input)
-	if err != nil {
-		t.Fatalf("parseGeminiResponse() error = %v", err)
+	// A wall outline in the middle of an otherwise blank sheet.
+	img := image.NewGray(image.Rect(0, 0, 400, 300))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for x := 150; x < 250; x++ {
+		for _, y := range []int{100, 101, 198, 199} {
+			img.Pix[img.PixOffset(x, y)] = 0
+		}
+	}
+	for y := 100; y < 200; y++ {
+		for _, x := range []int{150, 151, 248, 249} {
+			img.Pix[img.PixOffset(x, y)] = 0
+		}
+	}
+	var data bytes.Buffer
+	png.Encode(&data, img)
+
+	h := NewFloorplanHandler(store.NewMemoryRepository(), map[string]ai.RoomDetector{"static": ai.StaticDetector{}}, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newFileUploadRequest("/upload", "plan.png", data.Bytes()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Width      int `json:"width"`
+		Height     int `json:"height"`
+		ContentBox struct {
+			Crop []int `json:"crop"`
+		} `json:"content_box"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+
+	crop := resp.ContentBox.Crop
+	if resp.Width >= 400 || resp.Height >= 300 || len(crop) != 4 {
+		t.Fatalf("expected a crop around the outline, got %s", w.Body.String())
+	}
+	if crop[0] > 150 || crop[1] > 100 || crop[0]+crop[2] < 250 || crop[1]+crop[3] < 200 {
+		t.Fatalf("crop %v does not cover the outline", crop)
+	}
+}
+
+func TestUploadFloorplan_SelectsDetector(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	detectors := map[string]ai.RoomDetector{
+		"static":             ai.StaticDetector{},
+		ai.DetectorClassical: ai.NewClassicalDetector(ai.DefaultClassicalDetectorOptions()),
+	}
+	h := NewFloorplanHandler(store.NewMemoryRepository(), detectors, "static", pdfraster.DefaultOptions(), ai.DefaultEdgeDetectionOptions())
+
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/upload?detector=classical", "plan.png", 50, 50))
+	if w.Code != http.StatusOK {
+		t.Fatalf("classical detector status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newUploadRequest(t, "/upload?detector=missing", "plan.png", 50, 50))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown detector status = %d, want 400", w.Code)
 	}
-	if len(got.Rooms) < 1 {
-		t.Fatalf("expected at least 1 room, got %d", len(got.Rooms))
+}
+
+// blankPDF builds a PDF with the given number of empty 200x100pt pages.
+func blankPDF(pages int) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for i := 0; i < pages; i++ {
+		kids += fmt.Sprintf("%d 0 R ", 3+i)
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
 	}
-	if got.Rooms[0].Name != "Office 1" {
-		t.Fatalf("expected room name Office 1, got %q", got.Rooms[0].Name)
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
 	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
 }
 
-func TestParseGeminiResponse_Invalid(t *testing.T) {
-	input := "no json here"
+func TestUploadFloorplan_PDFCreatesFloorplanPerPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	detector := ai.StaticDetector{Rooms: []ai.GeminiRoom{{Name: "Office 101", Type: "OFFICE", Rect: []int{0, 0, 500, 500}}}}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", pdfraster.Options{DPI: 144, MaxPages: 2}, ai.DefaultEdgeDetectionOptions())
+
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newFileUploadRequest("/upload", "plan.pdf", blankPDF(2)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Floorplans []struct {
+			ID     string `json:"id"`
+			Page   int    `json:"page"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+			Rooms  []struct {
+				FloorplanID string `json:"floorplan_id"`
+			} `json:"rooms"`
+		} `json:"floorplans"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &resp)
 
-	_, err := parseGeminiResponse(input)
-	if err == nil {
-		t.Fatalf("expected error for invalid input")
+	if len(resp.Floorplans) != 2 {
+		t.Fatalf("got %d floorplans, want one per page: %s", len(resp.Floorplans), w.Body.String())
+	}
+	for i, fp := range resp.Floorplans {
+		if fp.Page != i+1 || fp.Width != 400 || fp.Height != 200 {
+			t.Errorf("floorplan %d = page %d, %dx%d; want page %d, 400x200 at 144 DPI", i, fp.Page, fp.Width, fp.Height, i+1)
+		}
+		if len(fp.Rooms) != 1 || fp.Rooms[0].FloorplanID != fp.ID {
+			t.Errorf("floorplan %d rooms = %+v", i, fp.Rooms)
+		}
+		stored, err := repo.Get(context.Background(), fp.ID)
+		if err != nil || stored.Filename != "plan.pdf" || stored.Page != i+1 {
+			t.Errorf("stored floorplan %d = %+v, %v", i, stored, err)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newFileUploadRequest("/upload", "big.pdf", blankPDF(3)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("PDF over max_pages status = %d, want 400", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, newFileUploadRequest("/upload", "fake.pdf", []byte("not a pdf")))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("non-PDF content status = %d, want 400", w.Code)
+	}
+}
+
+func TestUploadFloorplan_PDFPageTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	detector := ai.StaticDetector{}
+	// blankPDF pages are 400x200 at 144 DPI, one pixel over the cap.
+	opts := pdfraster.Options{DPI: 144, MaxPages: 2, MaxPagePixels: 400*200 - 1}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"static": detector}, "static", opts, ai.DefaultEdgeDetectionOptions())
+
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newFileUploadRequest("/upload", "huge.pdf", blankPDF(1)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "too large") {
+		t.Errorf("status = %d, body = %s; want 400 for a page over the pixel cap", w.Code, w.Body.String())
+	}
+	if _, total, _ := repo.ListPage(context.Background(), store.ListOptions{}); total != 0 {
+		t.Errorf("stored %d floorplans, want none", total)
+	}
+}
+
+// failingDetector detects with StaticDetector until it has been called
+// failAfter times, then fails.
+type failingDetector struct {
+	calls     int
+	failAfter int
+}
+
+func (d *failingDetector) DetectRooms(ctx context.Context, data []byte, mimeType string) (*ai.Detection, error) {
+	d.calls++
+	if d.calls > d.failAfter {
+		return nil, fmt.Errorf("detector unavailable")
+	}
+	return ai.StaticDetector{}.DetectRooms(ctx, data, mimeType)
+}
+
+func TestUploadFloorplan_PDFFailureSavesNoPages(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	repo := store.NewMemoryRepository()
+	detector := &failingDetector{failAfter: 1}
+	h := NewFloorplanHandler(repo, map[string]ai.RoomDetector{"failing": detector}, "failing", pdfraster.Options{DPI: 72, MaxPages: 3}, ai.DefaultEdgeDetectionOptions())
+
+	r := gin.New()
+	r.POST("/upload", h.UploadFloorplan)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, newFileUploadRequest("/upload", "plan.pdf", blankPDF(3)))
+	if w.Code == http.StatusOK {
+		t.Fatalf("status = %d, want an error when page 2 fails", w.Code)
+	}
+	if detector.calls != 2 {
+		t.Errorf("detector called %d times, want analysis to stop at the failing page", detector.calls)
+	}
+	if _, total, _ := repo.ListPage(context.Background(), store.ListOptions{}); total != 0 {
+		t.Errorf("stored %d floorplans, want none after a failed page", total)
 	}
 }
@@ -0,0 +1,247 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/store"
+)
+
+// State is the lifecycle state of a job.
+type State string
+
+const (
+	StateQueued    State = "queued"
+	StateRunning   State = "running"
+	StateSucceeded State = "succeeded"
+	StateFailed    State = "failed"
+)
+
+var (
+	// ErrNotFound is returned when a job ID is unknown (or has been evicted).
+	ErrNotFound = errors.New("job not found")
+	// ErrQueueFull is returned when no more jobs can be accepted right now.
+	ErrQueueFull = errors.New("job queue is full")
+	// ErrStopped is returned when submitting to a manager that has been stopped.
+	ErrStopped = errors.New("job manager is stopped")
+)
+
+// Job is a snapshot of an asynchronous task.
+type Job struct {
+	ID        string      `json:"id"`
+	State     State       `json:"state"`
+	Stage     string      `json:"stage,omitempty"` // Free-form progress label while running
+	Error     string      `json:"error,omitempty"`
+	Result    interface{} `json:"result,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Done reports whether the job has finished, successfully or not.
+func (j Job) Done() bool {
+	return j.State == StateSucceeded || j.State == StateFailed
+}
+
+// Task is the work performed by a job. It calls progress to report named
+// stages and returns the value exposed as the job's result.
+type Task func(ctx context.Context, progress func(stage string)) (interface{}, error)
+
+// Manager runs submitted tasks on a fixed pool of workers and keeps the most
+// recent jobs in memory so their state can be polled.
+type Manager struct {
+	cfg      config.JobsConfig
+	onUpdate func(Job)
+
+	queue  chan queuedJob
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mutex   sync.RWMutex
+	jobs    map[string]*Job
+	stopped bool
+}
+
+type queuedJob struct {
+	id   string
+	task Task
+}
+
+// NewManager creates a manager; onUpdate (optional) is called with a snapshot
+// every time a job changes state or stage; it may run with the manager locked,
+// so it must not call back into the manager. Call Start to launch the workers.
+func NewManager(cfg config.JobsConfig, onUpdate func(Job)) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		cfg:      cfg,
+		onUpdate: onUpdate,
+		queue:    make(chan queuedJob, cfg.QueueSize),
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     make(map[string]*Job),
+	}
+}
+
+// Start launches the worker pool.
+func (m *Manager) Start() {
+	for i := 0; i < m.cfg.Workers; i++ {
+		m.wg.Add(1)
+		go m.worker()
+	}
+}
+
+// Stop cancels running tasks and waits for the workers to exit.
+// Jobs still in the queue are marked failed.
+func (m *Manager) Stop() {
+	m.mutex.Lock()
+	if m.stopped {
+		m.mutex.Unlock()
+		return
+	}
+	m.stopped = true
+	close(m.queue)
+	m.mutex.Unlock()
+
+	m.cancel()
+	m.wg.Wait()
+}
+
+// Submit enqueues a task and returns the queued job. The queued update is
+// published only once the job is accepted, and under the lock a worker needs
+// to update the job, so it always precedes the job's other updates.
+func (m *Manager) Submit(task Task) (Job, error) {
+	now := time.Now().UTC()
+	job := Job{ID: store.NewID(), State: StateQueued, CreatedAt: now, UpdatedAt: now}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopped {
+		return Job{}, ErrStopped
+	}
+	select {
+	case m.queue <- queuedJob{id: job.ID, task: task}:
+	default:
+		return Job{}, ErrQueueFull
+	}
+
+	// The worker waits for the lock to update the job, so it is stored first.
+	m.jobs[job.ID] = &job
+	m.evictLocked()
+	m.notify(job)
+	return job, nil
+}
+
+// Get returns a snapshot of the job with the given ID.
+func (m *Manager) Get(id string) (Job, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return Job{}, ErrNotFound
+	}
+	return *job, nil
+}
+
+func (m *Manager) worker() {
+	defer m.wg.Done()
+	for q := range m.queue {
+		m.run(q)
+	}
+}
+
+func (m *Manager) run(q queuedJob) {
+	if m.ctx.Err() != nil {
+		m.update(q.id, func(j *Job) {
+			j.State = StateFailed
+			j.Error = ErrStopped.Error()
+		})
+		return
+	}
+
+	m.update(q.id, func(j *Job) { j.State = StateRunning })
+
+	ctx, cancel := context.WithTimeout(m.ctx, m.cfg.Timeout)
+	defer cancel()
+
+	result, err := runTask(ctx, q.task, func(stage string) {
+		m.update(q.id, func(j *Job) { j.Stage = stage })
+	})
+
+	m.update(q.id, func(j *Job) {
+		j.Stage = ""
+		if err != nil {
+			j.State = StateFailed
+			j.Error = err.Error()
+			return
+		}
+		j.State = StateSucceeded
+		j.Result = result
+	})
+}
+
+// runTask calls task, turning a panic into an error: workers run outside any
+// request, so nothing else would stop a panicking task from taking the whole
+// server down.
+func runTask(ctx context.Context, task Task, progress func(stage string)) (result interface{}, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("job task panicked: %v\n%s", p, debug.Stack())
+			result, err = nil, fmt.Errorf("internal error: %v", p)
+		}
+	}()
+	return task(ctx, progress)
+}
+
+// update applies fn to the stored job and publishes the new snapshot.
+func (m *Manager) update(id string, fn func(*Job)) {
+	m.mutex.Lock()
+	job, ok := m.jobs[id]
+	if !ok {
+		m.mutex.Unlock()
+		return
+	}
+	fn(job)
+	job.UpdatedAt = time.Now().UTC()
+	snapshot := *job
+	m.mutex.Unlock()
+
+	m.notify(snapshot)
+}
+
+func (m *Manager) notify(job Job) {
+	if m.onUpdate != nil {
+		m.onUpdate(job)
+	}
+}
+
+// evictLocked drops the oldest finished jobs beyond the retention limit.
+func (m *Manager) evictLocked() {
+	if len(m.jobs) <= m.cfg.Retain {
+		return
+	}
+
+	var finished []*Job
+	for _, j := range m.jobs {
+		if j.Done() {
+			finished = append(finished, j)
+		}
+	}
+	sort.Slice(finished, func(a, b int) bool {
+		return finished[a].UpdatedAt.Before(finished[b].UpdatedAt)
+	})
+
+	for _, j := range finished {
+		if len(m.jobs) <= m.cfg.Retain {
+			break
+		}
+		delete(m.jobs, j.ID)
+	}
+}
@@ -0,0 +1,163 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/config"
+)
+
+func testConfig() config.JobsConfig {
+	return config.JobsConfig{Workers: 1, QueueSize: 1, Retain: 10, Timeout: time.Second}
+}
+
+// waitDone polls until the job finishes or the test times out.
+func waitDone(t *testing.T, m *Manager, id string) Job {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatalf("Get() error = %v", err)
+		}
+		if job.Done() {
+			return job
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return Job{}
+}
+
+func TestManager_RunsTaskAndReportsProgress(t *testing.T) {
+	var mu sync.Mutex
+	var updates []Job
+	m := NewManager(testConfig(), func(j Job) {
+		mu.Lock()
+		updates = append(updates, j)
+		mu.Unlock()
+	})
+	m.Start()
+	defer m.Stop()
+
+	job, err := m.Submit(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		progress("detecting")
+		return "done", nil
+	})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if job.State != StateQueued || job.ID == "" {
+		t.Fatalf("Submit() = %+v, want queued job with ID", job)
+	}
+
+	final := waitDone(t, m, job.ID)
+	if final.State != StateSucceeded || final.Result != "done" {
+		t.Fatalf("final job = %+v", final)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(updates) == 0 || updates[0].State != StateQueued {
+		t.Fatalf("first update = %+v, want queued", updates)
+	}
+	var sawRunning, sawStage bool
+	for _, u := range updates {
+		sawRunning = sawRunning || u.State == StateRunning
+		sawStage = sawStage || u.Stage == "detecting"
+	}
+	if !sawRunning || !sawStage {
+		t.Fatalf("updates missing running state or stage: %+v", updates)
+	}
+}
+
+func TestManager_FailedTask(t *testing.T) {
+	m := NewManager(testConfig(), nil)
+	m.Start()
+	defer m.Stop()
+
+	job, _ := m.Submit(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+
+	final := waitDone(t, m, job.ID)
+	if final.State != StateFailed || final.Error != "boom" || final.Result != nil {
+		t.Fatalf("final job = %+v, want failed with error boom", final)
+	}
+}
+
+func TestManager_QueueFull(t *testing.T) {
+	var updates []Job
+	m := NewManager(testConfig(), func(j Job) { updates = append(updates, j) })
+	// Workers are not started, so the single queue slot stays occupied.
+	noop := func(ctx context.Context, progress func(string)) (interface{}, error) { return nil, nil }
+
+	if _, err := m.Submit(noop); err != nil {
+		t.Fatalf("first Submit() error = %v", err)
+	}
+	if _, err := m.Submit(noop); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("second Submit() error = %v, want ErrQueueFull", err)
+	}
+
+	m.Stop()
+	if _, err := m.Submit(noop); !errors.Is(err, ErrStopped) {
+		t.Fatalf("Submit() after Stop error = %v, want ErrStopped", err)
+	}
+	// Only the accepted job is announced; rejected ones were never queued.
+	if len(updates) != 1 || updates[0].State != StateQueued {
+		t.Fatalf("updates = %+v, want only the first job queued", updates)
+	}
+}
+
+func TestManager_EvictsOldestFinishedJobs(t *testing.T) {
+	cfg := testConfig()
+	cfg.QueueSize = 10
+	cfg.Retain = 2
+	m := NewManager(cfg, nil)
+	m.Start()
+	defer m.Stop()
+
+	noop := func(ctx context.Context, progress func(string)) (interface{}, error) { return nil, nil }
+	var ids []string
+	for i := 0; i < 3; i++ {
+		job, err := m.Submit(noop)
+		if err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+		waitDone(t, m, job.ID)
+		ids = append(ids, job.ID)
+	}
+
+	if _, err := m.Get(ids[0]); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("oldest job should be evicted, Get() error = %v", err)
+	}
+	if _, err := m.Get(ids[2]); err != nil {
+		t.Fatalf("newest job should be retained, Get() error = %v", err)
+	}
+}
+
+func TestManager_RecoversFromPanickingTask(t *testing.T) {
+	m := NewManager(testConfig(), nil)
+	m.Start()
+	defer m.Stop()
+
+	job, err := m.Submit(func(ctx context.Context, progress func(string)) (interface{}, error) {
+		var pages []int
+		return pages[1], nil
+	})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	if final := waitDone(t, m, job.ID); final.State != StateFailed || final.Error == "" {
+		t.Fatalf("final job = %+v, want failed with an error", final)
+	}
+
+	// The worker survives and runs the next job.
+	job, _ = m.Submit(func(ctx context.Context, progress func(string)) (interface{}, error) { return "done", nil })
+	if final := waitDone(t, m, job.ID); final.State != StateSucceeded {
+		t.Fatalf("next job = %+v, want succeeded", final)
+	}
+}
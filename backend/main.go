@@ -17,14 +17,28 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
 	"io/fs"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"floorplan-whiteboard/ai"
+	"floorplan-whiteboard/config"
 	_ "floorplan-whiteboard/docs" // Load generated swagger docs
 	"floorplan-whiteboard/handler"
+	"floorplan-whiteboard/jobs"
+	"floorplan-whiteboard/mqttbridge"
+	"floorplan-whiteboard/occupancy"
+	"floorplan-whiteboard/pdfraster"
 	"floorplan-whiteboard/realtime"
+	"floorplan-whiteboard/store"
 
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
@@ -34,7 +48,26 @@ import (
 //go:embed docs/swagger.json docs/swagger.yaml
 var swaggerFS embed.FS
 
+// shutdownTimeout bounds how long in-flight requests may take to finish on shutdown.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	// Registered first so it runs last, once the other deferred cleanup is done.
+	failed := false
+	defer func() {
+		if failed {
+			os.Exit(1)
+		}
+	}()
+
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to YAML config file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	r := gin.Default()
 
 	// Swagger documentation
@@ -50,23 +83,69 @@ func main() {
 		c.Data(http.StatusOK, "application/json", data)
 	})
 
-	// CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+	r.Use(handler.CORS(cfg.Server))
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	repo, err := store.OpenBolt(cfg.Store.Path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer repo.Close()
+
+	var broker realtime.Broker
+	if cfg.Realtime.Broker == config.BrokerRedis {
+		redisBroker, err := realtime.NewRedisBroker(cfg.Realtime.Redis)
+		if err != nil {
+			log.Fatal(err)
 		}
+		defer redisBroker.Close()
+		broker = redisBroker
+		log.Printf("Realtime hub sharing updates over redis %s", cfg.Realtime.Redis.Addr)
+	}
+	hub, err := realtime.NewHub(cfg.Realtime, repo, broker)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hub.AllowOrigin = cfg.Server.AllowsOrigin
+	go hub.Run()
 
-		c.Next()
-	})
+	edgeOpts := ai.EdgeDetectionOptions(cfg.Detection.Edge)
+	classicalOpts := ai.DefaultClassicalDetectorOptions()
+	classicalOpts.Edge = edgeOpts
 
-	hub := realtime.NewHub()
-	go hub.Run()
+	detectors := map[string]ai.RoomDetector{
+		ai.DetectorGemini:    ai.NewGeminiDetector(ai.GeminiOptions(cfg.Gemini)),
+		ai.DetectorClassical: ai.NewClassicalDetector(classicalOpts),
+	}
+	if _, ok := detectors[cfg.Detection.DefaultDetector]; !ok {
+		log.Fatalf("invalid configuration: unknown detection.default_detector %q", cfg.Detection.DefaultDetector)
+	}
+	floorplans := handler.NewFloorplanHandler(repo, detectors, cfg.Detection.DefaultDetector, pdfraster.OptionsFromConfig(cfg.PDF), edgeOpts)
+	edges := handler.NewEdgeDetectionHandler(edgeOpts)
+
+	jobManager := jobs.NewManager(cfg.Jobs, func(job jobs.Job) {
+		hub.PublishJobProgress(job.ID, job)
+	})
+	jobManager.Start()
+	defer jobManager.Stop()
+	jobHandler := handler.NewJobHandler(floorplans, jobManager)
+	statusService := occupancy.NewService(repo, repo, hub)
+	statuses := handler.NewStatusHandler(statusService)
+	events := handler.NewEventsHandler(hub, cfg.Realtime)
+	heatmaps := handler.NewHeatmapHandler(floorplans, statusService)
+	exports := handler.NewExportHandler(floorplans, cfg.Export)
+	navigation := handler.NewNavigationHandler(floorplans)
+
+	if cfg.MQTT.Enabled {
+		bridge, err := mqttbridge.New(cfg.MQTT, statusService)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := bridge.Start(); err != nil {
+			log.Fatal(err)
+		}
+		defer bridge.Stop()
+		log.Printf("MQTT bridge subscribed to %s", cfg.MQTT.Broker)
+	}
 
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "SmartFloor Backend Running"})
@@ -74,18 +153,75 @@ func main() {
 
 	api := r.Group("/api/v1")
 	{
-		api.POST("/upload", handler.UploadFloorplan)
-		api.POST("/process/edges", handler.ProcessFloorplanEdges)
-		api.POST("/process/edges-json", handler.ProcessFloorplanWithJSON)
-		api.POST("/process/crop", handler.CropFloorplanHandler)
+		api.POST("/upload", floorplans.UploadFloorplan)
+		api.POST("/jobs", jobHandler.CreateJob)
+		api.GET("/jobs/:id", jobHandler.GetJob)
+
+		api.GET("/floorplans", floorplans.ListFloorplans)
+		api.GET("/floorplans/:id", floorplans.GetFloorplan)
+		api.GET("/floorplans/:id/overlay", floorplans.GetFloorplanOverlay)
+		api.GET("/floorplans/:id/export", exports.ExportFloorplan)
+		api.GET("/floorplans/:id/graph", navigation.GetGraph)
+		api.GET("/floorplans/:id/route", navigation.GetRoute)
+		api.PUT("/floorplans/:id", floorplans.UpdateFloorplan)
+		api.POST("/floorplans/:id/calibration", floorplans.CalibrateFloorplan)
+		api.DELETE("/floorplans/:id", floorplans.DeleteFloorplan)
+		api.POST("/floorplans/:id/rooms", floorplans.CreateRoom)
+		api.PATCH("/floorplans/:id/rooms/:roomId", floorplans.PatchRoom)
+		api.DELETE("/floorplans/:id/rooms/:roomId", floorplans.DeleteRoom)
+		api.POST("/floorplans/:id/rooms/:roomId/status", statuses.SetRoomStatus)
+		api.POST("/floorplans/:id/status", statuses.SetRoomStatuses)
+		api.GET("/floorplans/:id/rooms/:roomId/history", statuses.GetRoomHistory)
+		api.GET("/floorplans/:id/rooms/:roomId/utilization", statuses.GetRoomUtilization)
+		api.GET("/floorplans/:id/events", events.StreamEvents)
+		api.GET("/floorplans/:id/heatmap.png", heatmaps.GetHeatmap)
+
+		api.POST("/process/edges", edges.ProcessFloorplanEdges)
+		api.POST("/process/edges-json", edges.ProcessFloorplanWithJSON)
+		api.POST("/process/crop", edges.CropFloorplanHandler)
 	}
 
 	r.GET("/ws", func(c *gin.Context) {
 		hub.HandleWebSocket(c)
 	})
 
-	log.Println("Server starting on :8080")
-	if err := r.Run(":8080"); err != nil {
-		log.Fatal(err)
+	// Serve until interrupted, then drain in-flight requests so the deferred
+	// shutdown of the MQTT bridge, job manager and store runs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Requests inherit baseCtx, which shutdown cancels, so long-lived
+	// handlers such as event streams end instead of holding Shutdown up.
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+	srv := &http.Server{
+		Addr:        cfg.Server.Addr,
+		Handler:     r,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	srv.RegisterOnShutdown(cancelRequests)
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Server starting on %s", cfg.Server.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		// Returning, rather than log.Fatal, still runs the deferred cleanup.
+		log.Printf("Server failed: %v", err)
+		failed = true
+		return
+	case <-ctx.Done():
+	}
+
+	log.Println("Shutting down")
+	// Websockets are hijacked, so Shutdown does not see them; close them
+	// through the hub first.
+	hub.Close()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
 	}
 }
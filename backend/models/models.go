@@ -1,6 +1,7 @@
 package models
 
 import (
+	"math"
 	"time"
 )
 
@@ -27,14 +28,155 @@ const (
 // Used for pixel coordinates relative to the cropped image.
 type Rect []int
 
+// Point is an [x, y] position in pixels relative to the cropped image.
+type Point [2]int
+
 // Room represents a detected functional space within a floorplan.
 type Room struct {
 	ID          string     `json:"id"`
 	FloorplanID string     `json:"floorplan_id"`
 	Name        string     `json:"name"`
 	Type        RoomType   `json:"type"`
-	Rect        Rect       `json:"rect"` // [x, y, w, h]
+	Rect        Rect       `json:"rect"`              // [x, y, w, h]; the polygon's bounding box when it has one
+	Polygon     []Point    `json:"polygon,omitempty"` // Outline of non-rectangular rooms
+	Status      RoomStatus `json:"status"`
+	Occupancy   int        `json:"occupancy"` // People currently in the room, as last reported
+
+	// Size in meters, filled in by Measure for responses from calibrated floorplans.
+	WidthM  float64 `json:"width_m,omitempty"`
+	HeightM float64 `json:"height_m,omitempty"`
+	AreaM2  float64 `json:"area_m2,omitempty"`
+}
+
+// Label is the name shown for the room, falling back to its ID.
+func (r Room) Label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return r.ID
+}
+
+// Outline returns the room's polygon, or the corners of its rect when it has
+// none. It returns nil for rooms with neither.
+func (r Room) Outline() []Point {
+	if len(r.Polygon) >= 3 {
+		return r.Polygon
+	}
+	if len(r.Rect) != 4 {
+		return nil
+	}
+	x, y, w, h := r.Rect[0], r.Rect[1], r.Rect[2], r.Rect[3]
+	return []Point{{x, y}, {x + w, y}, {x + w, y + h}, {x, y + h}}
+}
+
+// Measure sets the room's width and height (of its rect) and floor area (of
+// its outline) in meters at pixelsPerMeter, rounded to centimeters and square
+// centimeters. A pixelsPerMeter of 0 clears them.
+func (r *Room) Measure(pixelsPerMeter float64) {
+	r.WidthM, r.HeightM, r.AreaM2 = 0, 0, 0
+	outline := r.Outline()
+	if pixelsPerMeter <= 0 || outline == nil {
+		return
+	}
+	box := BoundingRect(outline)
+	r.WidthM = roundTo(float64(box[2])/pixelsPerMeter, 100)
+	r.HeightM = roundTo(float64(box[3])/pixelsPerMeter, 100)
+	r.AreaM2 = roundTo(PolygonArea(outline)/(pixelsPerMeter*pixelsPerMeter), 10000)
+}
+
+// PolygonArea returns the area enclosed by points, in square pixels.
+func PolygonArea(points []Point) float64 {
+	var sum int
+	for i, p := range points {
+		q := points[(i+1)%len(points)]
+		sum += p[0]*q[1] - q[0]*p[1]
+	}
+	return math.Abs(float64(sum)) / 2
+}
+
+// roundTo rounds v to the nearest 1/scale.
+func roundTo(v, scale float64) float64 {
+	return math.Round(v*scale) / scale
+}
+
+// BoundingRect returns the [x, y, w, h] box around points.
+func BoundingRect(points []Point) Rect {
+	if len(points) == 0 {
+		return nil
+	}
+	minX, minY, maxX, maxY := points[0][0], points[0][1], points[0][0], points[0][1]
+	for _, p := range points[1:] {
+		minX, minY = min(minX, p[0]), min(minY, p[1])
+		maxX, maxY = max(maxX, p[0]), max(maxY, p[1])
+	}
+	return Rect{minX, minY, maxX - minX, maxY - minY}
+}
+
+// Distance returns how far (x, y) lies outside the room's outline, in
+// pixels: 0 inside or on it, +Inf for rooms without an outline.
+func (r Room) Distance(x, y float64) float64 {
+	outline := r.Outline()
+	if outline == nil {
+		return math.Inf(1)
+	}
+	inside := false
+	dist := math.Inf(1)
+	for i, a := range outline {
+		b := outline[(i+1)%len(outline)]
+		ax, ay, bx, by := float64(a[0]), float64(a[1]), float64(b[0]), float64(b[1])
+		if (ay > y) != (by > y) && x < ax+(y-ay)*(bx-ax)/(by-ay) {
+			inside = !inside
+		}
+		dist = math.Min(dist, segmentDistance(x, y, ax, ay, bx, by))
+	}
+	if inside {
+		return 0
+	}
+	return dist
+}
+
+// segmentDistance returns the distance from (x, y) to the segment a-b.
+func segmentDistance(x, y, ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	t := 0.0
+	if l := dx*dx + dy*dy; l > 0 {
+		t = math.Max(0, math.Min(1, ((x-ax)*dx+(y-ay)*dy)/l))
+	}
+	return math.Hypot(x-ax-t*dx, y-ay-t*dy)
+}
+
+// Wall is a straight wall segment along its center line.
+type Wall struct {
+	ID        string `json:"id"`
+	Start     Point  `json:"start"`
+	End       Point  `json:"end"`
+	Thickness int    `json:"thickness"` // Pixels across the wall
+}
+
+// Door is a doorway in a wall.
+type Door struct {
+	ID       string   `json:"id"`
+	Position Point    `json:"position"` // Center of the opening
+	Width    int      `json:"width"`    // Clear width in pixels
+	RoomIDs  []string `json:"room_ids"` // Rooms on either side, nearest first; one for exterior doors
+}
+
+// Window is a window in a wall, placed like a Door.
+type Window struct {
+	ID       string   `json:"id"`
+	Position Point    `json:"position"` // Center of the opening
+	Width    int      `json:"width"`    // Clear width in pixels
+	RoomIDs  []string `json:"room_ids"` // Rooms it opens onto, nearest first
+}
+
+// StatusChange records a room's status and occupancy from Timestamp until
+// its next change.
+type StatusChange struct {
+	FloorplanID string     `json:"floorplan_id"`
+	RoomID      string     `json:"room_id"`
 	Status      RoomStatus `json:"status"`
+	Occupancy   int        `json:"occupancy"`
+	Timestamp   time.Time  `json:"timestamp"`
 }
 
 // Floorplan represents the processed digital twin.
@@ -45,13 +187,46 @@ type Floorplan struct {
 	Width     int       `json:"width"`
 	Height    int       `json:"height"`
 	Rooms     []Room    `json:"rooms"`
+	Walls     []Wall    `json:"walls,omitempty"`
+	Doors     []Door    `json:"doors,omitempty"`
+	Windows   []Window  `json:"windows,omitempty"`
+	Page      int       `json:"page,omitempty"` // 1-based source page for floorplans rasterized from a PDF
 	CreatedAt time.Time `json:"created_at"`
+
+	ContentBox     *ContentBox   `json:"content_box,omitempty"`      // Where the cropped image lies on the uploaded sheet
+	GeoTransform   *GeoTransform `json:"geo_transform,omitempty"`    // Pixel to WGS 84 mapping, when georeferenced
+	PixelsPerMeter float64       `json:"pixels_per_meter,omitempty"` // Scale of the cropped image, once calibrated
+}
+
+// Measure fills in every room's size in meters at the floorplan's calibrated
+// scale, or clears them when it is not calibrated (see Room.Measure).
+func (fp *Floorplan) Measure() {
+	for i := range fp.Rooms {
+		fp.Rooms[i].Measure(fp.PixelsPerMeter)
+	}
+}
+
+// GeoTransform is an affine transform [a, b, c, d, e, f] from cropped image
+// pixels to WGS 84 coordinates: lon = a*x + b*y + c, lat = d*x + e*y + f.
+type GeoTransform [6]float64
+
+// Apply maps the pixel (x, y) to longitude and latitude.
+func (t GeoTransform) Apply(x, y float64) (lon, lat float64) {
+	return t[0]*x + t[1]*y + t[2], t[3]*x + t[4]*y + t[5]
+}
+
+// Invertible reports whether the transform maps distinct pixels to distinct points.
+func (t GeoTransform) Invertible() bool {
+	return t[0]*t[4]-t[1]*t[3] != 0
 }
 
 // ContentBox represents the detected content area in the original image.
 // Bounds are [ymin, xmin, ymax, xmax] in relative 0-1000 coordinates (Gemini format).
+// Crop is the same area as [x, y, w, h] in original image pixels; adding its
+// origin to cropped-image coordinates maps them back onto the original sheet.
 type ContentBox struct {
 	OriginalWidth  int   `json:"original_width"`
 	OriginalHeight int   `json:"original_height"`
 	Bounds         []int `json:"bounds"`
+	Crop           Rect  `json:"crop"`
 }
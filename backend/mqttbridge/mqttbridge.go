@@ -0,0 +1,206 @@
+// Package mqttbridge subscribes to occupancy sensor topics on an MQTT broker
+// and applies the readings as room status changes, which updates the stored
+// rooms and pushes status_update messages through the realtime hub.
+package mqttbridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/occupancy"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	floorplanPlaceholder = "{floorplan_id}"
+	roomPlaceholder      = "{room_id}"
+
+	// connectTimeout bounds Start's wait for the first connection and subscription.
+	connectTimeout = 10 * time.Second
+	// applyTimeout bounds storing one message's change.
+	applyTimeout = 10 * time.Second
+)
+
+// ErrNoRoute is returned by HandleMessage for topics no mapping matches.
+var ErrNoRoute = errors.New("no topic mapping matches")
+
+// route is a compiled topic mapping.
+type route struct {
+	levels      []string // Topic levels; placeholders are kept verbatim
+	filter      string   // Subscription filter with placeholders replaced by +
+	floorplanID string
+	roomID      string
+}
+
+// match resolves the floorplan and room ID for topic.
+func (r route) match(topic string) (floorplanID, roomID string, ok bool) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(r.levels) {
+		return "", "", false
+	}
+	floorplanID, roomID = r.floorplanID, r.roomID
+	for i, level := range r.levels {
+		switch level {
+		case floorplanPlaceholder:
+			floorplanID = levels[i]
+		case roomPlaceholder:
+			roomID = levels[i]
+		default:
+			if level != levels[i] {
+				return "", "", false
+			}
+		}
+	}
+	return floorplanID, roomID, floorplanID != "" && roomID != ""
+}
+
+// compileRoute validates a topic mapping.
+func compileRoute(cfg config.MQTTTopicConfig) (route, error) {
+	if cfg.Topic == "" {
+		return route{}, errors.New("topic must not be empty")
+	}
+	r := route{levels: strings.Split(cfg.Topic, "/"), floorplanID: cfg.FloorplanID, roomID: cfg.RoomID}
+
+	filter := make([]string, len(r.levels))
+	hasFloorplan, hasRoom := false, false
+	for i, level := range r.levels {
+		switch {
+		case level == floorplanPlaceholder:
+			hasFloorplan = true
+			filter[i] = "+"
+		case level == roomPlaceholder:
+			hasRoom = true
+			filter[i] = "+"
+		case strings.ContainsAny(level, "+#{}"):
+			return route{}, fmt.Errorf("topic %q: level %q may only be a literal, {floorplan_id} or {room_id}", cfg.Topic, level)
+		default:
+			filter[i] = level
+		}
+	}
+	if !hasFloorplan && cfg.FloorplanID == "" {
+		return route{}, fmt.Errorf("topic %q: needs a {floorplan_id} level or a floorplan_id", cfg.Topic)
+	}
+	if !hasRoom && cfg.RoomID == "" {
+		return route{}, fmt.Errorf("topic %q: needs a {room_id} level or a room_id", cfg.Topic)
+	}
+	r.filter = strings.Join(filter, "/")
+	return r, nil
+}
+
+// Bridge feeds MQTT sensor messages into an occupancy.Service.
+type Bridge struct {
+	cfg     config.MQTTConfig
+	service *occupancy.Service
+	routes  []route
+
+	client mqtt.Client
+	ready  chan struct{} // Closed after the first successful subscription
+	once   sync.Once
+}
+
+// New validates the topic mappings in cfg and creates a bridge that applies
+// changes through service. Call Start to connect.
+func New(cfg config.MQTTConfig, service *occupancy.Service) (*Bridge, error) {
+	b := &Bridge{cfg: cfg, service: service, ready: make(chan struct{})}
+	for _, topic := range cfg.Topics {
+		r, err := compileRoute(topic)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mqtt topic mapping: %w", err)
+		}
+		b.routes = append(b.routes, r)
+	}
+	if len(b.routes) == 0 {
+		return nil, errors.New("invalid mqtt configuration: no topics")
+	}
+	return b, nil
+}
+
+// Start connects to the broker and subscribes to every mapped topic, waiting
+// for the first subscription to complete. The client reconnects and
+// resubscribes automatically afterwards.
+func (b *Bridge) Start() error {
+	opts := mqtt.NewClientOptions().
+		AddBroker(b.cfg.Broker).
+		SetClientID(b.cfg.ClientID).
+		SetUsername(b.cfg.Username).
+		SetPassword(b.cfg.Password).
+		SetAutoReconnect(true).
+		SetConnectTimeout(connectTimeout).
+		SetOnConnectHandler(b.subscribe).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("MQTT connection lost: %v", err)
+		})
+
+	b.client = mqtt.NewClient(opts)
+	token := b.client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		b.client.Disconnect(0)
+		return fmt.Errorf("timed out connecting to MQTT broker %s", b.cfg.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker %s: %w", b.cfg.Broker, err)
+	}
+
+	select {
+	case <-b.ready:
+		return nil
+	case <-time.After(connectTimeout):
+		b.client.Disconnect(0)
+		return fmt.Errorf("timed out subscribing to MQTT topics on %s", b.cfg.Broker)
+	}
+}
+
+// Stop disconnects from the broker.
+func (b *Bridge) Stop() {
+	if b.client != nil {
+		b.client.Disconnect(250)
+	}
+}
+
+// subscribe (re)subscribes to every mapped topic; it runs on each connect.
+func (b *Bridge) subscribe(client mqtt.Client) {
+	filters := make(map[string]byte, len(b.routes))
+	for _, r := range b.routes {
+		filters[r.filter] = byte(b.cfg.QoS)
+	}
+
+	token := client.SubscribeMultiple(filters, func(_ mqtt.Client, msg mqtt.Message) {
+		if err := b.HandleMessage(msg.Topic(), msg.Payload()); err != nil {
+			log.Printf("MQTT message on %s dropped: %v", msg.Topic(), err)
+		}
+	})
+	go func() {
+		if token.Wait(); token.Error() != nil {
+			log.Printf("MQTT subscribe failed: %v", token.Error())
+			return
+		}
+		b.once.Do(func() { close(b.ready) })
+	}()
+}
+
+// HandleMessage maps topic to a room and applies the change described by payload.
+func (b *Bridge) HandleMessage(topic string, payload []byte) error {
+	for _, r := range b.routes {
+		floorplanID, roomID, ok := r.match(topic)
+		if !ok {
+			continue
+		}
+		change, err := ParsePayload(roomID, payload)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), applyTimeout)
+		defer cancel()
+		_, err = b.service.Apply(ctx, floorplanID, []occupancy.Change{change})
+		return err
+	}
+	return fmt.Errorf("%w: %s", ErrNoRoute, topic)
+}
@@ -0,0 +1,156 @@
+package mqttbridge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/occupancy"
+	"floorplan-whiteboard/realtime"
+	"floorplan-whiteboard/store"
+
+	mqttserver "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+)
+
+func TestParsePayload(t *testing.T) {
+	cases := []struct {
+		payload   string
+		status    models.RoomStatus
+		occupancy int // -1 = not set
+	}{
+		{`{"status":"busy","occupancy":3}`, models.RoomStatusBusy, 3},
+		{`{"status":"OFFLINE"}`, models.RoomStatusOffline, -1},
+		{`{"occupancy":0}`, models.RoomStatusAvailable, 0},
+		{`{"occupied":true}`, models.RoomStatusBusy, -1},
+		{`AVAILABLE`, models.RoomStatusAvailable, -1},
+		{`"busy"`, models.RoomStatusBusy, -1},
+		{`2`, models.RoomStatusBusy, 2},
+		{`false`, models.RoomStatusAvailable, -1},
+	}
+	for _, tc := range cases {
+		change, err := ParsePayload("room-a", []byte(tc.payload))
+		if err != nil {
+			t.Errorf("ParsePayload(%s) error = %v", tc.payload, err)
+			continue
+		}
+		if change.RoomID != "room-a" || change.Status != tc.status {
+			t.Errorf("ParsePayload(%s) = %+v, want status %s", tc.payload, change, tc.status)
+		}
+		if got := change.Occupancy; (tc.occupancy < 0) != (got == nil) || (got != nil && *got != tc.occupancy) {
+			t.Errorf("ParsePayload(%s) occupancy = %v, want %d", tc.payload, got, tc.occupancy)
+		}
+	}
+
+	for _, bad := range []string{``, `{"status":"ON_FIRE"}`, `{}`, `{"occupancy":-1}`, `{broken`,
+		`{"occupancy":2.7}`, `{"occupancy":1e3}`, `2.7`, `NaN`, `+Inf`, `-Inf`, `99999999999999999999`} {
+		if _, err := ParsePayload("room-a", []byte(bad)); err == nil {
+			t.Errorf("ParsePayload(%q) expected error", bad)
+		}
+	}
+}
+
+func TestCompileRoute(t *testing.T) {
+	r, err := compileRoute(config.MQTTTopicConfig{Topic: "site/{floorplan_id}/rooms/{room_id}"})
+	if err != nil {
+		t.Fatalf("compileRoute() error = %v", err)
+	}
+	if r.filter != "site/+/rooms/+" {
+		t.Errorf("filter = %q", r.filter)
+	}
+	if fp, room, ok := r.match("site/fp-1/rooms/room-a"); !ok || fp != "fp-1" || room != "room-a" {
+		t.Errorf("match = %q, %q, %v", fp, room, ok)
+	}
+	if _, _, ok := r.match("site/fp-1/desks/room-a"); ok {
+		t.Error("matched a topic with a different literal level")
+	}
+
+	fixed, err := compileRoute(config.MQTTTopicConfig{Topic: "hq/sensor-7", FloorplanID: "fp-1", RoomID: "room-a"})
+	if err != nil {
+		t.Fatalf("compileRoute(fixed) error = %v", err)
+	}
+	if fp, room, ok := fixed.match("hq/sensor-7"); !ok || fp != "fp-1" || room != "room-a" {
+		t.Errorf("fixed match = %q, %q, %v", fp, room, ok)
+	}
+
+	for _, bad := range []config.MQTTTopicConfig{
+		{Topic: ""},
+		{Topic: "site/{floorplan_id}"}, // No room
+		{Topic: "site/+/{room_id}", FloorplanID: "fp"}, // Wildcard
+		{Topic: "site/#", FloorplanID: "fp", RoomID: "r"},
+	} {
+		if _, err := compileRoute(bad); err == nil {
+			t.Errorf("compileRoute(%+v) expected error", bad)
+		}
+	}
+}
+
+// chanPublisher forwards published updates to a channel.
+type chanPublisher chan realtime.StatusUpdate
+
+func (p chanPublisher) PublishStatus(update realtime.StatusUpdate) { p <- update }
+
+// startBroker runs an embedded MQTT broker and returns it with its tcp:// URL.
+func startBroker(t *testing.T) (*mqttserver.Server, string) {
+	t.Helper()
+	server := mqttserver.New(&mqttserver.Options{InlineClient: true})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	t.Cleanup(func() { server.Close() })
+	return server, "tcp://" + tcp.Address()
+}
+
+func TestBridge_AppliesMessagesFromBroker(t *testing.T) {
+	broker, url := startBroker(t)
+
+	repo := store.NewMemoryRepository()
+	fp := &models.Floorplan{Rooms: []models.Room{{ID: "room-a", Name: "Office 101", Status: models.RoomStatusAvailable}}}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+	published := make(chanPublisher, 1)
+
+	cfg := config.Default().MQTT
+	cfg.Broker = url
+	cfg.QoS = 0
+	bridge, err := New(cfg, occupancy.NewService(repo, nil, published))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := bridge.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(bridge.Stop)
+
+	if err := broker.Publish("spacetwin/"+fp.ID+"/room-a/status", []byte(`{"status":"busy","occupancy":2}`), false, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case update := <-published:
+		if update.FloorplanID != fp.ID || update.RoomID != "room-a" || update.Status != models.RoomStatusBusy || update.Occupancy != 2 {
+			t.Errorf("published update = %+v", update)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status update published for MQTT message")
+	}
+
+	stored, _ := repo.Get(context.Background(), fp.ID)
+	if stored.Rooms[0].Status != models.RoomStatusBusy || stored.Rooms[0].Occupancy != 2 {
+		t.Errorf("stored room = %+v", stored.Rooms[0])
+	}
+
+	if err := bridge.HandleMessage("other/topic", []byte("BUSY")); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("HandleMessage(unmapped) error = %v, want ErrNoRoute", err)
+	}
+}
@@ -0,0 +1,94 @@
+package mqttbridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/occupancy"
+)
+
+// sensorPayload is the JSON object form of a sensor message.
+type sensorPayload struct {
+	Status    *string      `json:"status"`
+	Occupancy *json.Number `json:"occupancy"`
+	Occupied  *bool        `json:"occupied"`
+}
+
+// ParsePayload translates a sensor message into a change for roomID. Accepted
+// payloads are:
+//
+//   - a JSON object with any of "status" (AVAILABLE, BUSY or OFFLINE, any
+//     case), "occupancy" (people count) and "occupied" (boolean);
+//   - a bare status name, e.g. BUSY;
+//   - a bare people count, e.g. 3;
+//   - true/false for occupied/vacant.
+//
+// When a payload carries a count or occupied flag but no status, the status
+// is derived from it: BUSY when occupied, AVAILABLE otherwise.
+func ParsePayload(roomID string, payload []byte) (occupancy.Change, error) {
+	change := occupancy.Change{RoomID: roomID}
+	payload = bytes.TrimSpace(payload)
+	if len(payload) == 0 {
+		return change, errors.New("empty payload")
+	}
+
+	var p sensorPayload
+	if payload[0] == '{' {
+		if err := json.Unmarshal(payload, &p); err != nil {
+			return change, fmt.Errorf("invalid JSON payload: %w", err)
+		}
+	} else {
+		text := strings.Trim(string(payload), `"`)
+		if _, err := strconv.ParseFloat(text, 64); err == nil {
+			n := json.Number(text)
+			p.Occupancy = &n
+		} else if b, err := strconv.ParseBool(text); err == nil {
+			p.Occupied = &b
+		} else {
+			p.Status = &text
+		}
+	}
+
+	if p.Occupancy != nil {
+		// Counts are whole people: 2.7, NaN and Inf are sensor faults, not
+		// something to truncate.
+		count, err := strconv.Atoi(p.Occupancy.String())
+		if err != nil {
+			return change, fmt.Errorf("occupancy must be a whole number, got %q", *p.Occupancy)
+		}
+		if count < 0 {
+			return change, fmt.Errorf("occupancy must not be negative, got %d", count)
+		}
+		change.Occupancy = &count
+	}
+
+	switch {
+	case p.Status != nil:
+		status := models.RoomStatus(strings.ToUpper(strings.TrimSpace(*p.Status)))
+		switch status {
+		case models.RoomStatusAvailable, models.RoomStatusBusy, models.RoomStatusOffline:
+			change.Status = status
+		default:
+			return change, fmt.Errorf("unknown status %q", *p.Status)
+		}
+	case p.Occupied != nil:
+		change.Status = statusFor(*p.Occupied)
+	case change.Occupancy != nil:
+		change.Status = statusFor(*change.Occupancy > 0)
+	default:
+		return change, errors.New("payload has no status, occupancy or occupied field")
+	}
+	return change, nil
+}
+
+func statusFor(occupied bool) models.RoomStatus {
+	if occupied {
+		return models.RoomStatusBusy
+	}
+	return models.RoomStatusAvailable
+}
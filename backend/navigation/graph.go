@@ -0,0 +1,190 @@
+// Package navigation derives which rooms of a floorplan connect to each other
+// and finds routes between them for wayfinding.
+package navigation
+
+import (
+	"math"
+	"sort"
+
+	"floorplan-whiteboard/models"
+)
+
+// How an Edge connects its rooms.
+const (
+	ViaDoor     = "door"
+	ViaBoundary = "boundary"
+)
+
+// DefaultTolerance is the widest gap, in pixels, between two room outlines
+// still treated as a shared boundary; floorplans with thicker walls use the
+// thickest wall instead.
+const DefaultTolerance = 10
+
+// MinSharedLength is the shortest shared boundary, in pixels, that connects
+// two rooms; rooms touching over less, such as at a corner, do not connect.
+const MinSharedLength = 10
+
+// maxSkew is the sine of the largest angle between two outline edges still
+// treated as parallel.
+const maxSkew = 0.1
+
+// Node is a room in the graph.
+type Node struct {
+	RoomID string          `json:"room_id"`
+	Name   string          `json:"name"`
+	Type   models.RoomType `json:"type"`
+	Center models.Point    `json:"center"` // Center of the room's bounding rect
+}
+
+// Edge connects two rooms, through a door or a shared boundary.
+type Edge struct {
+	From     string       `json:"from"`
+	To       string       `json:"to"`
+	Via      string       `json:"via"` // ViaDoor or ViaBoundary
+	DoorID   string       `json:"door_id,omitempty"`
+	Point    models.Point `json:"point"`    // Where the edge crosses from one room into the other
+	Distance float64      `json:"distance"` // Pixels from From's center through Point to To's center
+}
+
+// Graph is the room adjacency graph of a floorplan.
+type Graph struct {
+	Nodes []Node `json:"nodes"`
+	Edges []Edge `json:"edges"`
+}
+
+// Build derives fp's room graph. Rooms linked by a door connect through it;
+// other rooms whose outlines run alongside each other for at least
+// MinSharedLength, no further apart than the tolerance, connect through the
+// middle of that shared boundary. Each pair of rooms has at most one edge,
+// preferring doors; edges are ordered by their rooms' order in fp.
+func Build(fp *models.Floorplan) *Graph {
+	g := &Graph{Nodes: []Node{}, Edges: []Edge{}}
+	index := make(map[string]int)
+	var outlines [][]models.Point
+	for _, room := range fp.Rooms {
+		outline := room.Outline()
+		if outline == nil {
+			continue
+		}
+		index[room.ID] = len(g.Nodes)
+		outlines = append(outlines, outline)
+		box := models.BoundingRect(outline)
+		g.Nodes = append(g.Nodes, Node{
+			RoomID: room.ID,
+			Name:   room.Name,
+			Type:   room.Type,
+			Center: models.Point{box[0] + box[2]/2, box[1] + box[3]/2},
+		})
+	}
+
+	type pair struct{ a, b int }
+	edges := make(map[pair]Edge)
+	connect := func(a, b int, via, doorID string, p models.Point) {
+		if a > b {
+			a, b = b, a
+		}
+		e := Edge{
+			From:     g.Nodes[a].RoomID,
+			To:       g.Nodes[b].RoomID,
+			Via:      via,
+			DoorID:   doorID,
+			Point:    p,
+			Distance: distance(g.Nodes[a].Center, p) + distance(p, g.Nodes[b].Center),
+		}
+		if old, ok := edges[pair{a, b}]; ok && old.Distance <= e.Distance {
+			return
+		}
+		edges[pair{a, b}] = e
+	}
+
+	for _, door := range fp.Doors {
+		var linked []int
+		for _, id := range door.RoomIDs {
+			if i, ok := index[id]; ok {
+				linked = append(linked, i)
+			}
+		}
+		if len(linked) >= 2 && linked[0] != linked[1] {
+			connect(linked[0], linked[1], ViaDoor, door.ID, door.Position)
+		}
+	}
+
+	tolerance := float64(DefaultTolerance)
+	for _, wall := range fp.Walls {
+		tolerance = math.Max(tolerance, float64(wall.Thickness))
+	}
+	for a := range g.Nodes {
+		for b := a + 1; b < len(g.Nodes); b++ {
+			if _, ok := edges[pair{a, b}]; ok {
+				continue
+			}
+			if length, p := sharedBoundary(outlines[a], outlines[b], tolerance); length >= MinSharedLength {
+				connect(a, b, ViaBoundary, "", p)
+			}
+		}
+	}
+
+	pairs := make([]pair, 0, len(edges))
+	for p := range edges {
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+	for _, p := range pairs {
+		g.Edges = append(g.Edges, edges[p])
+	}
+	return g
+}
+
+// sharedBoundary returns how long outlines a and b run alongside each other
+// within tolerance pixels, and the middle of the longest such stretch.
+func sharedBoundary(a, b []models.Point, tolerance float64) (float64, models.Point) {
+	var total, longest float64
+	var mid models.Point
+	for i, p := range a {
+		q := a[(i+1)%len(a)]
+		px, py := float64(p[0]), float64(p[1])
+		length := math.Hypot(float64(q[0])-px, float64(q[1])-py)
+		if length == 0 {
+			continue
+		}
+		// Unit direction u along a's edge and its normal (-uy, ux).
+		ux, uy := (float64(q[0])-px)/length, (float64(q[1])-py)/length
+
+		for j, r := range b {
+			s := b[(j+1)%len(b)]
+			rx, ry := float64(r[0])-px, float64(r[1])-py
+			sx, sy := float64(s[0])-px, float64(s[1])-py
+			if other := math.Hypot(sx-rx, sy-ry); other == 0 || math.Abs(ux*(sy-ry)-uy*(sx-rx)) > maxSkew*other {
+				continue
+			}
+			offR, offS := ux*ry-uy*rx, ux*sy-uy*sx
+			if math.Abs(offR) > tolerance || math.Abs(offS) > tolerance {
+				continue
+			}
+			alongR, alongS := ux*rx+uy*ry, ux*sx+uy*sy
+			lo := math.Max(0, math.Min(alongR, alongS))
+			hi := math.Min(length, math.Max(alongR, alongS))
+			if hi <= lo {
+				continue
+			}
+			total += hi - lo
+			if hi-lo > longest {
+				// Halfway between the two edges, in the middle of the overlap.
+				t, off := (lo+hi)/2, (offR+offS)/4
+				longest = hi - lo
+				mid = models.Point{int(math.Round(px + ux*t - uy*off)), int(math.Round(py + uy*t + ux*off))}
+			}
+		}
+	}
+	return total, mid
+}
+
+// distance returns the straight-line distance between a and b in pixels.
+func distance(a, b models.Point) float64 {
+	return math.Hypot(float64(b[0]-a[0]), float64(b[1]-a[1]))
+}
@@ -0,0 +1,120 @@
+package navigation
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"floorplan-whiteboard/models"
+)
+
+// testFloorplan lays out
+//
+//	A B   C    (C 5 pixels right of B)
+//	D          (door to A; touches B only at a corner)
+//
+// plus E, far from the others.
+func testFloorplan() *models.Floorplan {
+	return &models.Floorplan{
+		Rooms: []models.Room{
+			{ID: "A", Name: "Lobby", Rect: models.Rect{0, 0, 100, 100}},
+			{ID: "B", Rect: models.Rect{100, 0, 100, 100}},
+			{ID: "C", Rect: models.Rect{205, 0, 100, 100}},
+			{ID: "D", Rect: models.Rect{0, 100, 100, 100}},
+			{ID: "E", Rect: models.Rect{400, 400, 50, 50}},
+		},
+		Doors: []models.Door{{ID: "door-1", Position: models.Point{50, 100}, Width: 30, RoomIDs: []string{"D", "A"}}},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	g := Build(testFloorplan())
+
+	if len(g.Nodes) != 5 || g.Nodes[0].Name != "Lobby" || g.Nodes[0].Center != (models.Point{50, 50}) {
+		t.Fatalf("nodes = %+v", g.Nodes)
+	}
+	want := []Edge{
+		{From: "A", To: "B", Via: ViaBoundary, Point: models.Point{100, 50}, Distance: 100},
+		{From: "A", To: "D", Via: ViaDoor, DoorID: "door-1", Point: models.Point{50, 100}, Distance: 100},
+		{From: "B", To: "C", Via: ViaBoundary, Point: models.Point{203, 50}, Distance: 105},
+	}
+	if !reflect.DeepEqual(g.Edges, want) {
+		t.Fatalf("edges = %+v, want %+v", g.Edges, want)
+	}
+}
+
+func TestBuild_ToleranceFollowsWalls(t *testing.T) {
+	fp := &models.Floorplan{Rooms: []models.Room{
+		{ID: "A", Rect: models.Rect{0, 0, 100, 100}},
+		{ID: "B", Rect: models.Rect{125, 0, 100, 100}},
+	}}
+	if g := Build(fp); len(g.Edges) != 0 {
+		t.Fatalf("rooms 25 pixels apart should not connect, got %+v", g.Edges)
+	}
+	fp.Walls = []models.Wall{{Start: models.Point{112, 0}, End: models.Point{112, 100}, Thickness: 30}}
+	if g := Build(fp); len(g.Edges) != 1 {
+		t.Fatalf("rooms either side of a 30 pixel wall should connect, got %+v", g.Edges)
+	}
+}
+
+func TestBuild_Polygons(t *testing.T) {
+	// An L-shaped room wrapping around B's top and right sides.
+	fp := &models.Floorplan{Rooms: []models.Room{
+		{ID: "L", Rect: models.Rect{0, 0, 200, 200}, Polygon: []models.Point{{0, 0}, {200, 0}, {200, 200}, {100, 200}, {100, 100}, {0, 100}}},
+		{ID: "B", Rect: models.Rect{0, 100, 100, 100}},
+	}}
+	g := Build(fp)
+	if len(g.Edges) != 1 || g.Edges[0].Via != ViaBoundary {
+		t.Fatalf("edges = %+v", g.Edges)
+	}
+}
+
+func TestShortestPath(t *testing.T) {
+	g := Build(testFloorplan())
+
+	route, err := g.ShortestPath("D", "C")
+	if err != nil {
+		t.Fatalf("ShortestPath() error = %v", err)
+	}
+	var ids []string
+	for _, n := range route.Rooms {
+		ids = append(ids, n.RoomID)
+	}
+	if !reflect.DeepEqual(ids, []string{"D", "A", "B", "C"}) || route.Distance != 305 {
+		t.Fatalf("route = %v, distance %v", ids, route.Distance)
+	}
+	for i, e := range route.Edges {
+		if e.From != ids[i] || e.To != ids[i+1] {
+			t.Errorf("edge %d = %s -> %s, want it oriented along the route", i, e.From, e.To)
+		}
+	}
+
+	if route, err := g.ShortestPath("B", "B"); err != nil || len(route.Rooms) != 1 || len(route.Edges) != 0 || route.Distance != 0 {
+		t.Errorf("ShortestPath(B, B) = %+v, %v", route, err)
+	}
+	if _, err := g.ShortestPath("A", "E"); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("ShortestPath(A, E) error = %v, want ErrNoRoute", err)
+	}
+	if _, err := g.ShortestPath("A", "Z"); !errors.Is(err, ErrUnknownRoom) {
+		t.Errorf("ShortestPath(A, Z) error = %v, want ErrUnknownRoom", err)
+	}
+}
+
+func TestWalkable(t *testing.T) {
+	g := Build(testFloorplan())
+
+	walkable := g.Walkable()
+	if len(walkable.Edges) != 1 || walkable.Edges[0].Via != ViaDoor || len(walkable.Nodes) != len(g.Nodes) {
+		t.Fatalf("Walkable() with a door = %+v, want only the door edge", walkable.Edges)
+	}
+	if _, err := walkable.ShortestPath("D", "C"); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("walkable ShortestPath(D, C) error = %v, want ErrNoRoute through walls", err)
+	}
+
+	fp := testFloorplan()
+	fp.Doors = nil
+	g = Build(fp)
+	if walkable := g.Walkable(); !reflect.DeepEqual(walkable.Edges, g.Edges) {
+		t.Errorf("Walkable() without doors = %+v, want every edge", walkable.Edges)
+	}
+}
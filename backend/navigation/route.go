@@ -0,0 +1,119 @@
+package navigation
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrUnknownRoom is returned for a route endpoint that is not in the graph.
+	ErrUnknownRoom = errors.New("unknown room")
+	// ErrNoRoute is returned when no chain of edges links the two rooms.
+	ErrNoRoute = errors.New("no route between rooms")
+)
+
+// Route is the shortest path between two rooms.
+type Route struct {
+	Rooms    []Node  `json:"rooms"`    // From the start room to the destination
+	Edges    []Edge  `json:"edges"`    // Edges[i] leads from Rooms[i] to Rooms[i+1]
+	Distance float64 `json:"distance"` // Sum of the edges' distances, in pixels
+}
+
+// Walkable returns the part of g a route may follow. Where a floorplan marks
+// doors, rooms that only share a boundary are separated by a wall, so when g
+// has a door edge only door edges are kept; otherwise shared boundaries are
+// the only connections known and every edge is kept.
+func (g *Graph) Walkable() *Graph {
+	for _, e := range g.Edges {
+		if e.Via == ViaDoor {
+			return g.Only(ViaDoor)
+		}
+	}
+	return g
+}
+
+// Only returns a copy of g with just the edges that connect via the given
+// kind, ViaDoor or ViaBoundary.
+func (g *Graph) Only(via string) *Graph {
+	out := &Graph{Nodes: g.Nodes, Edges: []Edge{}}
+	for _, e := range g.Edges {
+		if e.Via == via {
+			out.Edges = append(out.Edges, e)
+		}
+	}
+	return out
+}
+
+// ShortestPath finds the route from room from to room to with the smallest
+// total edge distance. Edges in the route are oriented along it.
+func (g *Graph) ShortestPath(from, to string) (*Route, error) {
+	index := make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		index[n.RoomID] = i
+	}
+	start, ok := index[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRoom, from)
+	}
+	end, ok := index[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownRoom, to)
+	}
+
+	type step struct {
+		node int
+		edge Edge
+	}
+	neighbors := make([][]step, len(g.Nodes))
+	for _, e := range g.Edges {
+		a, b := index[e.From], index[e.To]
+		reversed := e
+		reversed.From, reversed.To = e.To, e.From
+		neighbors[a] = append(neighbors[a], step{b, e})
+		neighbors[b] = append(neighbors[b], step{a, reversed})
+	}
+
+	// Dijkstra's algorithm; floorplans have few enough rooms for a linear scan.
+	dist := make([]float64, len(g.Nodes))
+	prev := make([]step, len(g.Nodes))
+	done := make([]bool, len(g.Nodes))
+	for i := range dist {
+		dist[i] = math.Inf(1)
+	}
+	dist[start] = 0
+	for {
+		u := -1
+		for i := range dist {
+			if !done[i] && !math.IsInf(dist[i], 1) && (u == -1 || dist[i] < dist[u]) {
+				u = i
+			}
+		}
+		if u == -1 || u == end {
+			break
+		}
+		done[u] = true
+		for _, s := range neighbors[u] {
+			if d := dist[u] + s.edge.Distance; d < dist[s.node] {
+				dist[s.node] = d
+				prev[s.node] = step{u, s.edge}
+			}
+		}
+	}
+	if math.IsInf(dist[end], 1) {
+		return nil, ErrNoRoute
+	}
+
+	route := &Route{Rooms: []Node{g.Nodes[end]}, Edges: []Edge{}, Distance: dist[end]}
+	for n := end; n != start; n = prev[n].node {
+		route.Rooms = append(route.Rooms, g.Nodes[prev[n].node])
+		route.Edges = append(route.Edges, prev[n].edge)
+	}
+	for i, j := 0, len(route.Rooms)-1; i < j; i, j = i+1, j-1 {
+		route.Rooms[i], route.Rooms[j] = route.Rooms[j], route.Rooms[i]
+	}
+	for i, j := 0, len(route.Edges)-1; i < j; i, j = i+1, j-1 {
+		route.Edges[i], route.Edges[j] = route.Edges[j], route.Edges[i]
+	}
+	return route, nil
+}
@@ -0,0 +1,254 @@
+package occupancy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"floorplan-whiteboard/models"
+)
+
+// Utilization intervals.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+)
+
+// maxBuckets bounds the number of intervals one utilization query may span.
+const maxBuckets = 24 * 366
+
+var (
+	// ErrInvalidRange is returned for history queries with an empty or too long range or an unknown interval.
+	ErrInvalidRange = errors.New("invalid time range")
+	// ErrNoHistory is returned when the service was created without a history repository.
+	ErrNoHistory = errors.New("status history is not recorded")
+)
+
+// RoomHistory is a room's recorded changes in a time range.
+type RoomHistory struct {
+	RoomID  string                `json:"room_id"`
+	From    time.Time             `json:"from"`
+	To      time.Time             `json:"to"`
+	Initial *models.StatusChange  `json:"initial"` // Last change before From: the room's state at From, if known
+	Changes []models.StatusChange `json:"changes"`
+}
+
+// UtilizationBucket summarizes one hour or day of a room's history. The
+// percentages are shares of the bucket's duration and add up to 100.
+type UtilizationBucket struct {
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	BusyPercent      float64   `json:"busy_percent"`
+	AvailablePercent float64   `json:"available_percent"`
+	OfflinePercent   float64   `json:"offline_percent"`
+	UnknownPercent   float64   `json:"unknown_percent"` // Before the first recorded change, or in the future
+	PeakOccupancy    int       `json:"peak_occupancy"`
+}
+
+// Utilization is the share of time a room was busy, per hour or day.
+type Utilization struct {
+	RoomID      string              `json:"room_id"`
+	Interval    string              `json:"interval"`
+	From        time.Time           `json:"from"`
+	To          time.Time           `json:"to"`
+	BusyPercent float64             `json:"busy_percent"` // Over the whole range
+	Buckets     []UtilizationBucket `json:"buckets"`
+}
+
+// RoomUtilization is the share of a range a room was busy.
+type RoomUtilization struct {
+	RoomID         string  `json:"room_id"`
+	BusyPercent    float64 `json:"busy_percent"`
+	UnknownPercent float64 `json:"unknown_percent"`
+}
+
+// History returns a room's recorded changes with from <= Timestamp < to.
+func (s *Service) History(ctx context.Context, floorplanID, roomID string, from, to time.Time) (*RoomHistory, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	if err := s.checkRoom(ctx, floorplanID, roomID); err != nil {
+		return nil, err
+	}
+
+	initial, err := s.history.LastChangeBefore(ctx, floorplanID, roomID, from)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.history.RoomHistory(ctx, floorplanID, roomID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return &RoomHistory{RoomID: roomID, From: from, To: to, Initial: initial, Changes: changes}, nil
+}
+
+// Utilization aggregates a room's history between from and to into hour or
+// day buckets aligned in loc. Buckets at the ends are clipped to the range.
+func (s *Service) Utilization(ctx context.Context, floorplanID, roomID string, from, to time.Time, interval string, loc *time.Location) (*Utilization, error) {
+	bounds, err := bucketBounds(from, to, interval, loc)
+	if err != nil {
+		return nil, err
+	}
+	h, err := s.History(ctx, floorplanID, roomID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return utilization(h, interval, bounds, time.Now()), nil
+}
+
+// FloorplanUtilization returns how busy each room of fp was between from and
+// to, in the order of fp.Rooms.
+func (s *Service) FloorplanUtilization(ctx context.Context, fp *models.Floorplan, from, to time.Time) ([]RoomUtilization, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	if s.history == nil {
+		return nil, ErrNoHistory
+	}
+
+	now := time.Now()
+	rooms := make([]RoomUtilization, len(fp.Rooms))
+	for i, room := range fp.Rooms {
+		h := &RoomHistory{RoomID: room.ID, From: from, To: to}
+		var err error
+		if h.Initial, err = s.history.LastChangeBefore(ctx, fp.ID, room.ID, from); err != nil {
+			return nil, err
+		}
+		if h.Changes, err = s.history.RoomHistory(ctx, fp.ID, room.ID, from, to); err != nil {
+			return nil, err
+		}
+		u := utilization(h, "", []time.Time{from, to}, now)
+		rooms[i] = RoomUtilization{RoomID: room.ID, BusyPercent: u.BusyPercent, UnknownPercent: u.Buckets[0].UnknownPercent}
+	}
+	return rooms, nil
+}
+
+// checkRoom returns store.ErrNotFound or ErrRoomNotFound for unknown rooms.
+func (s *Service) checkRoom(ctx context.Context, floorplanID, roomID string) error {
+	if s.history == nil {
+		return ErrNoHistory
+	}
+	fp, err := s.repo.Get(ctx, floorplanID)
+	if err != nil {
+		return err
+	}
+	for _, room := range fp.Rooms {
+		if room.ID == roomID {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrRoomNotFound, roomID)
+}
+
+// bucketBounds splits [from, to) at hour or day boundaries in loc.
+func bucketBounds(from, to time.Time, interval string, loc *time.Location) ([]time.Time, error) {
+	if !from.Before(to) {
+		return nil, fmt.Errorf("%w: from must be before to", ErrInvalidRange)
+	}
+	var next func(time.Time) time.Time
+	switch interval {
+	case IntervalHour:
+		next = func(t time.Time) time.Time {
+			t = t.In(loc)
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		}
+	case IntervalDay:
+		next = func(t time.Time) time.Time {
+			t = t.In(loc)
+			return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		}
+	default:
+		return nil, fmt.Errorf("%w: interval must be %s or %s, got %q", ErrInvalidRange, IntervalHour, IntervalDay, interval)
+	}
+
+	bounds := []time.Time{from}
+	for t := from; t.Before(to); {
+		if t = next(t); t.After(to) {
+			t = to
+		}
+		bounds = append(bounds, t)
+		if len(bounds) > maxBuckets+1 {
+			return nil, fmt.Errorf("%w: more than %d %s intervals", ErrInvalidRange, maxBuckets, interval)
+		}
+	}
+	return bounds, nil
+}
+
+// segment is a stretch of time during which a room's state did not change.
+type segment struct {
+	start, end time.Time
+	status     models.RoomStatus
+	occupancy  int
+}
+
+// utilization computes the buckets delimited by bounds from h. Time after now
+// has not happened yet and counts as unknown.
+func utilization(h *RoomHistory, interval string, bounds []time.Time, now time.Time) *Utilization {
+	end := h.To
+	if now.Before(end) {
+		end = now
+	}
+
+	var segments []segment
+	add := func(start time.Time, change models.StatusChange) {
+		if n := len(segments); n > 0 {
+			segments[n-1].end = start
+		}
+		segments = append(segments, segment{start: start, end: end, status: change.Status, occupancy: change.Occupancy})
+	}
+	if h.Initial != nil {
+		add(h.From, *h.Initial)
+	}
+	for _, change := range h.Changes {
+		add(change.Timestamp, change)
+	}
+
+	u := &Utilization{RoomID: h.RoomID, Interval: interval, From: h.From, To: h.To, Buckets: make([]UtilizationBucket, 0, len(bounds)-1)}
+	var busy time.Duration
+	for i := 0; i+1 < len(bounds); i++ {
+		start, stop := bounds[i], bounds[i+1]
+		b := UtilizationBucket{Start: start, End: stop}
+		durations := map[models.RoomStatus]time.Duration{}
+		var known time.Duration
+		for _, seg := range segments {
+			from, to := later(seg.start, start), earlier(seg.end, stop)
+			if !from.Before(to) {
+				continue
+			}
+			durations[seg.status] += to.Sub(from)
+			known += to.Sub(from)
+			b.PeakOccupancy = max(b.PeakOccupancy, seg.occupancy)
+		}
+		length := stop.Sub(start)
+		b.BusyPercent = percent(durations[models.RoomStatusBusy], length)
+		b.AvailablePercent = percent(durations[models.RoomStatusAvailable], length)
+		b.OfflinePercent = percent(durations[models.RoomStatusOffline], length)
+		b.UnknownPercent = percent(length-known, length)
+		busy += durations[models.RoomStatusBusy]
+		u.Buckets = append(u.Buckets, b)
+	}
+	u.BusyPercent = percent(busy, h.To.Sub(h.From))
+	return u
+}
+
+func percent(part, whole time.Duration) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return float64(part) / float64(whole) * 100
+}
+
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlier(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
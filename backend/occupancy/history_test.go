@@ -0,0 +1,86 @@
+package occupancy
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/models"
+)
+
+func TestBucketBounds(t *testing.T) {
+	from := time.Date(2026, 3, 2, 9, 30, 0, 0, time.UTC)
+	bounds, err := bucketBounds(from, from.Add(2*time.Hour), IntervalHour, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{from, from.Add(30 * time.Minute), from.Add(90 * time.Minute), from.Add(2 * time.Hour)}
+	if len(bounds) != len(want) {
+		t.Fatalf("bounds = %v, want %v", bounds, want)
+	}
+	for i := range want {
+		if !bounds[i].Equal(want[i]) {
+			t.Errorf("bounds[%d] = %v, want %v", i, bounds[i], want[i])
+		}
+	}
+
+	// Days split at local midnight.
+	tokyo := time.FixedZone("JST", 9*3600)
+	days, err := bucketBounds(from, from.Add(24*time.Hour), IntervalDay, tokyo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 3 || !days[1].Equal(time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Errorf("day bounds = %v, want a split at 15:00 UTC", days)
+	}
+
+	for _, tc := range []struct {
+		from, to time.Time
+		interval string
+	}{
+		{from, from, IntervalHour},
+		{from, from.Add(time.Hour), "week"},
+		{from, from.AddDate(2, 0, 0), IntervalHour},
+	} {
+		if _, err := bucketBounds(tc.from, tc.to, tc.interval, time.UTC); !errors.Is(err, ErrInvalidRange) {
+			t.Errorf("bucketBounds(%v, %v, %s) error = %v, want ErrInvalidRange", tc.from, tc.to, tc.interval, err)
+		}
+	}
+}
+
+func TestUtilization(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2026, 3, 2, hour, minute, 0, 0, time.UTC) }
+	h := &RoomHistory{
+		RoomID:  "room-a",
+		From:    at(9, 0),
+		To:      at(12, 0),
+		Initial: &models.StatusChange{Status: models.RoomStatusAvailable, Timestamp: at(7, 0)},
+		Changes: []models.StatusChange{
+			{Status: models.RoomStatusBusy, Occupancy: 5, Timestamp: at(9, 15)},
+			{Status: models.RoomStatusAvailable, Timestamp: at(10, 30)},
+			{Status: models.RoomStatusOffline, Timestamp: at(11, 0)},
+		},
+	}
+	bounds, _ := bucketBounds(h.From, h.To, IntervalHour, time.UTC)
+	// It is 11:30: the last half hour has not happened yet.
+	u := utilization(h, IntervalHour, bounds, at(11, 30))
+
+	want := []UtilizationBucket{
+		{BusyPercent: 75, AvailablePercent: 25, PeakOccupancy: 5},
+		{BusyPercent: 50, AvailablePercent: 50, PeakOccupancy: 5},
+		{OfflinePercent: 50, UnknownPercent: 50},
+	}
+	if len(u.Buckets) != len(want) {
+		t.Fatalf("buckets = %+v", u.Buckets)
+	}
+	for i, b := range u.Buckets {
+		b.Start, b.End = time.Time{}, time.Time{}
+		if b != want[i] {
+			t.Errorf("bucket %d = %+v, want %+v", i, b, want[i])
+		}
+	}
+	if math.Abs(u.BusyPercent-125.0/3) > 1e-9 {
+		t.Errorf("BusyPercent = %v, want 41.67 (75 of 180 minutes)", u.BusyPercent)
+	}
+}
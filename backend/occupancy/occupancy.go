@@ -0,0 +1,184 @@
+// Package occupancy applies live room status and occupancy changes reported
+// by sensors or booking systems: it updates the stored rooms and publishes
+// the changes to realtime subscribers.
+package occupancy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/realtime"
+	"floorplan-whiteboard/store"
+)
+
+var (
+	// ErrRoomNotFound is returned when a change names a room the floorplan does not have.
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrInvalidChange is returned for changes with an unknown status or negative occupancy.
+	ErrInvalidChange = errors.New("invalid status change")
+)
+
+// Publisher receives every applied change; *realtime.Hub implements it.
+type Publisher interface {
+	PublishStatus(update realtime.StatusUpdate)
+}
+
+// Change sets the status and/or occupancy of one room. Omitted fields keep
+// their stored value.
+type Change struct {
+	RoomID    string            `json:"room_id"`
+	Status    models.RoomStatus `json:"status,omitempty"`
+	Occupancy *int              `json:"occupancy,omitempty"`
+}
+
+// Service applies changes to stored floorplans and records them in the
+// room status history.
+type Service struct {
+	repo      store.FloorplanRepository
+	history   store.HistoryRepository
+	publisher Publisher
+
+	mutex sync.Mutex
+	locks map[string]*floorplanLock // By floorplan ID, while in use
+}
+
+// floorplanLock serializes the changes to one floorplan.
+type floorplanLock struct {
+	sync.Mutex
+	users int // Apply calls holding or waiting for it; guarded by Service.mutex
+}
+
+// NewService creates a Service; history and publisher may be nil.
+func NewService(repo store.FloorplanRepository, history store.HistoryRepository, publisher Publisher) *Service {
+	return &Service{repo: repo, history: history, publisher: publisher, locks: make(map[string]*floorplanLock)}
+}
+
+// lock locks floorplanID's changes and returns the unlock function.
+func (s *Service) lock(floorplanID string) func() {
+	s.mutex.Lock()
+	l, ok := s.locks[floorplanID]
+	if !ok {
+		l = &floorplanLock{}
+		s.locks[floorplanID] = l
+	}
+	l.users++
+	s.mutex.Unlock()
+
+	l.Lock()
+	return func() {
+		l.Unlock()
+		s.mutex.Lock()
+		if l.users--; l.users == 0 {
+			delete(s.locks, floorplanID)
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// Apply validates and stores all changes to the floorplan atomically, then
+// publishes one status update per changed room. Changes that alter a room's
+// status or occupancy are recorded in the history. Calls for the same
+// floorplan run one at a time, so updates are published in the order they
+// were stored. It returns the updated rooms in the order of changes.
+func (s *Service) Apply(ctx context.Context, floorplanID string, changes []Change) ([]models.Room, error) {
+	for _, change := range changes {
+		if err := validate(change); err != nil {
+			return nil, err
+		}
+	}
+
+	// Store, record and publish under one lock; otherwise two changes to a
+	// room could be stored A then B but published B then A, leaving
+	// subscribers with the stale status.
+	defer s.lock(floorplanID)()
+
+	var now time.Time
+	var updated []models.Room
+	var recorded []models.StatusChange
+	// Modify keeps the rooms from changing between reading and storing them,
+	// so concurrent changes and room edits are not lost.
+	fp, err := s.repo.Modify(ctx, floorplanID, func(fp *models.Floorplan) error {
+		// Taken with the floorplan loaded for writing, so history timestamps
+		// follow the order changes are stored in.
+		now = time.Now().UTC()
+		index := make(map[string]int, len(fp.Rooms))
+		for i, room := range fp.Rooms {
+			index[room.ID] = i
+		}
+
+		updated = make([]models.Room, 0, len(changes))
+		recorded = nil
+		for _, change := range changes {
+			i, ok := index[change.RoomID]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrRoomNotFound, change.RoomID)
+			}
+			room := &fp.Rooms[i]
+			before := *room
+			if change.Status != "" {
+				room.Status = change.Status
+			}
+			if change.Occupancy != nil {
+				room.Occupancy = *change.Occupancy
+			}
+			updated = append(updated, *room)
+			if room.Status != before.Status || room.Occupancy != before.Occupancy {
+				recorded = append(recorded, models.StatusChange{
+					FloorplanID: fp.ID,
+					RoomID:      room.ID,
+					Status:      room.Status,
+					Occupancy:   room.Occupancy,
+					Timestamp:   now,
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The rooms are already updated, so a history failure only loses the record.
+	if s.history != nil && len(recorded) > 0 {
+		if err := s.history.AppendHistory(ctx, recorded); err != nil {
+			log.Printf("Failed to record status history for floorplan %s: %v", fp.ID, err)
+		}
+	}
+
+	if s.publisher != nil {
+		for _, room := range updated {
+			s.publisher.PublishStatus(realtime.StatusUpdate{
+				FloorplanID: fp.ID,
+				RoomID:      room.ID,
+				Status:      room.Status,
+				Occupancy:   room.Occupancy,
+				Timestamp:   now,
+			})
+		}
+	}
+	return updated, nil
+}
+
+func validate(change Change) error {
+	if change.RoomID == "" {
+		return fmt.Errorf("%w: room_id is required", ErrInvalidChange)
+	}
+	switch change.Status {
+	case "":
+		if change.Occupancy == nil {
+			return fmt.Errorf("%w: room %s: status or occupancy is required", ErrInvalidChange, change.RoomID)
+		}
+	case models.RoomStatusAvailable, models.RoomStatusBusy, models.RoomStatusOffline:
+	default:
+		return fmt.Errorf("%w: room %s: unknown status %q", ErrInvalidChange, change.RoomID, change.Status)
+	}
+	if change.Occupancy != nil && *change.Occupancy < 0 {
+		return fmt.Errorf("%w: room %s: occupancy must not be negative", ErrInvalidChange, change.RoomID)
+	}
+	return nil
+}
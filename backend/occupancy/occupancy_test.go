@@ -0,0 +1,177 @@
+package occupancy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/realtime"
+	"floorplan-whiteboard/store"
+)
+
+type recordingPublisher struct {
+	updates []realtime.StatusUpdate
+}
+
+func (p *recordingPublisher) PublishStatus(update realtime.StatusUpdate) {
+	p.updates = append(p.updates, update)
+}
+
+func seed(t *testing.T, repo store.FloorplanRepository) *models.Floorplan {
+	t.Helper()
+	fp := &models.Floorplan{
+		Filename: "plan.png",
+		Rooms: []models.Room{
+			{ID: "room-a", Name: "Office 101", Status: models.RoomStatusAvailable},
+			{ID: "room-b", Name: "Meeting Room A", Status: models.RoomStatusAvailable},
+		},
+	}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+	return fp
+}
+
+func intPtr(v int) *int { return &v }
+
+func TestApply_UpdatesStoreAndPublishes(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemoryRepository()
+	fp := seed(t, repo)
+	pub := &recordingPublisher{}
+	svc := NewService(repo, repo, pub)
+
+	rooms, err := svc.Apply(ctx, fp.ID, []Change{
+		{RoomID: "room-b", Status: models.RoomStatusBusy, Occupancy: intPtr(4)},
+		{RoomID: "room-a", Occupancy: intPtr(1)},
+	})
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(rooms) != 2 || rooms[0].ID != "room-b" || rooms[0].Status != models.RoomStatusBusy || rooms[0].Occupancy != 4 {
+		t.Fatalf("rooms = %+v", rooms)
+	}
+	if rooms[1].Status != models.RoomStatusAvailable || rooms[1].Occupancy != 1 {
+		t.Errorf("occupancy-only change altered status: %+v", rooms[1])
+	}
+
+	stored, _ := repo.Get(ctx, fp.ID)
+	if stored.Rooms[1].Status != models.RoomStatusBusy || stored.Rooms[1].Occupancy != 4 {
+		t.Errorf("stored room = %+v, want BUSY/4", stored.Rooms[1])
+	}
+
+	if len(pub.updates) != 2 {
+		t.Fatalf("published %d updates, want 2", len(pub.updates))
+	}
+	u := pub.updates[0]
+	if u.FloorplanID != fp.ID || u.RoomID != "room-b" || u.Status != models.RoomStatusBusy || u.Occupancy != 4 || u.Timestamp.IsZero() {
+		t.Errorf("published update = %+v", u)
+	}
+}
+
+// storedPublisher checks that every update it receives is still what the
+// store holds, i.e. that no other change was stored before it was published.
+type storedPublisher struct {
+	t    *testing.T
+	repo store.FloorplanRepository
+}
+
+func (p *storedPublisher) PublishStatus(update realtime.StatusUpdate) {
+	time.Sleep(time.Millisecond) // Give a racing change time to be stored
+	fp, _ := p.repo.Get(context.Background(), update.FloorplanID)
+	if got := fp.Rooms[0].Occupancy; got != update.Occupancy {
+		p.t.Errorf("published occupancy %d while %d is stored", update.Occupancy, got)
+	}
+}
+
+func TestApply_PublishesInStoreOrder(t *testing.T) {
+	repo := store.NewMemoryRepository()
+	fp := seed(t, repo)
+	svc := NewService(repo, nil, &storedPublisher{t: t, repo: repo})
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.Apply(context.Background(), fp.ID, []Change{{RoomID: "room-a", Occupancy: intPtr(i)}}); err != nil {
+				t.Errorf("Apply() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if len(svc.locks) != 0 {
+		t.Errorf("%d floorplan locks left after every Apply returned", len(svc.locks))
+	}
+}
+
+func TestApply_RejectsWholeBatch(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemoryRepository()
+	fp := seed(t, repo)
+	pub := &recordingPublisher{}
+	svc := NewService(repo, repo, pub)
+
+	cases := []struct {
+		name    string
+		changes []Change
+		want    error
+	}{
+		{"unknown status", []Change{{RoomID: "room-a", Status: "BUSY"}, {RoomID: "room-b", Status: "ON_FIRE"}}, ErrInvalidChange},
+		{"negative occupancy", []Change{{RoomID: "room-a", Occupancy: intPtr(-1)}}, ErrInvalidChange},
+		{"empty change", []Change{{RoomID: "room-a"}}, ErrInvalidChange},
+		{"unknown room", []Change{{RoomID: "room-a", Status: "BUSY"}, {RoomID: "room-z", Status: "BUSY"}}, ErrRoomNotFound},
+	}
+	for _, tc := range cases {
+		if _, err := svc.Apply(ctx, fp.ID, tc.changes); !errors.Is(err, tc.want) {
+			t.Errorf("%s: error = %v, want %v", tc.name, err, tc.want)
+		}
+	}
+
+	if _, err := svc.Apply(ctx, "missing", []Change{{RoomID: "room-a", Status: "BUSY"}}); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("missing floorplan: error = %v, want store.ErrNotFound", err)
+	}
+
+	stored, _ := repo.Get(ctx, fp.ID)
+	if stored.Rooms[0].Status != models.RoomStatusAvailable {
+		t.Errorf("rejected batch was partially stored: %+v", stored.Rooms[0])
+	}
+	if len(pub.updates) != 0 {
+		t.Errorf("rejected batches published %d updates", len(pub.updates))
+	}
+}
+
+func TestApply_RecordsChangesInHistory(t *testing.T) {
+	ctx := context.Background()
+	repo := store.NewMemoryRepository()
+	fp := seed(t, repo)
+	svc := NewService(repo, repo, nil)
+
+	for _, change := range []Change{
+		{RoomID: "room-a", Status: models.RoomStatusBusy},
+		{RoomID: "room-a", Status: models.RoomStatusBusy}, // Repeated reading: no change
+		{RoomID: "room-a", Occupancy: intPtr(2)},
+	} {
+		if _, err := svc.Apply(ctx, fp.ID, []Change{change}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := svc.History(ctx, fp.ID, "room-a", time.Now().Add(-time.Minute), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(h.Changes) != 2 || h.Changes[0].Status != models.RoomStatusBusy || h.Changes[1].Occupancy != 2 || h.Initial != nil {
+		t.Errorf("history = %+v, want BUSY then occupancy 2", h)
+	}
+
+	if _, err := svc.History(ctx, fp.ID, "room-z", time.Now().Add(-time.Minute), time.Now()); !errors.Is(err, ErrRoomNotFound) {
+		t.Errorf("unknown room: error = %v, want ErrRoomNotFound", err)
+	}
+	if _, err := NewService(repo, nil, nil).History(ctx, fp.ID, "room-a", time.Now().Add(-time.Minute), time.Now()); !errors.Is(err, ErrNoHistory) {
+		t.Errorf("no history repository: error = %v, want ErrNoHistory", err)
+	}
+}
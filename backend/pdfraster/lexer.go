@@ -0,0 +1,274 @@
+package pdfraster
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// Content stream tokens. Operands are float64, name, []byte (string
+// literals), []interface{} (arrays), map[name]interface{} (dictionaries),
+// bool or nil; operators are returned as keyword.
+type (
+	name    string
+	keyword string
+)
+
+// Closing delimiters returned by lexer.next while parsing arrays and dicts.
+type delimiter byte
+
+// lexer splits a decoded content stream into tokens.
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(b byte) bool {
+	switch b {
+	case 0, '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelimiter(b byte) bool {
+	switch b {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+func isRegular(b byte) bool {
+	return !isWhitespace(b) && !isDelimiter(b)
+}
+
+// skipSpace skips whitespace and comments.
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		if isWhitespace(b) {
+			l.pos++
+			continue
+		}
+		if b == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+			continue
+		}
+		return
+	}
+}
+
+// next returns the next token, or false at the end of the stream.
+func (l *lexer) next() (interface{}, bool) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return nil, false
+	}
+
+	b := l.data[l.pos]
+	switch {
+	case b == '/':
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.data) && isRegular(l.data[l.pos]) {
+			l.pos++
+		}
+		return name(l.data[start:l.pos]), true
+
+	case b == '(':
+		return l.literalString(), true
+
+	case b == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		l.pos += 2
+		return l.dict(), true
+
+	case b == '<':
+		return l.hexString(), true
+
+	case b == '>' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '>':
+		l.pos += 2
+		return delimiter('>'), true
+
+	case b == '[':
+		l.pos++
+		return l.array(), true
+
+	case b == ']':
+		l.pos++
+		return delimiter(']'), true
+
+	case isDelimiter(b):
+		// Stray delimiter ({, }, ), >): skip it.
+		l.pos++
+		return l.next()
+	}
+
+	start := l.pos
+	for l.pos < len(l.data) && isRegular(l.data[l.pos]) {
+		l.pos++
+	}
+	word := string(l.data[start:l.pos])
+
+	if c := word[0]; c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9') {
+		if f, err := strconv.ParseFloat(word, 64); err == nil {
+			return f, true
+		}
+	}
+	switch word {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	case "null":
+		return nil, true
+	}
+	return keyword(word), true
+}
+
+// literalString consumes a balanced (...) string starting at the current
+// position and returns it with escape sequences resolved.
+func (l *lexer) literalString() []byte {
+	l.pos++ // (
+	var s []byte
+	depth := 1
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		switch b {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s
+			}
+		case '\\':
+			if l.pos >= len(l.data) {
+				return s
+			}
+			b = l.data[l.pos]
+			l.pos++
+			switch b {
+			case 'n':
+				b = '\n'
+			case 'r':
+				b = '\r'
+			case 't':
+				b = '\t'
+			case 'b':
+				b = '\b'
+			case 'f':
+				b = '\f'
+			case '\r', '\n': // Line continuation
+				if b == '\r' && l.pos < len(l.data) && l.data[l.pos] == '\n' {
+					l.pos++
+				}
+				continue
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				v := b - '0'
+				for i := 0; i < 2 && l.pos < len(l.data) && l.data[l.pos] >= '0' && l.data[l.pos] <= '7'; i++ {
+					v = v<<3 | (l.data[l.pos] - '0')
+					l.pos++
+				}
+				b = v
+			}
+		}
+		s = append(s, b)
+	}
+	return s
+}
+
+// hexString consumes a <...> string starting at the current position. A
+// missing final digit is taken as 0.
+func (l *lexer) hexString() []byte {
+	l.pos++ // <
+	var s []byte
+	var digits int
+	for l.pos < len(l.data) {
+		b := l.data[l.pos]
+		l.pos++
+		var v byte
+		switch {
+		case b == '>':
+			return s
+		case b >= '0' && b <= '9':
+			v = b - '0'
+		case b >= 'a' && b <= 'f':
+			v = b - 'a' + 10
+		case b >= 'A' && b <= 'F':
+			v = b - 'A' + 10
+		default:
+			continue // Whitespace
+		}
+		if digits%2 == 0 {
+			s = append(s, v<<4)
+		} else {
+			s[len(s)-1] |= v
+		}
+		digits++
+	}
+	return s
+}
+
+func (l *lexer) array() []interface{} {
+	var items []interface{}
+	for {
+		tok, ok := l.next()
+		if !ok || tok == delimiter(']') {
+			return items
+		}
+		items = append(items, tok)
+	}
+}
+
+func (l *lexer) dict() map[name]interface{} {
+	d := make(map[name]interface{})
+	for {
+		tok, ok := l.next()
+		if !ok || tok == delimiter('>') {
+			return d
+		}
+		key, isName := tok.(name)
+		if !isName {
+			continue
+		}
+		value, ok := l.next()
+		if !ok || value == delimiter('>') {
+			return d
+		}
+		d[key] = value
+	}
+}
+
+// skipInlineImage skips the data of an inline image (BI ... ID <data> EI),
+// called after the BI operator has been read.
+func (l *lexer) skipInlineImage() {
+	for {
+		tok, ok := l.next()
+		if !ok {
+			return
+		}
+		if tok == keyword("ID") {
+			break
+		}
+	}
+	l.pos++ // Single whitespace after ID
+
+	for l.pos+2 <= len(l.data) {
+		i := bytes.Index(l.data[l.pos:], []byte("EI"))
+		if i < 0 {
+			l.pos = len(l.data)
+			return
+		}
+		at := l.pos + i
+		l.pos = at + 2
+		before := at == 0 || isWhitespace(l.data[at-1])
+		after := l.pos >= len(l.data) || !isRegular(l.data[l.pos])
+		if before && after {
+			return
+		}
+	}
+	l.pos = len(l.data)
+}
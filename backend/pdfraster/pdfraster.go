@@ -0,0 +1,198 @@
+// Package pdfraster renders the pages of a PDF to images in pure Go, so that
+// floorplan PDFs can go through the same room detection as raster uploads.
+//
+// Only what floorplans need is drawn: vector paths (filled and stroked),
+// embedded images and text. Text is drawn with a substitute font at the
+// document's positions and widths; text in composite fonts without a
+// ToUnicode map, shadings, clipping paths and transparency are ignored.
+package pdfraster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+
+	"floorplan-whiteboard/config"
+
+	"github.com/disintegration/imaging"
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+)
+
+// DefaultMaxPagePixels caps the size of a single rendered page when
+// Options.MaxPagePixels is not set: 160 MB as RGBA, enough for A0 at 150 DPI.
+const DefaultMaxPagePixels = 40_000_000
+
+var (
+	// ErrTooManyPages is returned when a PDF has more pages than Options.MaxPages.
+	ErrTooManyPages = errors.New("too many pages")
+	// ErrPageTooLarge is returned when a page would exceed Options.MaxPagePixels at the requested DPI.
+	ErrPageTooLarge = errors.New("page too large to rasterize")
+
+	// errCrashed wraps panics recovered while reading or rendering a PDF.
+	errCrashed = errors.New("PDF renderer crashed")
+)
+
+func init() {
+	// Keep pdfcpu from creating a configuration directory in the user's home.
+	api.DisableConfigDir()
+}
+
+// Options controls rasterization.
+type Options struct {
+	DPI           float64 // Output resolution; PDF user space is 72 units per inch
+	MaxPages      int     // Documents with more pages are rejected; 0 = no limit
+	MaxPagePixels int     // Larger pages are rejected; 0 = DefaultMaxPagePixels
+}
+
+// DefaultOptions returns the options used when no configuration is given.
+func DefaultOptions() Options {
+	return Options{DPI: 150, MaxPages: 20, MaxPagePixels: DefaultMaxPagePixels}
+}
+
+// OptionsFromConfig converts the pdf section of the backend configuration.
+func OptionsFromConfig(cfg config.PDFConfig) Options {
+	return Options{DPI: cfg.DPI, MaxPages: cfg.MaxPages, MaxPagePixels: cfg.MaxPagePixels}
+}
+
+// IsPDF reports whether data starts with the PDF file signature.
+func IsPDF(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\n\f\r "), []byte("%PDF-"))
+}
+
+// Document is a PDF opened for rendering one page at a time, so callers need
+// not hold every page's image at once.
+type Document struct {
+	ctx       *model.Context
+	dpi       float64
+	maxPixels int
+}
+
+// Open reads the PDF in data and checks its page count against opts.MaxPages.
+// Panics in pdfcpu are returned as errors, as they are by Render.
+func Open(data []byte, opts Options) (doc *Document, err error) {
+	defer recoverCrash(&err)
+	if opts.DPI <= 0 {
+		return nil, fmt.Errorf("invalid DPI %v", opts.DPI)
+	}
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+	ctx, err := api.ReadContext(bytes.NewReader(data), conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if err := ctx.EnsurePageCount(); err != nil {
+		return nil, fmt.Errorf("failed to read PDF page tree: %w", err)
+	}
+	if ctx.PageCount == 0 {
+		return nil, errors.New("PDF has no pages")
+	}
+	if opts.MaxPages > 0 && ctx.PageCount > opts.MaxPages {
+		return nil, fmt.Errorf("%w: PDF has %d pages, at most %d are allowed", ErrTooManyPages, ctx.PageCount, opts.MaxPages)
+	}
+	maxPixels := opts.MaxPagePixels
+	if maxPixels <= 0 {
+		maxPixels = DefaultMaxPagePixels
+	}
+	return &Document{ctx: ctx, dpi: opts.DPI, maxPixels: maxPixels}, nil
+}
+
+// PageCount returns the number of pages in the document.
+func (d *Document) PageCount() int {
+	return d.ctx.PageCount
+}
+
+// Render draws page pageNr (1-based) on a white background. Panics in
+// pdfcpu, the image decoders or the renderer are returned as errors, so
+// that a malformed upload fails on its own.
+func (d *Document) Render(pageNr int) (img image.Image, err error) {
+	defer recoverCrash(&err)
+	if pageNr < 1 || pageNr > d.ctx.PageCount {
+		return nil, fmt.Errorf("page %d out of range 1..%d", pageNr, d.ctx.PageCount)
+	}
+	if img, err = renderPage(d.ctx, pageNr, d.dpi, d.maxPixels); err != nil {
+		return nil, fmt.Errorf("page %d: %w", pageNr, err)
+	}
+	return img, nil
+}
+
+// Rasterize renders every page of the PDF in data, in page order. All pages
+// are held in memory at once; use Open and Render to process them one by one.
+func Rasterize(data []byte, opts Options) ([]image.Image, error) {
+	doc, err := Open(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]image.Image, 0, doc.PageCount())
+	for pageNr := 1; pageNr <= doc.PageCount(); pageNr++ {
+		img, err := doc.Render(pageNr)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, img)
+	}
+	return pages, nil
+}
+
+// recoverCrash turns a panic in the deferring function into an error wrapping
+// errCrashed. It must be deferred directly.
+func recoverCrash(err *error) {
+	if p := recover(); p != nil {
+		*err = fmt.Errorf("%w: %v", errCrashed, p)
+	}
+}
+
+// renderPage draws one page's visible box (CropBox, else MediaBox) and applies
+// the page's /Rotate. Pages over maxPixels are rejected before allocating.
+func renderPage(ctx *model.Context, pageNr int, dpi float64, maxPixels int) (image.Image, error) {
+	pageDict, _, attrs, err := ctx.PageDict(pageNr, false)
+	if err != nil {
+		return nil, err
+	}
+
+	box := attrs.CropBox
+	if box == nil {
+		box = attrs.MediaBox
+	}
+	if box == nil {
+		box = types.NewRectangle(0, 0, 612, 792) // US Letter
+	}
+
+	scale := dpi / 72
+	w := int(math.Ceil(math.Abs(box.Width()) * scale))
+	h := int(math.Ceil(math.Abs(box.Height()) * scale))
+	if w <= 0 || h <= 0 {
+		return nil, errors.New("page has an empty media box")
+	}
+	if w*h > maxPixels {
+		return nil, fmt.Errorf("%w: %dx%d pixels at %v DPI", ErrPageTooLarge, w, h, dpi)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range dst.Pix {
+		dst.Pix[i] = 0xff // White, opaque background
+	}
+
+	content, err := ctx.PageContent(pageDict, pageNr)
+	if err != nil && !errors.Is(err, model.ErrNoContent) {
+		return nil, err
+	}
+
+	// PDF user space has its origin bottom-left with y up; the image has y down.
+	device := matrix{scale, 0, 0, -scale, -box.LL.X * scale, box.UR.Y * scale}
+	newRenderer(ctx, dst, device).run(content, attrs.Resources, 0)
+
+	switch ((attrs.Rotate % 360) + 360) % 360 {
+	case 90:
+		return imaging.Rotate270(dst), nil // /Rotate is clockwise, imaging rotates counter-clockwise
+	case 180:
+		return imaging.Rotate180(dst), nil
+	case 270:
+		return imaging.Rotate90(dst), nil
+	}
+	return dst, nil
+}
@@ -0,0 +1,253 @@
+package pdfraster
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"testing"
+)
+
+type testPage struct {
+	content string
+	extra   string // Additional page dictionary entries
+}
+
+// buildPDF assembles a minimal PDF with 200x100pt pages and a valid xref
+// table. extra objects are numbered after the pages, from 3+2*len(pages).
+func buildPDF(pages []testPage, extra ...string) []byte {
+	var objects []string
+	kids := ""
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 3+2*i)
+	}
+	objects = append(objects,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)),
+	)
+	for i, p := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 100] /Contents %d 0 R %s >>", 4+2*i, p.extra),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(p.content)+1, p.content),
+		)
+	}
+
+	objects = append(objects, extra...)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func rgbaAt(img image.Image, x, y int) color.RGBA {
+	return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+}
+
+func TestRasterize_PathsAndColors(t *testing.T) {
+	data := buildPDF([]testPage{
+		{content: "0 0 1 rg 10 10 50 30 re f"},
+		{content: "0 G 4 w 0 50 m 200 50 l S q 1 0 0 1 100 0 cm 1 0 0 rg 10 10 20 20 re f Q"},
+	})
+
+	pages, err := Rasterize(data, Options{DPI: 72})
+	if err != nil {
+		t.Fatalf("Rasterize() error = %v", err)
+	}
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages, want 2", len(pages))
+	}
+	if b := pages[0].Bounds(); b.Dx() != 200 || b.Dy() != 100 {
+		t.Fatalf("page size = %v, want 200x100 at 72 DPI", b.Size())
+	}
+
+	white := color.RGBA{255, 255, 255, 255}
+	cases := []struct {
+		page int
+		x, y int
+		want color.RGBA
+	}{
+		{0, 35, 75, color.RGBA{0, 0, 255, 255}}, // Inside the blue rect (y flipped)
+		{0, 35, 25, white},
+		{0, 100, 50, white},
+		{1, 100, 50, color.RGBA{0, 0, 0, 255}}, // On the stroked line
+		{1, 100, 30, white},
+		{1, 120, 80, color.RGBA{255, 0, 0, 255}}, // Rect translated by cm
+		{1, 20, 80, white},                       // Untranslated position stays empty
+	}
+	for _, tc := range cases {
+		if got := rgbaAt(pages[tc.page], tc.x, tc.y); got != tc.want {
+			t.Errorf("page %d pixel (%d,%d) = %v, want %v", tc.page+1, tc.x, tc.y, got, tc.want)
+		}
+	}
+}
+
+func TestRasterize_DPIAndRotation(t *testing.T) {
+	data := buildPDF([]testPage{{content: "0 g 0 0 20 100 re f", extra: "/Rotate 90"}})
+
+	pages, err := Rasterize(data, Options{DPI: 144})
+	if err != nil {
+		t.Fatalf("Rasterize() error = %v", err)
+	}
+	if b := pages[0].Bounds(); b.Dx() != 200 || b.Dy() != 400 {
+		t.Fatalf("rotated page size = %v, want 200x400", b.Size())
+	}
+	// The left edge of the page becomes the top edge after a clockwise turn.
+	if got := rgbaAt(pages[0], 100, 10); got.R != 0 {
+		t.Errorf("top pixel = %v, want black bar", got)
+	}
+	if got := rgbaAt(pages[0], 100, 390); got.R != 255 {
+		t.Errorf("bottom pixel = %v, want white", got)
+	}
+}
+
+func TestRasterize_ImageXObject(t *testing.T) {
+	// A 2x1 RGB image (red, blue) scaled onto the rectangle x 50-150, y 25-75.
+	img := "<< /Type /XObject /Subtype /Image /Width 2 /Height 1 /ColorSpace /DeviceRGB /BitsPerComponent 8 /Length 6 >>\n" +
+		"stream\n\xff\x00\x00\x00\x00\xff\nendstream"
+	page := testPage{
+		content: "q 100 0 0 50 50 25 cm /Im1 Do Q",
+		extra:   "/Resources << /XObject << /Im1 5 0 R >> >>",
+	}
+
+	pages, err := Rasterize(buildPDF([]testPage{page}, img), Options{DPI: 72})
+	if err != nil {
+		t.Fatalf("Rasterize() error = %v", err)
+	}
+	if got := rgbaAt(pages[0], 70, 50); got.R < 200 || got.B > 50 {
+		t.Errorf("left half = %v, want red", got)
+	}
+	if got := rgbaAt(pages[0], 130, 50); got.B < 200 || got.R > 50 {
+		t.Errorf("right half = %v, want blue", got)
+	}
+	if got := rgbaAt(pages[0], 30, 50); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("outside image = %v, want white", got)
+	}
+}
+
+func TestRasterize_SkipsInlineImages(t *testing.T) {
+	content := "BI /W 1 /H 1 /BPC 8 /CS /G ID \x00 EI\n" +
+		"0 g 150 10 20 20 re f"
+	pages, err := Rasterize(buildPDF([]testPage{{content: content}}), Options{DPI: 72})
+	if err != nil {
+		t.Fatalf("Rasterize() error = %v", err)
+	}
+	if got := rgbaAt(pages[0], 160, 80); got.R != 0 {
+		t.Errorf("pixel after inline image = %v, want black rect", got)
+	}
+}
+
+func TestRasterize_Text(t *testing.T) {
+	const toUnicode = "/CIDInit /ProcSet findresource begin 1 begincmap\n" +
+		"1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"1 beginbfrange <0001> <0002> <004C> endbfrange\n" +
+		"endcmap end"
+	content := "BT /F1 20 Tf 10 10 Td (LL) Tj ET\n" +
+		"BT /F2 20 Tf 10 40 Td <00010001> Tj ET\n" +
+		"BT 3 Tr /F1 20 Tf 10 70 Td (LL) Tj ET"
+	page := testPage{content: content, extra: "/Resources << /Font << /F1 5 0 R /F2 6 0 R >> >>"}
+	data := buildPDF([]testPage{page},
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+		"<< /Type /Font /Subtype /Type0 /BaseFont /X /Encoding /Identity-H /ToUnicode 7 0 R "+
+			"/DescendantFonts [<< /Type /Font /Subtype /CIDFontType2 /DW 600 >>] >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(toUnicode)+1, toUnicode))
+
+	pages, err := Rasterize(data, Options{DPI: 72})
+	if err != nil {
+		t.Fatalf("Rasterize() error = %v", err)
+	}
+	// The stem of the first L sits just right of the text origin; the page
+	// is 100 units high, so a baseline at y is at row 100-y.
+	darkIn := func(y int) bool {
+		for x := 10; x < 20; x++ {
+			if rgbaAt(pages[0], x, y).R < 128 {
+				return true
+			}
+		}
+		return false
+	}
+	if !darkIn(100 - 17) {
+		t.Error("simple font text was not drawn")
+	}
+	if !darkIn(100 - 47) {
+		t.Error("composite font text with ToUnicode was not drawn")
+	}
+	if darkIn(100 - 77) {
+		t.Error("invisible text (3 Tr) was drawn")
+	}
+}
+
+func TestRasterize_Errors(t *testing.T) {
+	data := buildPDF([]testPage{{}, {}, {}})
+	if _, err := Rasterize(data, Options{DPI: 72, MaxPages: 2}); !errors.Is(err, ErrTooManyPages) {
+		t.Errorf("expected ErrTooManyPages, got %v", err)
+	}
+	if _, err := Rasterize(data, Options{DPI: 72, MaxPagePixels: 100}); !errors.Is(err, ErrPageTooLarge) {
+		t.Errorf("expected ErrPageTooLarge, got %v", err)
+	}
+	if _, err := Rasterize([]byte("not a pdf"), DefaultOptions()); err == nil {
+		t.Error("expected error for invalid PDF")
+	}
+	if _, err := Rasterize(data, Options{}); err == nil {
+		t.Error("expected error for zero DPI")
+	}
+}
+
+func TestRasterize_OffPageCoordinates(t *testing.T) {
+	content := "0 G 4 w 0 50 m 1000000000000 50 l S\n" + // Far off the page, but crossing it
+		"0 g -1e30 0 m 1e30 0 l 1e30 20 l -1e30 20 l f\n" +
+		"1e400 0 m 10 10 l 0 10 l f"
+	pages, err := Rasterize(buildPDF([]testPage{{content: content}}), Options{DPI: 72})
+	if err != nil {
+		t.Fatalf("Rasterize() error = %v", err)
+	}
+	if got := rgbaAt(pages[0], 100, 50); got.R != 0 {
+		t.Errorf("pixel on the long line = %v, want black", got)
+	}
+	if got := rgbaAt(pages[0], 100, 90); got.R != 0 {
+		t.Errorf("pixel in the wide rect = %v, want black", got)
+	}
+	if got := rgbaAt(pages[0], 100, 30); got.R != 255 {
+		t.Errorf("pixel between them = %v, want white", got)
+	}
+}
+
+func FuzzRasterize(f *testing.F) {
+	for _, content := range []string{
+		"0 0 1 rg 10 10 50 30 re f",
+		"0 G 4 w 0 50 m 200 50 l S q 1 0 0 1 100 0 cm 1 0 0 rg 10 10 20 20 re f Q",
+		"0 0 m 1000000000000 5 l S",
+		"q 1e30 0 0 1e30 0 0 cm 0 0 1 1 re f Q",
+		"1e300 w 0 0 m 10 10 l S",
+		"0 0 m 10 10 20 0 30 10 c 40 0 v h B",
+		"BT /F1 12 Tf 10 10 Td (Room 101) Tj ET",
+		"BT 1e30 Tz 2 TL 0 0 Td [(a) -50 <62> 1e300] TJ T* (c) Tj 1 1 (d) \" ET",
+	} {
+		f.Add(content)
+	}
+	f.Fuzz(func(t *testing.T, content string) {
+		if _, err := Rasterize(buildPDF([]testPage{{content: content}}), Options{DPI: 72}); errors.Is(err, errCrashed) {
+			t.Fatalf("Rasterize(%q) = %v", content, err)
+		}
+	})
+}
+
+func TestIsPDF(t *testing.T) {
+	if !IsPDF(buildPDF([]testPage{{}})) {
+		t.Error("IsPDF(generated PDF) = false")
+	}
+	if IsPDF([]byte("\x89PNG\r\n")) {
+		t.Error("IsPDF(PNG header) = true")
+	}
+}
@@ -0,0 +1,634 @@
+package pdfraster
+
+import (
+	"image"
+	"image/color"
+	_ "image/jpeg" // Decode DCT images extracted by pdfcpu
+	_ "image/png"  // Decode Flate images extracted by pdfcpu
+	"math"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/f64"
+	_ "golang.org/x/image/tiff" // Decode CMYK images extracted by pdfcpu
+	"golang.org/x/image/vector"
+)
+
+// maxFormDepth bounds nested Form XObjects (and guards against cycles).
+const maxFormDepth = 8
+
+// curveSteps is the number of line segments a Bézier curve is flattened into.
+const curveSteps = 16
+
+// matrix is a PDF transformation matrix [a b c d e f], mapping
+// (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type matrix [6]float64
+
+var identity = matrix{1, 0, 0, 1, 0, 0}
+
+// then returns the transformation that applies m followed by n.
+func (m matrix) then(n matrix) matrix {
+	return matrix{
+		m[0]*n[0] + m[1]*n[2],
+		m[0]*n[1] + m[1]*n[3],
+		m[2]*n[0] + m[3]*n[2],
+		m[2]*n[1] + m[3]*n[3],
+		m[4]*n[0] + m[5]*n[2] + n[4],
+		m[4]*n[1] + m[5]*n[3] + n[5],
+	}
+}
+
+func (m matrix) apply(x, y float64) point {
+	return point{m[0]*x + m[2]*y + m[4], m[1]*x + m[3]*y + m[5]}
+}
+
+// scale is the factor by which m scales lengths, on average.
+func (m matrix) scale() float64 {
+	return math.Sqrt(math.Abs(m[0]*m[3] - m[1]*m[2]))
+}
+
+type point struct{ x, y float64 }
+
+// subpath is a flattened sequence of device-space points.
+type subpath struct {
+	points []point
+	closed bool
+}
+
+// graphicsState is the subset of the PDF graphics state the renderer honours.
+type graphicsState struct {
+	ctm       matrix
+	fill      color.RGBA
+	stroke    color.RGBA
+	lineWidth float64
+
+	// Text state
+	font       *pdfFont
+	fontSize   float64
+	charSpace  float64
+	wordSpace  float64
+	hScale     float64 // Horizontal scaling, 1 = 100%
+	leading    float64
+	rise       float64
+	textRender int // Text rendering mode
+}
+
+// renderer paints one page's content streams onto dst.
+type renderer struct {
+	ctx    *model.Context
+	dst    *image.RGBA
+	raster vector.Rasterizer
+	images map[int]image.Image // Decoded image XObjects by object number
+	fonts  map[int]*pdfFont    // Font dictionaries by object number
+	glyphs sfnt.Buffer
+
+	state   graphicsState
+	stack   []graphicsState
+	path    []subpath
+	tm, tlm matrix // Text matrix and text line matrix, inside BT ... ET
+}
+
+func newRenderer(ctx *model.Context, dst *image.RGBA, ctm matrix) *renderer {
+	black := color.RGBA{A: 255}
+	return &renderer{
+		ctx:    ctx,
+		dst:    dst,
+		images: make(map[int]image.Image),
+		fonts:  make(map[int]*pdfFont),
+		state:  graphicsState{ctm: ctm, fill: black, stroke: black, lineWidth: 1, hScale: 1},
+	}
+}
+
+// run interprets a decoded content stream. Shadings, clipping and
+// transparency are not rendered: floorplan walls are drawn with paths or
+// embedded as images. Text is drawn with a substitute font (see showText),
+// so room labels and dimensions reach room detection.
+func (r *renderer) run(content []byte, resources types.Dict, depth int) {
+	lex := &lexer{data: content}
+	var operands []interface{}
+
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			return
+		}
+		op, isOp := tok.(keyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+
+		nums := numbers(operands)
+		switch op {
+		case "q":
+			r.stack = append(r.stack, r.state)
+		case "Q":
+			if n := len(r.stack); n > 0 {
+				r.state = r.stack[n-1]
+				r.stack = r.stack[:n-1]
+			}
+		case "cm":
+			if len(nums) == 6 {
+				r.state.ctm = matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}.then(r.state.ctm)
+			}
+		case "w":
+			if len(nums) == 1 {
+				r.state.lineWidth = nums[0]
+			}
+
+		case "m":
+			if len(nums) == 2 {
+				r.path = append(r.path, subpath{points: []point{r.state.ctm.apply(nums[0], nums[1])}})
+			}
+		case "l":
+			if len(nums) == 2 {
+				r.lineTo(r.state.ctm.apply(nums[0], nums[1]))
+			}
+		case "c":
+			if len(nums) == 6 {
+				r.curveTo(r.state.ctm.apply(nums[0], nums[1]), r.state.ctm.apply(nums[2], nums[3]), r.state.ctm.apply(nums[4], nums[5]))
+			}
+		case "v":
+			if cur, ok := r.currentPoint(); ok && len(nums) == 4 {
+				r.curveTo(cur, r.state.ctm.apply(nums[0], nums[1]), r.state.ctm.apply(nums[2], nums[3]))
+			}
+		case "y":
+			if len(nums) == 4 {
+				end := r.state.ctm.apply(nums[2], nums[3])
+				r.curveTo(r.state.ctm.apply(nums[0], nums[1]), end, end)
+			}
+		case "h":
+			r.closePath()
+		case "re":
+			if len(nums) == 4 {
+				x, y, w, h := nums[0], nums[1], nums[2], nums[3]
+				m := r.state.ctm
+				r.path = append(r.path, subpath{
+					points: []point{m.apply(x, y), m.apply(x+w, y), m.apply(x+w, y+h), m.apply(x, y+h)},
+					closed: true,
+				})
+			}
+
+		case "f", "F", "f*":
+			r.fillPath()
+			r.path = nil
+		case "S":
+			r.strokePath()
+			r.path = nil
+		case "s":
+			r.closePath()
+			r.strokePath()
+			r.path = nil
+		case "B", "B*":
+			r.fillPath()
+			r.strokePath()
+			r.path = nil
+		case "b", "b*":
+			r.closePath()
+			r.fillPath()
+			r.strokePath()
+			r.path = nil
+		case "n":
+			r.path = nil
+
+		case "g", "rg", "k", "sc", "scn":
+			r.state.fill = colorFrom(nums, r.state.fill)
+		case "G", "RG", "K", "SC", "SCN":
+			r.state.stroke = colorFrom(nums, r.state.stroke)
+		case "cs":
+			r.state.fill = color.RGBA{A: 255}
+		case "CS":
+			r.state.stroke = color.RGBA{A: 255}
+
+		case "BT":
+			r.tm, r.tlm = identity, identity
+		case "Tf":
+			if len(operands) == 2 {
+				n, ok := operands[0].(name)
+				size, ok2 := operands[1].(float64)
+				if ok && ok2 {
+					r.state.font, r.state.fontSize = r.loadFont(resources, string(n)), size
+				}
+			}
+		case "Tc":
+			if len(nums) == 1 {
+				r.state.charSpace = nums[0]
+			}
+		case "Tw":
+			if len(nums) == 1 {
+				r.state.wordSpace = nums[0]
+			}
+		case "Tz":
+			if len(nums) == 1 {
+				r.state.hScale = nums[0] / 100
+			}
+		case "TL":
+			if len(nums) == 1 {
+				r.state.leading = nums[0]
+			}
+		case "Ts":
+			if len(nums) == 1 {
+				r.state.rise = nums[0]
+			}
+		case "Tr":
+			if len(nums) == 1 {
+				r.state.textRender = int(nums[0])
+			}
+		case "Td":
+			if len(nums) == 2 {
+				r.moveText(nums[0], nums[1])
+			}
+		case "TD":
+			if len(nums) == 2 {
+				r.state.leading = -nums[1]
+				r.moveText(nums[0], nums[1])
+			}
+		case "Tm":
+			if len(nums) == 6 {
+				r.tm = matrix{nums[0], nums[1], nums[2], nums[3], nums[4], nums[5]}
+				r.tlm = r.tm
+			}
+		case "T*":
+			r.moveText(0, -r.state.leading)
+		case "Tj", "'", "\"":
+			if op == "\"" && len(operands) == 3 {
+				if aw, ok := operands[0].(float64); ok {
+					r.state.wordSpace = aw
+				}
+				if ac, ok := operands[1].(float64); ok {
+					r.state.charSpace = ac
+				}
+			}
+			if op != "Tj" {
+				r.moveText(0, -r.state.leading)
+			}
+			if len(operands) > 0 {
+				if s, ok := operands[len(operands)-1].([]byte); ok {
+					r.showText(s)
+				}
+			}
+		case "TJ":
+			if len(operands) == 1 {
+				items, _ := operands[0].([]interface{})
+				for _, item := range items {
+					switch v := item.(type) {
+					case []byte:
+						r.showText(v)
+					case float64:
+						r.adjustText(v)
+					}
+				}
+			}
+
+		case "Do":
+			if len(operands) == 1 {
+				if n, ok := operands[0].(name); ok {
+					r.drawXObject(string(n), resources, depth)
+				}
+			}
+		case "BI":
+			lex.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+// numbers returns the numeric operands, or nil if any operand is not a number.
+func numbers(operands []interface{}) []float64 {
+	nums := make([]float64, 0, len(operands))
+	for _, o := range operands {
+		f, ok := o.(float64)
+		if !ok {
+			return nil
+		}
+		nums = append(nums, f)
+	}
+	return nums
+}
+
+// colorFrom interprets 1 (gray), 3 (RGB) or 4 (CMYK) components in [0, 1],
+// keeping fallback for other operand counts (e.g. pattern names).
+func colorFrom(c []float64, fallback color.RGBA) color.RGBA {
+	ch := func(v float64) uint8 {
+		return uint8(math.Round(math.Max(0, math.Min(1, v)) * 255))
+	}
+	switch len(c) {
+	case 1:
+		return color.RGBA{ch(c[0]), ch(c[0]), ch(c[0]), 255}
+	case 3:
+		return color.RGBA{ch(c[0]), ch(c[1]), ch(c[2]), 255}
+	case 4:
+		k := 1 - c[3]
+		return color.RGBA{ch((1 - c[0]) * k), ch((1 - c[1]) * k), ch((1 - c[2]) * k), 255}
+	}
+	return fallback
+}
+
+func (r *renderer) currentPoint() (point, bool) {
+	if len(r.path) == 0 {
+		return point{}, false
+	}
+	sp := r.path[len(r.path)-1]
+	if sp.closed {
+		return sp.points[0], true // Closing a subpath returns to its start
+	}
+	return sp.points[len(sp.points)-1], true
+}
+
+func (r *renderer) lineTo(p point) {
+	if len(r.path) == 0 || r.path[len(r.path)-1].closed {
+		start := p
+		if cur, ok := r.currentPoint(); ok {
+			start = cur
+		}
+		r.path = append(r.path, subpath{points: []point{start}})
+	}
+	sp := &r.path[len(r.path)-1]
+	sp.points = append(sp.points, p)
+}
+
+func (r *renderer) curveTo(c1, c2, end point) {
+	p0, ok := r.currentPoint()
+	if !ok {
+		p0 = c1
+	}
+	for i := 1; i <= curveSteps; i++ {
+		t := float64(i) / curveSteps
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		r.lineTo(point{
+			a*p0.x + b*c1.x + c*c2.x + d*end.x,
+			a*p0.y + b*c1.y + c*c2.y + d*end.y,
+		})
+	}
+}
+
+func (r *renderer) closePath() {
+	if len(r.path) > 0 {
+		r.path[len(r.path)-1].closed = true
+	}
+}
+
+// fillPath fills the current path with the non-zero winding rule
+// (even-odd fills are approximated the same way).
+func (r *renderer) fillPath() {
+	r.paint(r.state.fill, func(add func([]point)) {
+		for _, sp := range r.path {
+			if len(sp.points) > 2 {
+				add(sp.points)
+			}
+		}
+	})
+}
+
+// strokePath strokes every segment of the current path as a rectangle with
+// square caps, at least one device pixel wide.
+func (r *renderer) strokePath() {
+	half := math.Max(r.state.lineWidth*r.state.ctm.scale(), 1) / 2
+	r.paint(r.state.stroke, func(add func([]point)) {
+		for _, sp := range r.path {
+			pts := sp.points
+			if sp.closed && len(pts) > 1 {
+				pts = append(pts[:len(pts):len(pts)], pts[0])
+			}
+			if len(pts) == 1 {
+				pts = []point{pts[0], pts[0]}
+			}
+			for i := 1; i < len(pts); i++ {
+				add(segmentQuad(pts[i-1], pts[i], half))
+			}
+		}
+	})
+}
+
+// segmentQuad returns the outline of the segment a-b widened by half on each
+// side and extended by half at both ends.
+func segmentQuad(a, b point, half float64) []point {
+	dx, dy := b.x-a.x, b.y-a.y
+	length := math.Hypot(dx, dy)
+	if length == 0 {
+		dx, dy, length = 1, 0, 1
+	}
+	ux, uy := dx/length*half, dy/length*half // Along the segment
+	nx, ny := -uy, ux                        // Normal
+	a = point{a.x - ux, a.y - uy}
+	b = point{b.x + ux, b.y + uy}
+	return []point{
+		{a.x + nx, a.y + ny},
+		{b.x + nx, b.y + ny},
+		{b.x - nx, b.y - ny},
+		{a.x - nx, a.y - ny},
+	}
+}
+
+// paint rasterizes the polygons produced by build in col. The rasterizer is
+// sized to the polygons' bounding box so small shapes stay cheap.
+//
+// Polygons are first clipped to the page, with a pixel to spare: the
+// rasterizer works in fixed point and overflows on coordinates far outside
+// it, which a PDF can place anywhere.
+func (r *renderer) paint(col color.RGBA, build func(add func([]point))) {
+	page := r.dst.Bounds()
+	lo := point{float64(page.Min.X) - 1, float64(page.Min.Y) - 1}
+	hi := point{float64(page.Max.X) + 1, float64(page.Max.Y) + 1}
+	var polys [][]point
+	build(func(p []point) {
+		if p = clipPolygon(p, lo, hi); len(p) > 2 {
+			polys = append(polys, p)
+		}
+	})
+	if len(polys) == 0 {
+		return
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, poly := range polys {
+		for _, p := range poly {
+			minX, minY = math.Min(minX, p.x), math.Min(minY, p.y)
+			maxX, maxY = math.Max(maxX, p.x), math.Max(maxY, p.y)
+		}
+	}
+	box := image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(r.dst.Bounds())
+	if box.Empty() {
+		return
+	}
+
+	ox, oy := float64(box.Min.X), float64(box.Min.Y)
+	r.raster.Reset(box.Dx(), box.Dy())
+	for _, poly := range polys {
+		r.raster.MoveTo(float32(poly[0].x-ox), float32(poly[0].y-oy))
+		for _, p := range poly[1:] {
+			r.raster.LineTo(float32(p.x-ox), float32(p.y-oy))
+		}
+		r.raster.ClosePath()
+	}
+	r.raster.Draw(r.dst, box, image.NewUniform(col), image.Point{})
+}
+
+// clipPolygon clips poly to the rectangle from lo to hi (Sutherland-Hodgman),
+// leaving the area inside the rectangle unchanged. Polygons with non-finite
+// points are dropped.
+func clipPolygon(poly []point, lo, hi point) []point {
+	for _, p := range poly {
+		if !finite(p) {
+			return nil
+		}
+	}
+	atX := func(x float64) func(a, b point) point {
+		return func(a, b point) point { return point{x, a.y + (x-a.x)/(b.x-a.x)*(b.y-a.y)} }
+	}
+	atY := func(y float64) func(a, b point) point {
+		return func(a, b point) point { return point{a.x + (y-a.y)/(b.y-a.y)*(b.x-a.x), y} }
+	}
+	poly = clipEdge(poly, func(p point) bool { return p.x >= lo.x }, atX(lo.x))
+	poly = clipEdge(poly, func(p point) bool { return p.x <= hi.x }, atX(hi.x))
+	poly = clipEdge(poly, func(p point) bool { return p.y >= lo.y }, atY(lo.y))
+	poly = clipEdge(poly, func(p point) bool { return p.y <= hi.y }, atY(hi.y))
+	for _, p := range poly {
+		if !finite(p) { // Intersections of lines too long to represent
+			return nil
+		}
+	}
+	return poly
+}
+
+// clipEdge keeps the part of poly on the inside of one clipping edge; cross
+// returns where the segment from a to b crosses it.
+func clipEdge(poly []point, inside func(point) bool, cross func(a, b point) point) []point {
+	var out []point
+	for i, cur := range poly {
+		prev := poly[(i+len(poly)-1)%len(poly)]
+		switch {
+		case inside(cur):
+			if !inside(prev) {
+				out = append(out, cross(prev, cur))
+			}
+			out = append(out, cur)
+		case inside(prev):
+			out = append(out, cross(prev, cur))
+		}
+	}
+	return out
+}
+
+func finite(p point) bool {
+	return !math.IsNaN(p.x) && !math.IsNaN(p.y) && !math.IsInf(p.x, 0) && !math.IsInf(p.y, 0)
+}
+
+// drawXObject paints the named image or form XObject from resources.
+func (r *renderer) drawXObject(resName string, resources types.Dict, depth int) {
+	xobjects := r.dict(resources, "XObject")
+	if xobjects == nil {
+		return
+	}
+	entry, ok := xobjects.Find(resName)
+	if !ok {
+		return
+	}
+	objNr := 0
+	if ref, ok := entry.(types.IndirectRef); ok {
+		objNr = ref.ObjectNumber.Value()
+	}
+	sd, _, err := r.ctx.DereferenceStreamDict(entry)
+	if err != nil || sd == nil {
+		return
+	}
+
+	switch sub := sd.Subtype(); {
+	case sub != nil && *sub == "Image":
+		if img := r.decodeImage(sd, resName, objNr); img != nil {
+			r.drawImage(img)
+		}
+
+	case sub != nil && *sub == "Form":
+		if depth >= maxFormDepth || sd.Decode() != nil {
+			return
+		}
+		formResources := r.dict(sd.Dict, "Resources")
+		if formResources == nil {
+			formResources = resources
+		}
+
+		saved, savedStack, savedPath := r.state, r.stack, r.path
+		r.stack, r.path = nil, nil
+		if m := r.numberArray(sd.Dict, "Matrix"); len(m) == 6 {
+			r.state.ctm = matrix{m[0], m[1], m[2], m[3], m[4], m[5]}.then(r.state.ctm)
+		}
+		r.run(sd.Content, formResources, depth+1)
+		r.state, r.stack, r.path = saved, savedStack, savedPath
+	}
+}
+
+// decodeImage extracts and decodes an image XObject, caching by object number.
+func (r *renderer) decodeImage(sd *types.StreamDict, resName string, objNr int) image.Image {
+	if img, ok := r.images[objNr]; ok && objNr != 0 {
+		return img
+	}
+
+	var img image.Image
+	extracted, err := pdfcpu.ExtractImage(r.ctx, sd, false, resName, objNr, false)
+	if err == nil && extracted != nil && extracted.Reader != nil {
+		img, _, _ = image.Decode(extracted.Reader) // Unsupported formats (e.g. JPX) are skipped
+	}
+	if objNr != 0 {
+		r.images[objNr] = img
+	}
+	return img
+}
+
+// drawImage paints img into the unit square of the current user space.
+func (r *renderer) drawImage(img image.Image) {
+	b := img.Bounds()
+	if b.Empty() {
+		return
+	}
+	w, h := float64(b.Dx()), float64(b.Dy())
+	m := r.state.ctm
+
+	// Image pixel (px, py) maps to unit square (px/w, 1-py/h), then through the CTM.
+	s2d := f64.Aff3{
+		m[0] / w, -m[2] / h, m[2] + m[4] - m[0]*float64(b.Min.X)/w + m[2]*float64(b.Min.Y)/h,
+		m[1] / w, -m[3] / h, m[3] + m[5] - m[1]*float64(b.Min.X)/w + m[3]*float64(b.Min.Y)/h,
+	}
+	draw.ApproxBiLinear.Transform(r.dst, s2d, img, b, draw.Over, nil)
+}
+
+func (r *renderer) dict(d types.Dict, key string) types.Dict {
+	if d == nil {
+		return nil
+	}
+	entry, ok := d.Find(key)
+	if !ok {
+		return nil
+	}
+	resolved, err := r.ctx.DereferenceDict(entry)
+	if err != nil {
+		return nil
+	}
+	return resolved
+}
+
+func (r *renderer) numberArray(d types.Dict, key string) []float64 {
+	entry, ok := d.Find(key)
+	if !ok {
+		return nil
+	}
+	arr, err := r.ctx.DereferenceArray(entry)
+	if err != nil {
+		return nil
+	}
+	nums := make([]float64, 0, len(arr))
+	for _, o := range arr {
+		f, err := r.ctx.DereferenceNumber(o)
+		if err != nil {
+			return nil
+		}
+		nums = append(nums, f)
+	}
+	return nums
+}
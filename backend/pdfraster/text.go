@@ -0,0 +1,353 @@
+package pdfraster
+
+import (
+	"sync"
+	"unicode/utf16"
+
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// glyphUnits is the size of the em in PDF glyph space: font widths and
+// TJ adjustments are in thousandths of the font size.
+const glyphUnits = 1000
+
+// glyphCurveSteps is the number of line segments a glyph's curves are
+// flattened into; glyphs are small, so fewer than paths need.
+const glyphCurveSteps = 4
+
+// substituteFont is the font every text run is drawn with. The document's own
+// fonts are not rendered; Go Regular, stretched to the widths the document
+// gives, keeps labels and dimensions legible at their positions.
+var substituteFont = sync.OnceValue(func() *sfnt.Font {
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		panic("pdfraster: cannot parse the substitute font: " + err.Error())
+	}
+	return f
+})
+
+// pdfFont is what the renderer needs from a font dictionary: how strings
+// split into character codes, what the codes read as and how far each one
+// advances.
+type pdfFont struct {
+	codeBytes    int                // 2 for composite (Type0) fonts, else 1
+	toUnicode    map[uint32]string  // From the ToUnicode CMap, if any
+	widths       map[uint32]float64 // In glyph space units
+	defaultWidth float64            // For codes without a width; 0 uses the substitute font's
+}
+
+// text returns what code reads as. Without a ToUnicode CMap, codes of simple
+// fonts are taken as Latin-1, which covers the ASCII labels of most
+// drawings; codes of composite fonts are glyph IDs and cannot be read.
+func (f *pdfFont) text(code uint32) string {
+	if s, ok := f.toUnicode[code]; ok {
+		return s
+	}
+	if f.codeBytes == 1 && f.toUnicode == nil {
+		return string(rune(code))
+	}
+	return ""
+}
+
+// loadFont reads the named font from resources, caching by object number.
+// It returns nil when the font cannot be found.
+func (r *renderer) loadFont(resources types.Dict, resName string) *pdfFont {
+	fonts := r.dict(resources, "Font")
+	if fonts == nil {
+		return nil
+	}
+	entry, ok := fonts.Find(resName)
+	if !ok {
+		return nil
+	}
+	objNr := 0
+	if ref, ok := entry.(types.IndirectRef); ok {
+		objNr = ref.ObjectNumber.Value()
+		if f, ok := r.fonts[objNr]; ok {
+			return f
+		}
+	}
+	fd, err := r.ctx.DereferenceDict(entry)
+	if err != nil || fd == nil {
+		return nil
+	}
+
+	f := &pdfFont{codeBytes: 1, widths: make(map[uint32]float64)}
+	if sub := fd.Subtype(); sub != nil && *sub == "Type0" {
+		f.codeBytes = 2
+		f.defaultWidth = glyphUnits
+		descendants, _ := r.ctx.DereferenceArray(fd["DescendantFonts"])
+		if len(descendants) > 0 {
+			if cid, err := r.ctx.DereferenceDict(descendants[0]); err == nil && cid != nil {
+				if dw, err := r.ctx.DereferenceNumber(cid["DW"]); err == nil {
+					f.defaultWidth = dw
+				}
+				w, _ := r.ctx.DereferenceArray(cid["W"])
+				r.cidWidths(w, f.widths)
+			}
+		}
+	} else if first, err := r.ctx.DereferenceNumber(fd["FirstChar"]); err == nil && first >= 0 && first < 256 {
+		for i, w := range r.numberArray(fd, "Widths") {
+			f.widths[uint32(first)+uint32(i)] = w
+		}
+	}
+
+	if entry, ok := fd.Find("ToUnicode"); ok {
+		if sd, _, err := r.ctx.DereferenceStreamDict(entry); err == nil && sd != nil && sd.Decode() == nil {
+			f.toUnicode = parseToUnicode(sd.Content)
+		}
+	}
+
+	if objNr != 0 {
+		r.fonts[objNr] = f
+	}
+	return f
+}
+
+// cidWidths reads a CIDFont W array: "c [w1 w2 ...]" gives consecutive
+// codes from c their widths, "cfirst clast w" gives a range one width.
+func (r *renderer) cidWidths(w types.Array, widths map[uint32]float64) {
+	const maxCode = 0xffff
+	for i := 0; i+1 < len(w); {
+		first, err := r.ctx.DereferenceNumber(w[i])
+		if err != nil || first < 0 || first > maxCode {
+			return
+		}
+		if list, err := r.ctx.DereferenceArray(w[i+1]); err == nil {
+			for j, o := range list {
+				if v, err := r.ctx.DereferenceNumber(o); err == nil {
+					widths[uint32(first)+uint32(j)] = v
+				}
+			}
+			i += 2
+			continue
+		}
+		if i+2 >= len(w) {
+			return
+		}
+		last, err := r.ctx.DereferenceNumber(w[i+1])
+		if err != nil || last < first || last > maxCode {
+			return
+		}
+		v, err := r.ctx.DereferenceNumber(w[i+2])
+		if err != nil {
+			return
+		}
+		for c := uint32(first); c <= uint32(last); c++ {
+			widths[c] = v
+		}
+		i += 3
+	}
+}
+
+// parseToUnicode reads the bfchar and bfrange mappings of a ToUnicode CMap.
+func parseToUnicode(data []byte) map[uint32]string {
+	m := make(map[uint32]string)
+	lex := &lexer{data: data}
+	var operands []interface{}
+	for {
+		tok, ok := lex.next()
+		if !ok {
+			return m
+		}
+		op, isOp := tok.(keyword)
+		if !isOp {
+			operands = append(operands, tok)
+			continue
+		}
+
+		switch op {
+		case "endbfchar":
+			for i := 0; i+1 < len(operands); i += 2 {
+				src, ok := operands[i].([]byte)
+				dst, ok2 := operands[i+1].([]byte)
+				if ok && ok2 {
+					m[charCode(src)] = utf16String(dst)
+				}
+			}
+		case "endbfrange":
+			for i := 0; i+2 < len(operands); i += 3 {
+				lo, ok := operands[i].([]byte)
+				hi, ok2 := operands[i+1].([]byte)
+				if !ok || !ok2 {
+					continue
+				}
+				first, last := charCode(lo), charCode(hi)
+				if last < first || last-first > 0xffff {
+					continue
+				}
+				switch dst := operands[i+2].(type) {
+				case []byte:
+					// Successive codes map to successive last UTF-16 units.
+					units := utf16Units(dst)
+					for c := first; c <= last && len(units) > 0; c++ {
+						m[c] = string(utf16.Decode(units))
+						units[len(units)-1]++
+					}
+				case []interface{}:
+					for j, d := range dst {
+						if b, ok := d.([]byte); ok && first+uint32(j) <= last {
+							m[first+uint32(j)] = utf16String(b)
+						}
+					}
+				}
+			}
+		}
+		operands = operands[:0]
+	}
+}
+
+// charCode reads a big-endian character code of up to four bytes.
+func charCode(b []byte) uint32 {
+	var code uint32
+	for i := 0; i < len(b) && i < 4; i++ {
+		code = code<<8 | uint32(b[i])
+	}
+	return code
+}
+
+func utf16Units(b []byte) []uint16 {
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+	}
+	return units
+}
+
+func utf16String(b []byte) string {
+	return string(utf16.Decode(utf16Units(b)))
+}
+
+// moveText starts a new line offset by (tx, ty) from the current one.
+func (r *renderer) moveText(tx, ty float64) {
+	r.tlm = matrix{1, 0, 0, 1, tx, ty}.then(r.tlm)
+	r.tm = r.tlm
+}
+
+// showText draws a string in the current font and advances the text matrix
+// past it. Each character is drawn with the substitute font, stretched to
+// the width the document's font gives it.
+func (r *renderer) showText(s []byte) {
+	st := &r.state
+	f := st.font
+	if f == nil {
+		f = &pdfFont{codeBytes: 1}
+	}
+	sub := substituteFont()
+	visible := st.textRender != 3 && st.textRender != 7 // Invisible, or clipping only
+
+	var polys [][]point
+	for i := 0; i+f.codeBytes <= len(s); i += f.codeBytes {
+		code := charCode(s[i : i+f.codeBytes])
+
+		type glyph struct {
+			index   sfnt.GlyphIndex
+			advance float64
+		}
+		var glyphs []glyph
+		var natural float64
+		for _, ch := range f.text(code) {
+			index, err := sub.GlyphIndex(&r.glyphs, ch)
+			if err != nil || index == 0 {
+				continue
+			}
+			advance, err := sub.GlyphAdvance(&r.glyphs, index, fixed.I(glyphUnits), font.HintingNone)
+			if err != nil {
+				continue
+			}
+			glyphs = append(glyphs, glyph{index, float64(advance) / 64})
+			natural += float64(advance) / 64
+		}
+
+		width, ok := f.widths[code]
+		if !ok {
+			width = f.defaultWidth
+		}
+		if width == 0 {
+			width = natural
+		}
+
+		if visible && natural > 0 {
+			trm := matrix{st.fontSize * st.hScale, 0, 0, st.fontSize, 0, st.rise}.then(r.tm).then(st.ctm)
+			stretch, x := width/natural, 0.0
+			for _, g := range glyphs {
+				polys = append(polys, r.glyphOutline(g.index, trm, x, stretch)...)
+				x += g.advance * stretch
+			}
+		}
+
+		tx := width/glyphUnits*st.fontSize + st.charSpace
+		if f.codeBytes == 1 && code == ' ' {
+			tx += st.wordSpace
+		}
+		r.tm = matrix{1, 0, 0, 1, tx * st.hScale, 0}.then(r.tm)
+	}
+
+	if len(polys) > 0 {
+		r.paint(st.fill, func(add func([]point)) {
+			for _, p := range polys {
+				add(p)
+			}
+		})
+	}
+}
+
+// adjustText applies a TJ adjustment, in thousandths of the font size.
+func (r *renderer) adjustText(n float64) {
+	tx := -n / glyphUnits * r.state.fontSize * r.state.hScale
+	r.tm = matrix{1, 0, 0, 1, tx, 0}.then(r.tm)
+}
+
+// glyphOutline returns the device-space contours of a substitute font glyph
+// drawn through the text rendering matrix trm, x glyph space units along the
+// baseline and stretched horizontally by stretch.
+func (r *renderer) glyphOutline(index sfnt.GlyphIndex, trm matrix, x, stretch float64) [][]point {
+	segments, err := substituteFont().LoadGlyph(&r.glyphs, index, fixed.I(glyphUnits), nil)
+	if err != nil {
+		return nil
+	}
+	// sfnt's y axis points down, glyph space's up.
+	at := func(p fixed.Point26_6) point {
+		return trm.apply((x+float64(p.X)/64*stretch)/glyphUnits, -float64(p.Y)/64/glyphUnits)
+	}
+
+	var contours [][]point
+	var cur []point
+	for _, seg := range segments {
+		if seg.Op != sfnt.SegmentOpMoveTo && len(cur) == 0 {
+			continue
+		}
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			if len(cur) > 2 {
+				contours = append(contours, cur)
+			}
+			cur = []point{at(seg.Args[0])}
+		case sfnt.SegmentOpLineTo:
+			cur = append(cur, at(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			p0, c, end := cur[len(cur)-1], at(seg.Args[0]), at(seg.Args[1])
+			for i := 1; i <= glyphCurveSteps; i++ {
+				t := float64(i) / glyphCurveSteps
+				u := 1 - t
+				cur = append(cur, point{u*u*p0.x + 2*u*t*c.x + t*t*end.x, u*u*p0.y + 2*u*t*c.y + t*t*end.y})
+			}
+		case sfnt.SegmentOpCubeTo:
+			p0, c1, c2, end := cur[len(cur)-1], at(seg.Args[0]), at(seg.Args[1]), at(seg.Args[2])
+			for i := 1; i <= glyphCurveSteps; i++ {
+				t := float64(i) / glyphCurveSteps
+				u := 1 - t
+				a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+				cur = append(cur, point{a*p0.x + b*c1.x + c*c2.x + d*end.x, a*p0.y + b*c1.y + c*c2.y + d*end.y})
+			}
+		}
+	}
+	if len(cur) > 2 {
+		contours = append(contours, cur)
+	}
+	return contours
+}
@@ -0,0 +1,52 @@
+package realtime
+
+import (
+	"context"
+	"sync"
+)
+
+// Broker carries hub messages between every hub sharing it, so clients
+// connected to one backend replica see updates published on another. It
+// numbers messages: every subscriber receives them in the same order, with
+// increasing sequence numbers.
+type Broker interface {
+	// Publish sends data to every subscriber, including the publishing hub.
+	Publish(ctx context.Context, data []byte) error
+	// Subscribe registers handler for every message published from now on.
+	// Calls to handler are sequential.
+	Subscribe(handler func(seq uint64, data []byte)) error
+	Close() error
+}
+
+// MemoryBroker is the in-process Broker of a single replica. Publish calls
+// the subscribers before returning.
+type MemoryBroker struct {
+	seq      uint64
+	handlers []func(uint64, []byte)
+	mutex    sync.Mutex
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.seq++
+	for _, handler := range b.handlers {
+		handler(b.seq, data)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(handler func(uint64, []byte)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.handlers = append(b.handlers, handler)
+	return nil
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,145 @@
+package realtime
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"floorplan-whiteboard/config"
+
+	"github.com/gorilla/websocket"
+)
+
+// maxClientMessageSize bounds messages read from clients; they only send
+// small subscription requests.
+const maxClientMessageSize = 4096
+
+// Client is one subscriber: a websocket connection, or a Subscription for
+// other transports. Messages are queued on send and written by a single
+// goroutine, writePump for websockets.
+type Client struct {
+	hub           *Hub
+	conn          *websocket.Conn // Nil for Subscriptions
+	addr          string          // Remote address, for logging
+	send          chan []byte
+	subscriptions map[string]bool // Floorplan IDs; guarded by hub.mutex
+	jobs          map[string]bool // Job IDs; guarded by hub.mutex
+
+	done      chan struct{} // Closed when the client is shut down
+	closeOnce sync.Once
+}
+
+func newClient(h *Hub, conn *websocket.Conn, addr string) *Client {
+	return &Client{
+		hub:           h,
+		conn:          conn,
+		addr:          addr,
+		send:          make(chan []byte, h.cfg.SendQueueSize),
+		subscriptions: make(map[string]bool),
+		jobs:          make(map[string]bool),
+		done:          make(chan struct{}),
+	}
+}
+
+// enqueue queues data without blocking. When the queue is full the slow
+// client policy applies: the message is dropped, or the client is
+// disconnected.
+func (cl *Client) enqueue(data []byte) {
+	select {
+	case <-cl.done:
+		return
+	default:
+	}
+
+	select {
+	case cl.send <- data:
+	default:
+		if cl.hub.cfg.SlowClientPolicy == config.SlowClientDrop {
+			log.Printf("Realtime client %s is too slow, dropping message", cl.addr)
+			return
+		}
+		log.Printf("Realtime client %s is too slow, disconnecting", cl.addr)
+		cl.close()
+	}
+}
+
+// reply queues a response to a message from this client.
+func (cl *Client) reply(msg interface{}) {
+	if data, err := encode(msg); err == nil {
+		cl.enqueue(data)
+	}
+}
+
+// close shuts the connection, which also unblocks a pending write. It does
+// not take the hub's lock, so it is safe to call while publishing; the
+// client's writer unregisters it once it stops.
+func (cl *Client) close() {
+	cl.closeOnce.Do(func() {
+		close(cl.done)
+		if cl.conn != nil {
+			cl.conn.Close()
+		}
+	})
+}
+
+// goAway tells a websocket peer the server is going away, then closes the
+// client. The close frame is sent alongside writePump, which gorilla allows
+// for control messages.
+func (cl *Client) goAway() {
+	if cl.conn != nil {
+		msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down")
+		cl.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(cl.hub.cfg.WriteTimeout))
+	}
+	cl.close()
+}
+
+// writePump writes queued messages and keepalive pings until the client is
+// closed or a write fails. Every write has a deadline, so a peer that stops
+// reading holds up only its own goroutine.
+func (cl *Client) writePump() {
+	ticker := time.NewTicker(cl.hub.cfg.PingInterval)
+	defer func() {
+		ticker.Stop()
+		cl.close()
+		cl.hub.unregister(cl)
+	}()
+
+	for {
+		select {
+		case data := <-cl.send:
+			cl.conn.SetWriteDeadline(time.Now().Add(cl.hub.cfg.WriteTimeout))
+			if err := cl.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			cl.conn.SetWriteDeadline(time.Now().Add(cl.hub.cfg.WriteTimeout))
+			if err := cl.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-cl.done:
+			return
+		}
+	}
+}
+
+// readPump handles client requests until the connection fails or the peer
+// misses the pong deadline.
+func (cl *Client) readPump() {
+	defer cl.close()
+
+	cl.conn.SetReadLimit(maxClientMessageSize)
+	cl.conn.SetReadDeadline(time.Now().Add(cl.hub.cfg.PongTimeout))
+	cl.conn.SetPongHandler(func(string) error {
+		return cl.conn.SetReadDeadline(time.Now().Add(cl.hub.cfg.PongTimeout))
+	})
+
+	for {
+		_, data, err := cl.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		// Any traffic shows the client is alive.
+		cl.conn.SetReadDeadline(time.Now().Add(cl.hub.cfg.PongTimeout))
+		cl.hub.handleClientMessage(cl, data)
+	}
+}
@@ -1,88 +1,555 @@
 package realtime
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/models"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
+// Message types pushed to websocket clients.
+const (
+	MessageUpdate       = "update"
+	MessageJobProgress  = "job_progress"
+	MessageStatusUpdate = "status_update"
+	MessageSnapshot     = "snapshot"
+	MessageSubscribed   = "subscribed"
+	MessageUnsubscribed = "unsubscribed"
+	MessageError        = "error"
+)
+
+// Message types sent by websocket clients.
+const (
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+)
+
+// Message is the envelope of broadcasts and job progress messages.
+type Message struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// ClientMessage is a request sent by a websocket client, e.g.
+// {"type": "subscribe", "floorplan_id": "...", "last_seq": 42}, or
+// {"type": "subscribe", "job_id": "..."} for an analysis job's progress.
+type ClientMessage struct {
+	Type        string  `json:"type"`
+	FloorplanID string  `json:"floorplan_id,omitempty"`
+	JobID       string  `json:"job_id,omitempty"`
+	LastSeq     *uint64 `json:"last_seq,omitempty"` // Subscribe only: resume after this status update instead of a snapshot
+}
+
+// SubscriptionMessage acknowledges a subscribe or unsubscribe request, or
+// reports why it failed.
+type SubscriptionMessage struct {
+	Type        string `json:"type"`
+	FloorplanID string `json:"floorplan_id,omitempty"`
+	JobID       string `json:"job_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// StatusUpdate reports a room's new status. It is only delivered to clients
+// subscribed to FloorplanID.
+type StatusUpdate struct {
+	Type        string            `json:"type"` // Always MessageStatusUpdate
+	Seq         uint64            `json:"seq"`  // Increases by one with every status update the hub publishes
+	FloorplanID string            `json:"floorplan_id"`
+	RoomID      string            `json:"room_id"`
+	Status      models.RoomStatus `json:"status"`
+	Occupancy   int               `json:"occupancy"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// RoomStatus is one room's entry in a Snapshot.
+type RoomStatus struct {
+	RoomID    string            `json:"room_id"`
+	Status    models.RoomStatus `json:"status"`
+	Occupancy int               `json:"occupancy"`
+}
+
+// Snapshot carries the current status of every room of a floorplan. It is
+// sent after subscribing; the status updates that follow have a higher Seq.
+type Snapshot struct {
+	Type        string       `json:"type"` // Always MessageSnapshot
+	Seq         uint64       `json:"seq"`
+	FloorplanID string       `json:"floorplan_id"`
+	Rooms       []RoomStatus `json:"rooms"`
+}
+
+// FloorplanSource loads floorplans for snapshots; store.FloorplanRepository
+// implements it.
+type FloorplanSource interface {
+	Get(ctx context.Context, id string) (*models.Floorplan, error)
+}
+
+const (
+	// snapshotTimeout bounds loading a floorplan for a snapshot.
+	snapshotTimeout = 5 * time.Second
+	// snapshotAttempts bounds retaking a snapshot that updates outran.
+	snapshotAttempts = 3
+	// publishTimeout bounds handing a message to the broker.
+	publishTimeout = 5 * time.Second
+)
+
+// event is what hubs exchange through their Broker: a status update, or a
+// message already encoded for every client, or only for the clients
+// subscribed to JobID.
+type event struct {
+	Status    *StatusUpdate   `json:"status,omitempty"`
+	Broadcast json.RawMessage `json:"broadcast,omitempty"`
+	JobID     string          `json:"job_id,omitempty"`
 }
 
+// Hub fans messages out to connected websocket clients. Each client has its
+// own bounded send queue drained by a writer goroutine, so a slow or stalled
+// client never blocks publishers or other clients.
+//
+// Messages go through a Broker, which numbers them and delivers them to every
+// hub sharing it. The most recent status updates are kept in a log, so a
+// reconnecting client can resume where it left off, on any replica.
 type Hub struct {
-	clients   map[*websocket.Conn]bool
-	broadcast chan []byte
-	mutex     sync.Mutex
+	// AllowOrigin, when set, lets browsers on other origins open websockets
+	// if it returns true for their Origin header. Same-origin requests and
+	// clients that send no Origin are always accepted. Set it before serving.
+	AllowOrigin func(origin string) bool
+
+	cfg        config.RealtimeConfig
+	floorplans FloorplanSource
+	broker     Broker
+	clients    map[*Client]bool
+	closed     bool           // Set by Close; new clients are turned away
+	done       chan struct{}  // Closed by Close, to stop Run
+	seq        uint64         // Seq of the last message delivered by the broker
+	log        []StatusUpdate // Ring of recent messages, indexed by (Seq-1) % len; broadcasts leave only their Seq
+	mutex      sync.RWMutex
 }
 
-func NewHub() *Hub {
-	return &Hub{
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan []byte),
+// NewHub creates a hub and subscribes it to broker, or to a new MemoryBroker
+// when broker is nil. floorplans provides subscription snapshots; when nil,
+// subscribers only receive updates.
+func NewHub(cfg config.RealtimeConfig, floorplans FloorplanSource, broker Broker) (*Hub, error) {
+	if broker == nil {
+		broker = NewMemoryBroker()
+	}
+	h := &Hub{
+		cfg:        cfg,
+		floorplans: floorplans,
+		broker:     broker,
+		clients:    make(map[*Client]bool),
+		done:       make(chan struct{}),
+		log:        make([]StatusUpdate, cfg.ReplayLogSize),
+	}
+	if err := broker.Subscribe(h.deliver); err != nil {
+		return nil, err
 	}
+	return h, nil
 }
 
+// Run performs the hub's background work: the development simulation when
+// enabled, until Close. It returns immediately otherwise.
 func (h *Hub) Run() {
-	// Simulate data loop
-	go func() {
-		for {
-			time.Sleep(2 * time.Second)
+	if !h.cfg.Simulate {
+		return
+	}
+	ticker := time.NewTicker(h.cfg.SimulationInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
 			h.broadcastSimulation()
+		case <-h.done:
+			return
 		}
-	}()
+	}
+}
 
-	for {
-		message := <-h.broadcast
-		h.mutex.Lock()
-		for client := range h.clients {
-			err := client.WriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				log.Printf("Websocket error: %v", err)
-				client.Close()
-				delete(h.clients, client)
+// Close disconnects every client and turns away new ones: websockets get a
+// going-away close frame, and Subscriptions report Done. The server does not
+// track hijacked websocket connections, so call Close before shutting it
+// down. The broker is left open.
+func (h *Hub) Close() {
+	h.mutex.Lock()
+	if h.closed {
+		h.mutex.Unlock()
+		return
+	}
+	h.closed = true
+	close(h.done)
+	clients := make([]*Client, 0, len(h.clients))
+	for cl := range h.clients {
+		clients = append(clients, cl)
+	}
+	h.mutex.Unlock()
+
+	// Each close frame may wait out a stalled client's write timeout, so
+	// send them in parallel.
+	var wg sync.WaitGroup
+	for _, cl := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cl.goAway()
+		}()
+	}
+	wg.Wait()
+}
+
+// Broadcast sends a message of the given type to every connected client.
+func (h *Hub) Broadcast(msgType string, data interface{}) {
+	encoded, err := encode(Message{Type: msgType, Data: data})
+	if err != nil {
+		return
+	}
+	h.publish(event{Broadcast: encoded})
+}
+
+// PublishJobProgress sends a job_progress message with data to the clients
+// subscribed to jobID.
+func (h *Hub) PublishJobProgress(jobID string, data interface{}) {
+	encoded, err := encode(Message{Type: MessageJobProgress, Data: data})
+	if err != nil {
+		return
+	}
+	h.publish(event{Broadcast: encoded, JobID: jobID})
+}
+
+// PublishStatus sends update to the clients subscribed to its floorplan. The
+// broker assigns its Seq; Type and a zero Timestamp are filled in.
+func (h *Hub) PublishStatus(update StatusUpdate) {
+	update.Type = MessageStatusUpdate
+	if update.Timestamp.IsZero() {
+		update.Timestamp = time.Now().UTC()
+	}
+	h.publish(event{Status: &update})
+}
+
+func (h *Hub) publish(ev event) {
+	data, err := encode(ev)
+	if err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+	if err := h.broker.Publish(ctx, data); err != nil {
+		log.Printf("Failed to publish realtime message: %v", err)
+	}
+}
+
+// deliver handles a message from the broker: it records status updates in
+// the replay log and queues the message for its clients. Queueing under the
+// lock keeps every client's queue in Seq order.
+func (h *Hub) deliver(seq uint64, data []byte) {
+	var ev event
+	if err := json.Unmarshal(data, &ev); err != nil {
+		log.Printf("Dropping malformed realtime message %d: %v", seq, err)
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.seq = seq
+	slot := &h.log[(seq-1)%uint64(len(h.log))]
+
+	if ev.Status == nil {
+		*slot = StatusUpdate{Seq: seq}
+		for cl := range h.clients {
+			if ev.JobID == "" || cl.jobs[ev.JobID] {
+				cl.enqueue(ev.Broadcast)
 			}
 		}
-		h.mutex.Unlock()
+		return
+	}
+
+	update := *ev.Status
+	update.Seq = seq
+	*slot = update
+	encoded, err := encode(update)
+	if err != nil {
+		return
+	}
+	for cl := range h.clients {
+		if cl.subscriptions[update.FloorplanID] {
+			cl.enqueue(encoded)
+		}
+	}
+}
+
+// updatesSince returns the logged status updates for floorplanID with a Seq
+// above after. ok is false when the log no longer holds all of them, e.g.
+// after is from before a restart or this hub missed messages. The caller must
+// hold the mutex.
+func (h *Hub) updatesSince(floorplanID string, after uint64) (updates []StatusUpdate, ok bool) {
+	size := uint64(len(h.log))
+	if after > h.seq || h.seq-after > size {
+		return nil, false
+	}
+	for seq := after + 1; seq <= h.seq; seq++ {
+		u := h.log[(seq-1)%size]
+		if u.Seq != seq {
+			return nil, false
+		}
+		if u.FloorplanID == floorplanID {
+			updates = append(updates, u)
+		}
+	}
+	return updates, true
+}
+
+func encode(msg interface{}) ([]byte, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to encode websocket message: %v", err)
+	}
+	return data, err
+}
+
+// checkOrigin is the websocket upgrader's origin check (see AllowOrigin).
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
 	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return h.AllowOrigin != nil && h.AllowOrigin(origin)
 }
 
+// HandleWebSocket serves a websocket connection. The optional floorplan_id
+// and last_seq, or job_id, query parameters subscribe on connect, as if the
+// client had sent a subscribe message.
 func (h *Hub) HandleWebSocket(c *gin.Context) {
+	upgrader := websocket.Upgrader{CheckOrigin: h.checkOrigin}
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Println("Failed to upgrade:", err)
 		return
 	}
 
+	cl := h.register(conn, conn.RemoteAddr().String())
+	go cl.writePump()
+	if floorplanID := c.Query("floorplan_id"); floorplanID != "" {
+		msg := ClientMessage{Type: MessageSubscribe, FloorplanID: floorplanID}
+		if raw := c.Query("last_seq"); raw != "" {
+			seq, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				cl.reply(SubscriptionMessage{Type: MessageError, FloorplanID: floorplanID, Error: "invalid last_seq " + raw})
+			} else {
+				msg.LastSeq = &seq
+			}
+		}
+		h.handleSubscribe(cl, msg)
+	}
+	if jobID := c.Query("job_id"); jobID != "" {
+		h.handleSubscribe(cl, ClientMessage{Type: MessageSubscribe, JobID: jobID})
+	}
+	cl.readPump()
+}
+
+// Subscription receives the messages a websocket client subscribed to one
+// floorplan would: broadcasts, and that floorplan's snapshot and status
+// updates. It serves transports other than the websocket, such as
+// server-sent events.
+type Subscription struct {
+	client *Client
+}
+
+// Subscribe subscribes to floorplanID, resuming after lastSeq when it is not
+// nil, exactly like a websocket subscribe message. The caller must Close the
+// subscription. Errors come from the FloorplanSource, e.g. store.ErrNotFound.
+func (h *Hub) Subscribe(floorplanID string, lastSeq *uint64, addr string) (*Subscription, error) {
+	cl := h.register(nil, addr)
+	if err := h.subscribe(cl, floorplanID, lastSeq); err != nil {
+		cl.close()
+		h.unregister(cl)
+		return nil, err
+	}
+	return &Subscription{client: cl}, nil
+}
+
+// Messages delivers the encoded JSON messages, in order.
+func (s *Subscription) Messages() <-chan []byte {
+	return s.client.send
+}
+
+// Done is closed when the hub drops the subscriber under the disconnect slow
+// client policy or is itself closed, or after Close.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.client.done
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.client.close()
+	s.client.hub.unregister(s.client)
+}
+
+func (h *Hub) register(conn *websocket.Conn, addr string) *Client {
+	cl := newClient(h, conn, addr)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		cl.close()
+		return cl
+	}
+	h.clients[cl] = true
+	return cl
+}
+
+// unregister forgets a client; called once when its connection ends.
+func (h *Hub) unregister(cl *Client) {
 	h.mutex.Lock()
-	h.clients[conn] = true
+	delete(h.clients, cl)
 	h.mutex.Unlock()
+}
+
+// clientCount reports the number of connected clients.
+func (h *Hub) clientCount() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return len(h.clients)
+}
+
+// handleClientMessage applies a subscribe or unsubscribe request and
+// acknowledges it to the sender.
+func (h *Hub) handleClientMessage(cl *Client, data []byte) {
+	var msg ClientMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		cl.reply(SubscriptionMessage{Type: MessageError, Error: "invalid message: " + err.Error()})
+		return
+	}
 
-	// Clean up on close
-	defer func() {
+	switch msg.Type {
+	case MessageSubscribe, MessageUnsubscribe:
+	default:
+		cl.reply(SubscriptionMessage{Type: MessageError, Error: "unknown message type " + msg.Type})
+		return
+	}
+	if (msg.FloorplanID == "") == (msg.JobID == "") {
+		cl.reply(SubscriptionMessage{Type: MessageError, Error: "one of floorplan_id or job_id is required"})
+		return
+	}
+
+	if msg.Type == MessageSubscribe {
+		h.handleSubscribe(cl, msg)
+		return
+	}
+	h.mutex.Lock()
+	delete(cl.subscriptions, msg.FloorplanID)
+	delete(cl.jobs, msg.JobID)
+	h.mutex.Unlock()
+	cl.reply(SubscriptionMessage{Type: MessageUnsubscribed, FloorplanID: msg.FloorplanID, JobID: msg.JobID})
+}
+
+// handleSubscribe subscribes a websocket client, reporting failures to it.
+// Job subscriptions only receive the job's progress from then on; clients
+// read its current state with GET /api/v1/jobs/{id}.
+func (h *Hub) handleSubscribe(cl *Client, msg ClientMessage) {
+	if msg.JobID != "" {
 		h.mutex.Lock()
-		delete(h.clients, conn)
+		cl.jobs[msg.JobID] = true
+		cl.reply(SubscriptionMessage{Type: MessageSubscribed, JobID: msg.JobID})
 		h.mutex.Unlock()
-		conn.Close()
-	}()
+		return
+	}
+	if err := h.subscribe(cl, msg.FloorplanID, msg.LastSeq); err != nil {
+		cl.reply(SubscriptionMessage{Type: MessageError, FloorplanID: msg.FloorplanID, Error: err.Error()})
+	}
+}
 
-	// Keep alive / read loop
-	for {
-		_, _, err := conn.ReadMessage()
+// subscribe adds a subscription and brings the client up to date. With a
+// lastSeq still covered by the replay log, the missed status updates are
+// replayed after the acknowledgement; otherwise the client gets a snapshot of
+// the floorplan's rooms followed by any updates published while it was
+// loaded.
+func (h *Hub) subscribe(cl *Client, floorplanID string, lastSeq *uint64) error {
+	ack := SubscriptionMessage{Type: MessageSubscribed, FloorplanID: floorplanID}
+
+	if lastSeq != nil {
+		h.mutex.Lock()
+		// A backlog that would overflow the send queue is cheaper as a snapshot.
+		if missed, ok := h.updatesSince(floorplanID, *lastSeq); ok && len(missed) < cap(cl.send)-1 {
+			cl.subscriptions[floorplanID] = true
+			cl.reply(ack)
+			for _, u := range missed {
+				cl.reply(u)
+			}
+			h.mutex.Unlock()
+			return nil
+		}
+		h.mutex.Unlock()
+	}
+
+	if h.floorplans == nil {
+		h.mutex.Lock()
+		cl.subscriptions[floorplanID] = true
+		cl.reply(ack)
+		h.mutex.Unlock()
+		return nil
+	}
+
+	// Load the rooms without holding the lock, then replay what was
+	// published meanwhile so nothing between the two is lost. If more was
+	// published than the log or the send queue holds, the snapshot is stale
+	// before it is sent, so it is taken again.
+	for attempt := 0; attempt < snapshotAttempts; attempt++ {
+		h.mutex.RLock()
+		seq := h.seq
+		h.mutex.RUnlock()
+
+		snapshot, err := h.snapshot(floorplanID, seq)
 		if err != nil {
-			break
+			return err
 		}
+
+		h.mutex.Lock()
+		if missed, ok := h.updatesSince(floorplanID, seq); ok && len(missed) < cap(cl.send)-2 {
+			cl.subscriptions[floorplanID] = true
+			cl.reply(ack)
+			cl.reply(snapshot)
+			for _, u := range missed {
+				cl.reply(u)
+			}
+			h.mutex.Unlock()
+			return nil
+		}
+		h.mutex.Unlock()
 	}
+	return errors.New("floorplan is changing too fast to snapshot, subscribe again")
 }
 
+// snapshot loads the rooms of floorplanID as of seq.
+func (h *Hub) snapshot(floorplanID string, seq uint64) (Snapshot, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+	fp, err := h.floorplans.Get(ctx, floorplanID)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot := Snapshot{Type: MessageSnapshot, Seq: seq, FloorplanID: floorplanID, Rooms: make([]RoomStatus, len(fp.Rooms))}
+	for i, room := range fp.Rooms {
+		snapshot.Rooms[i] = RoomStatus{RoomID: room.ID, Status: room.Status, Occupancy: room.Occupancy}
+	}
+	return snapshot, nil
+}
+
+// broadcastSimulation sends random "update" messages for a few hard-coded room
+// names. It only runs when realtime.simulate is enabled, for frontend
+// development without sensors.
 func (h *Hub) broadcastSimulation() {
 	// Simulate room data
 	statuses := []string{"AVAILABLE", "BUSY", "OFFLINE"}
@@ -96,10 +563,5 @@ func (h *Hub) broadcastSimulation() {
 	// Add random updates for other rooms potentially found
 	// In real app, we would track rooms by ID.
 
-	msg, _ := json.Marshal(map[string]interface{}{
-		"type": "update",
-		"data": updates,
-	})
-
-	h.broadcast <- msg
+	h.Broadcast(MessageUpdate, updates)
 }
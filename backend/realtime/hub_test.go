@@ -0,0 +1,491 @@
+package realtime
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/models"
+	"floorplan-whiteboard/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+func newTestHub(t *testing.T) (*Hub, string) {
+	t.Helper()
+	return newTestHubWithConfig(t, config.Default().Realtime, nil)
+}
+
+func newTestHubWithConfig(t *testing.T, cfg config.RealtimeConfig, floorplans FloorplanSource) (*Hub, string) {
+	t.Helper()
+	hub, err := NewHub(cfg, floorplans, nil)
+	if err != nil {
+		t.Fatalf("NewHub() error = %v", err)
+	}
+	return hub, serveHub(t, hub)
+}
+
+// serveHub serves hub's websocket endpoint and returns its URL.
+func serveHub(t *testing.T, hub *Hub) string {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	go hub.Run()
+
+	r := gin.New()
+	r.GET("/ws", hub.HandleWebSocket)
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws"
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readJSON reads the next message into a generic map.
+func readJSON(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg map[string]interface{}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("ReadJSON() error = %v", err)
+	}
+	return msg
+}
+
+// request sends a client message and waits for its acknowledgement.
+func request(t *testing.T, conn *websocket.Conn, msgType, floorplanID string) {
+	t.Helper()
+	if err := conn.WriteJSON(ClientMessage{Type: msgType, FloorplanID: floorplanID}); err != nil {
+		t.Fatalf("WriteJSON() error = %v", err)
+	}
+	ack := readJSON(t, conn)
+	if ack["floorplan_id"] != floorplanID || (ack["type"] != MessageSubscribed && ack["type"] != MessageUnsubscribed) {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+}
+
+func TestHub_StatusUpdatesOnlyReachSubscribers(t *testing.T) {
+	hub, url := newTestHub(t)
+	a, b := dial(t, url), dial(t, url)
+	request(t, a, MessageSubscribe, "fp-1")
+	request(t, b, MessageSubscribe, "fp-2")
+
+	hub.PublishStatus(StatusUpdate{FloorplanID: "fp-1", RoomID: "room-a", Status: models.RoomStatusBusy})
+	hub.PublishStatus(StatusUpdate{FloorplanID: "fp-2", RoomID: "room-b", Status: models.RoomStatusOffline})
+
+	got := readJSON(t, a)
+	if got["type"] != MessageStatusUpdate || got["room_id"] != "room-a" || got["status"] != "BUSY" || got["timestamp"] == "" {
+		t.Errorf("client a got %v, want room-a update", got)
+	}
+	// b's first message must be its own floorplan's update, not fp-1's.
+	if got := readJSON(t, b); got["room_id"] != "room-b" {
+		t.Errorf("client b got %v, want room-b update", got)
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub, url := newTestHub(t)
+	conn := dial(t, url)
+	request(t, conn, MessageSubscribe, "fp-1")
+	request(t, conn, MessageSubscribe, "fp-2")
+	request(t, conn, MessageUnsubscribe, "fp-1")
+
+	hub.PublishStatus(StatusUpdate{FloorplanID: "fp-1", RoomID: "room-a", Status: models.RoomStatusBusy})
+	hub.PublishStatus(StatusUpdate{FloorplanID: "fp-2", RoomID: "room-b", Status: models.RoomStatusBusy})
+
+	if got := readJSON(t, conn); got["room_id"] != "room-b" {
+		t.Errorf("got %v, want only the fp-2 update after unsubscribing from fp-1", got)
+	}
+}
+
+func TestHub_BroadcastReachesEveryone(t *testing.T) {
+	hub, url := newTestHub(t)
+	a, b := dial(t, url), dial(t, url)
+	request(t, a, MessageSubscribe, "fp-1")
+	// b has not subscribed; make sure it is registered before broadcasting.
+	if err := b.WriteJSON(ClientMessage{Type: "bogus"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readJSON(t, b); got["type"] != MessageError {
+		t.Fatalf("got %v, want error for unknown message type", got)
+	}
+
+	hub.Broadcast(MessageJobProgress, map[string]string{"id": "job-1"})
+	for _, conn := range []*websocket.Conn{a, b} {
+		if got := readJSON(t, conn); got["type"] != MessageJobProgress {
+			t.Errorf("got %v, want job_progress broadcast", got)
+		}
+	}
+}
+
+// waitFor polls cond until it holds or the timeout expires.
+func TestHub_JobProgressOnlyReachesJobSubscribers(t *testing.T) {
+	hub, url := newTestHub(t)
+	a, b := dial(t, url), dial(t, url+"?job_id=job-2")
+	if err := a.WriteJSON(ClientMessage{Type: MessageSubscribe, JobID: "job-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := readJSON(t, a); ack["type"] != MessageSubscribed || ack["job_id"] != "job-1" {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+	if ack := readJSON(t, b); ack["type"] != MessageSubscribed || ack["job_id"] != "job-2" {
+		t.Fatalf("unexpected ack %v", ack)
+	}
+
+	hub.PublishJobProgress("job-1", map[string]string{"id": "job-1", "state": "running"})
+	hub.PublishJobProgress("job-2", map[string]string{"id": "job-2", "state": "queued"})
+
+	got := readJSON(t, a)
+	if data, _ := got["data"].(map[string]interface{}); got["type"] != MessageJobProgress || data["id"] != "job-1" {
+		t.Errorf("client a got %v, want job-1 progress", got)
+	}
+	if data, _ := readJSON(t, b)["data"].(map[string]interface{}); data["id"] != "job-2" {
+		t.Errorf("client b got %v, want job-2 progress", data)
+	}
+
+	if err := a.WriteJSON(ClientMessage{Type: MessageSubscribe, FloorplanID: "fp-1", JobID: "job-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readJSON(t, a); got["type"] != MessageError {
+		t.Errorf("subscribing to a floorplan and a job at once got %v, want an error", got)
+	}
+}
+
+func waitFor(t *testing.T, timeout time.Duration, cond func() bool) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return cond()
+}
+
+// floodWithSlowClient connects a fast and a slow (never reading) client and
+// publishes large messages one at a time, checking that each reaches the fast
+// client promptly. It returns the slow connection and the number of messages
+// sent.
+func floodWithSlowClient(t *testing.T, hub *Hub, url string) (*websocket.Conn, int) {
+	t.Helper()
+	fast, slow := dial(t, url), dial(t, url)
+	request(t, fast, MessageSubscribe, "fp-1")
+	request(t, slow, MessageSubscribe, "fp-1")
+
+	// Large enough that the slow client's socket buffers fill after a few
+	// messages and its writer blocks.
+	payload := strings.Repeat("x", 1<<20)
+	const count = 32
+	for i := 0; i < count; i++ {
+		start := time.Now()
+		hub.Broadcast(MessageUpdate, fmt.Sprintf("%d:%s", i, payload))
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Fatalf("Broadcast %d blocked for %v", i, elapsed)
+		}
+		got := readJSON(t, fast)
+		if data, _ := got["data"].(string); !strings.HasPrefix(data, fmt.Sprintf("%d:", i)) {
+			t.Fatalf("fast client got message %.10q, want %d", data, i)
+		}
+	}
+	return slow, count
+}
+
+func TestHub_SlowClientIsDisconnected(t *testing.T) {
+	cfg := config.Default().Realtime
+	cfg.SendQueueSize = 2
+	cfg.SlowClientPolicy = config.SlowClientDisconnect
+	hub, url := newTestHubWithConfig(t, cfg, nil)
+
+	floodWithSlowClient(t, hub, url)
+	if !waitFor(t, 2*time.Second, func() bool { return hub.clientCount() == 1 }) {
+		t.Errorf("clientCount = %d, want the slow client disconnected", hub.clientCount())
+	}
+}
+
+func TestHub_SlowClientMessagesAreDropped(t *testing.T) {
+	cfg := config.Default().Realtime
+	cfg.SendQueueSize = 2
+	cfg.SlowClientPolicy = config.SlowClientDrop
+	hub, url := newTestHubWithConfig(t, cfg, nil)
+
+	slow, sent := floodWithSlowClient(t, hub, url)
+	if n := hub.clientCount(); n != 2 {
+		t.Fatalf("clientCount = %d, want the slow client kept", n)
+	}
+
+	received := 0
+	for {
+		slow.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		if _, _, err := slow.ReadMessage(); err != nil {
+			break
+		}
+		received++
+	}
+	if received == 0 || received >= sent {
+		t.Errorf("slow client received %d of %d messages, want some dropped", received, sent)
+	}
+}
+
+func TestHub_WriteTimeoutDisconnects(t *testing.T) {
+	cfg := config.Default().Realtime
+	cfg.WriteTimeout = 100 * time.Millisecond
+	hub, url := newTestHubWithConfig(t, cfg, nil)
+
+	// The queue never fills, so only the write deadline can drop the client.
+	floodWithSlowClient(t, hub, url)
+	if !waitFor(t, 2*time.Second, func() bool { return hub.clientCount() == 1 }) {
+		t.Errorf("clientCount = %d, want the stalled client disconnected", hub.clientCount())
+	}
+}
+
+func TestHub_UnresponsiveClientTimesOut(t *testing.T) {
+	cfg := config.Default().Realtime
+	cfg.PingInterval = 50 * time.Millisecond
+	cfg.PongTimeout = 200 * time.Millisecond
+	hub, url := newTestHubWithConfig(t, cfg, nil)
+
+	// Reading lets the client answer pings; the idle one never does.
+	alive := dial(t, url)
+	go func() {
+		for {
+			if _, _, err := alive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	dial(t, url)
+	if !waitFor(t, time.Second, func() bool { return hub.clientCount() == 2 }) {
+		t.Fatalf("clientCount = %d, want both clients registered", hub.clientCount())
+	}
+
+	if !waitFor(t, 2*time.Second, func() bool { return hub.clientCount() == 1 }) {
+		t.Fatalf("clientCount = %d, want the idle client timed out", hub.clientCount())
+	}
+	time.Sleep(3 * cfg.PongTimeout)
+	if n := hub.clientCount(); n != 1 {
+		t.Errorf("clientCount = %d, want the responsive client kept", n)
+	}
+}
+
+func TestHub_SnapshotOnConnect(t *testing.T) {
+	repo := store.NewMemoryRepository()
+	fp := &models.Floorplan{Rooms: []models.Room{
+		{ID: "room-a", Status: models.RoomStatusBusy, Occupancy: 4},
+		{ID: "room-b", Status: models.RoomStatusAvailable},
+	}}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+	hub, url := newTestHubWithConfig(t, config.Default().Realtime, repo)
+	hub.PublishStatus(StatusUpdate{FloorplanID: "other", RoomID: "x", Status: models.RoomStatusBusy})
+
+	conn := dial(t, url+"?floorplan_id="+fp.ID)
+	if ack := readJSON(t, conn); ack["type"] != MessageSubscribed {
+		t.Fatalf("got %v, want subscribed ack", ack)
+	}
+	var snapshot Snapshot
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := conn.ReadJSON(&snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Type != MessageSnapshot || snapshot.Seq != 1 || len(snapshot.Rooms) != 2 ||
+		snapshot.Rooms[0] != (RoomStatus{RoomID: "room-a", Status: models.RoomStatusBusy, Occupancy: 4}) {
+		t.Errorf("snapshot = %+v", snapshot)
+	}
+
+	hub.PublishStatus(StatusUpdate{FloorplanID: fp.ID, RoomID: "room-b", Status: models.RoomStatusBusy})
+	if got := readJSON(t, conn); got["room_id"] != "room-b" || got["seq"] != float64(2) {
+		t.Errorf("got %v, want room-b update with seq 2", got)
+	}
+
+	other := dial(t, url)
+	if err := other.WriteJSON(ClientMessage{Type: MessageSubscribe, FloorplanID: "missing"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readJSON(t, other); got["type"] != MessageError || got["floorplan_id"] != "missing" {
+		t.Errorf("got %v, want error for unknown floorplan", got)
+	}
+}
+
+func TestHub_ResumeReplaysMissedUpdates(t *testing.T) {
+	hub, url := newTestHub(t)
+	for _, u := range []StatusUpdate{
+		{FloorplanID: "fp-1", RoomID: "room-a", Status: models.RoomStatusBusy},
+		{FloorplanID: "fp-2", RoomID: "room-x", Status: models.RoomStatusBusy},
+		{FloorplanID: "fp-1", RoomID: "room-b", Status: models.RoomStatusBusy},
+		{FloorplanID: "fp-1", RoomID: "room-a", Status: models.RoomStatusAvailable},
+	} {
+		hub.PublishStatus(u)
+	}
+
+	// The client saw seq 1 before disconnecting.
+	conn := dial(t, url+"?floorplan_id=fp-1&last_seq=1")
+	if ack := readJSON(t, conn); ack["type"] != MessageSubscribed {
+		t.Fatalf("got %v, want subscribed ack", ack)
+	}
+	for _, want := range []float64{3, 4} {
+		if got := readJSON(t, conn); got["type"] != MessageStatusUpdate || got["seq"] != want {
+			t.Errorf("got %v, want replayed update %v", got, want)
+		}
+	}
+	hub.PublishStatus(StatusUpdate{FloorplanID: "fp-1", RoomID: "room-b", Status: models.RoomStatusOffline})
+	if got := readJSON(t, conn); got["seq"] != float64(5) {
+		t.Errorf("got %v, want live update 5", got)
+	}
+}
+
+func TestHub_ResumeBeyondLogSendsSnapshot(t *testing.T) {
+	repo := store.NewMemoryRepository()
+	fp := &models.Floorplan{Rooms: []models.Room{{ID: "room-a", Status: models.RoomStatusOffline}}}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default().Realtime
+	cfg.ReplayLogSize = 2
+	hub, url := newTestHubWithConfig(t, cfg, repo)
+	for i := 0; i < 5; i++ {
+		hub.PublishStatus(StatusUpdate{FloorplanID: fp.ID, RoomID: "room-a", Status: models.RoomStatusOffline})
+	}
+
+	// Seq 1 has been evicted, and 99 is from before a restart.
+	for _, lastSeq := range []uint64{1, 99} {
+		conn := dial(t, url)
+		if err := conn.WriteJSON(ClientMessage{Type: MessageSubscribe, FloorplanID: fp.ID, LastSeq: &lastSeq}); err != nil {
+			t.Fatal(err)
+		}
+		readJSON(t, conn) // Ack
+		if got := readJSON(t, conn); got["type"] != MessageSnapshot || got["seq"] != float64(5) {
+			t.Errorf("last_seq %d: got %v, want snapshot at seq 5", lastSeq, got)
+		}
+	}
+}
+
+// busySource publishes bursts of status updates while floorplans load.
+type busySource struct {
+	FloorplanSource
+	hub    *Hub
+	bursts int // Loads that publish; later ones don't
+	size   int // Updates per burst
+}
+
+func (s *busySource) Get(ctx context.Context, id string) (*models.Floorplan, error) {
+	if s.bursts > 0 {
+		s.bursts--
+		for i := 0; i < s.size; i++ {
+			s.hub.PublishStatus(StatusUpdate{FloorplanID: id, RoomID: "room-a", Status: models.RoomStatusBusy})
+		}
+	}
+	return s.FloorplanSource.Get(ctx, id)
+}
+
+func TestHub_SnapshotRetakenAfterOverflow(t *testing.T) {
+	repo := store.NewMemoryRepository()
+	fp := &models.Floorplan{Rooms: []models.Room{{ID: "room-a", Status: models.RoomStatusBusy}}}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default().Realtime
+	cfg.ReplayLogSize = 4
+	source := &busySource{FloorplanSource: repo, bursts: 1, size: 5}
+	hub, url := newTestHubWithConfig(t, cfg, source)
+	source.hub = hub
+
+	// The first load is outrun by five updates, more than the log holds.
+	conn := dial(t, url+"?floorplan_id="+fp.ID)
+	if ack := readJSON(t, conn); ack["type"] != MessageSubscribed {
+		t.Fatalf("got %v, want subscribed ack", ack)
+	}
+	if got := readJSON(t, conn); got["type"] != MessageSnapshot || got["seq"] != float64(5) {
+		t.Errorf("got %v, want snapshot retaken at seq 5", got)
+	}
+
+	// Loads that are always outrun give up with an error.
+	source.bursts = snapshotAttempts
+	other := dial(t, url+"?floorplan_id="+fp.ID)
+	if got := readJSON(t, other); got["type"] != MessageError {
+		t.Errorf("got %v, want error", got)
+	}
+}
+
+func TestHub_ChecksOrigin(t *testing.T) {
+	hub, url := newTestHub(t)
+	hub.AllowOrigin = func(origin string) bool { return origin == "https://twin.example.com" }
+
+	tryOrigin := func(origin string) error {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, _, err := websocket.DefaultDialer.Dial(url, header)
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}
+
+	if err := tryOrigin("https://twin.example.com"); err != nil {
+		t.Errorf("allowed origin: Dial() error = %v", err)
+	}
+	if err := tryOrigin(""); err != nil {
+		t.Errorf("no origin: Dial() error = %v", err)
+	}
+	if err := tryOrigin("http://" + strings.TrimPrefix(strings.TrimSuffix(url, "/ws"), "ws://")); err != nil {
+		t.Errorf("same origin: Dial() error = %v", err)
+	}
+	if err := tryOrigin("https://evil.example.com"); err == nil {
+		t.Error("unlisted origin was allowed to connect")
+	}
+}
+
+func TestHub_CloseDisconnectsClients(t *testing.T) {
+	hub, url := newTestHub(t)
+	conn := dial(t, url)
+	request(t, conn, MessageSubscribe, "fp-1")
+	sub, err := hub.Subscribe("fp-1", nil, "sse")
+	if err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	hub.Close()
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("ReadMessage() error = %v, want going away close", err)
+	}
+	select {
+	case <-sub.Done():
+	case <-time.After(2 * time.Second):
+		t.Error("subscription not done after Close")
+	}
+	sub.Close()
+
+	// Clients arriving after Close are turned away.
+	late, err := hub.Subscribe("fp-1", nil, "sse")
+	if err != nil {
+		t.Fatalf("Subscribe() after Close error = %v", err)
+	}
+	select {
+	case <-late.Done():
+	default:
+		t.Error("subscription after Close not done")
+	}
+	late.Close()
+	if !waitFor(t, 2*time.Second, func() bool { return hub.clientCount() == 0 }) {
+		t.Errorf("clientCount = %d, want 0", hub.clientCount())
+	}
+	hub.Close() // Closing twice is harmless
+}
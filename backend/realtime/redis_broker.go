@@ -0,0 +1,99 @@
+package realtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"floorplan-whiteboard/config"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisTimeout bounds connecting and subscribing to Redis.
+const redisTimeout = 10 * time.Second
+
+// publishScript numbers and publishes a message in one step, so the order of
+// sequence numbers is the order subscribers receive messages in. Messages are
+// sent as "<seq> <data>".
+var publishScript = redis.NewScript(`
+local seq = redis.call("INCR", KEYS[1])
+redis.call("PUBLISH", ARGV[1], seq .. " " .. ARGV[2])
+return seq
+`)
+
+// RedisBroker is a Broker over Redis pub/sub, for running several backend
+// replicas. Sequence numbers come from a counter stored next to the channel.
+type RedisBroker struct {
+	client  *redis.Client
+	channel string
+	seqKey  string
+	pubsub  *redis.PubSub
+}
+
+// NewRedisBroker connects to the Redis server in cfg.
+func NewRedisBroker(cfg config.RedisConfig) (*RedisBroker, error) {
+	client := redis.NewClient(&redis.Options{Addr: cfg.Addr, Password: cfg.Password, DB: cfg.DB})
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to redis %s: %w", cfg.Addr, err)
+	}
+	return &RedisBroker{client: client, channel: cfg.Channel, seqKey: cfg.Channel + ":seq"}, nil
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, data []byte) error {
+	return publishScript.Run(ctx, b.client, []string{b.seqKey}, b.channel, data).Err()
+}
+
+// Subscribe subscribes to the channel, returning once Redis has confirmed
+// the subscription. A RedisBroker supports one handler.
+func (b *RedisBroker) Subscribe(handler func(uint64, []byte)) error {
+	if b.pubsub != nil {
+		return errors.New("redis broker already subscribed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+	pubsub := b.client.Subscribe(ctx, b.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to redis channel %s: %w", b.channel, err)
+	}
+	b.pubsub = pubsub
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			seq, data, err := parseRedisMessage(msg.Payload)
+			if err != nil {
+				log.Printf("Dropping redis message on %s: %v", b.channel, err)
+				continue
+			}
+			handler(seq, data)
+		}
+	}()
+	return nil
+}
+
+func (b *RedisBroker) Close() error {
+	if b.pubsub != nil {
+		b.pubsub.Close()
+	}
+	return b.client.Close()
+}
+
+func parseRedisMessage(payload string) (uint64, []byte, error) {
+	rawSeq, data, ok := strings.Cut(payload, " ")
+	if !ok {
+		return 0, nil, errors.New("missing sequence number")
+	}
+	seq, err := strconv.ParseUint(rawSeq, 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid sequence number %q", rawSeq)
+	}
+	return seq, []byte(data), nil
+}
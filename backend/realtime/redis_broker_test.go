@@ -0,0 +1,70 @@
+package realtime
+
+import (
+	"testing"
+
+	"floorplan-whiteboard/config"
+	"floorplan-whiteboard/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newRedisHub starts a hub on its own RedisBroker, like one backend replica.
+func newRedisHub(t *testing.T, addr string) (*Hub, string) {
+	t.Helper()
+	cfg := config.Default().Realtime
+	cfg.Redis.Addr = addr
+	broker, err := NewRedisBroker(cfg.Redis)
+	if err != nil {
+		t.Fatalf("NewRedisBroker() error = %v", err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	hub, err := NewHub(cfg, nil, broker)
+	if err != nil {
+		t.Fatalf("NewHub() error = %v", err)
+	}
+	return hub, serveHub(t, hub)
+}
+
+func TestRedisBroker_SharesUpdatesBetweenHubs(t *testing.T) {
+	server := miniredis.RunT(t)
+	hubA, urlA := newRedisHub(t, server.Addr())
+	hubB, urlB := newRedisHub(t, server.Addr())
+
+	onA := dial(t, urlA)
+	request(t, onA, MessageSubscribe, "fp-1")
+
+	hubB.PublishStatus(StatusUpdate{FloorplanID: "fp-1", RoomID: "room-a", Status: models.RoomStatusBusy})
+	if got := readJSON(t, onA); got["room_id"] != "room-a" || got["seq"] != float64(1) {
+		t.Fatalf("client on A got %v, want room-a update 1 published on B", got)
+	}
+	hubA.Broadcast(MessageJobProgress, map[string]string{"id": "job-1"})
+	if got := readJSON(t, onA); got["type"] != MessageJobProgress {
+		t.Fatalf("got %v, want job_progress", got)
+	}
+	hubA.PublishStatus(StatusUpdate{FloorplanID: "fp-1", RoomID: "room-b", Status: models.RoomStatusOffline})
+	if got := readJSON(t, onA); got["room_id"] != "room-b" || got["seq"] != float64(3) {
+		t.Fatalf("got %v, want room-b update 3", got)
+	}
+
+	// The client moves to replica B and resumes from the same sequence.
+	onB := dial(t, urlB+"?floorplan_id=fp-1&last_seq=1")
+	if ack := readJSON(t, onB); ack["type"] != MessageSubscribed {
+		t.Fatalf("got %v, want subscribed ack", ack)
+	}
+	if got := readJSON(t, onB); got["room_id"] != "room-b" || got["seq"] != float64(3) {
+		t.Errorf("client on B got %v, want replayed update 3", got)
+	}
+}
+
+func TestParseRedisMessage(t *testing.T) {
+	seq, data, err := parseRedisMessage(`42 {"status":{}}`)
+	if err != nil || seq != 42 || string(data) != `{"status":{}}` {
+		t.Errorf("parseRedisMessage() = %d, %q, %v", seq, data, err)
+	}
+	for _, bad := range []string{"", "{}", "x {}"} {
+		if _, _, err := parseRedisMessage(bad); err == nil {
+			t.Errorf("parseRedisMessage(%q) expected error", bad)
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"floorplan-whiteboard/models"
+
+	bolt "go.etcd.io/bbolt"
+	berrors "go.etcd.io/bbolt/errors"
+)
+
+var (
+	floorplansBucket = []byte("floorplans")
+	// imagesBucket holds each floorplan's ImageURL under its ID, so that
+	// frequent edits such as occupancy updates don't rewrite the image.
+	imagesBucket = []byte("images")
+	// historyBucket holds one nested bucket of status changes per floorplan,
+	// keyed by historyKey.
+	historyBucket = []byte("history")
+)
+
+// BoltRepository is a FloorplanRepository backed by a single BoltDB file.
+// Each floorplan, including its rooms, is stored as one JSON document; its
+// image is stored apart from it.
+type BoltRepository struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens (or creates) the database file at path.
+func OpenBolt(path string) (*BoltRepository, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open floorplan store: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(floorplansBucket); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(imagesBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(historyBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize floorplan store: %w", err)
+	}
+
+	return &BoltRepository{db: db}, nil
+}
+
+func (r *BoltRepository) Create(ctx context.Context, fps ...*models.Floorplan) error {
+	encoded := make([][]byte, len(fps))
+	for i, fp := range fps {
+		prepareCreate(fp)
+		data, err := encodeFloorplan(fp)
+		if err != nil {
+			return err
+		}
+		encoded[i] = data
+	}
+
+	// One transaction, so a failed Put leaves none of the floorplans stored.
+	return r.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(floorplansBucket)
+		for i, fp := range fps {
+			if err := bucket.Put([]byte(fp.ID), encoded[i]); err != nil {
+				return err
+			}
+			if err := putImage(tx, fp); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *BoltRepository) Get(ctx context.Context, id string) (*models.Floorplan, error) {
+	var fp models.Floorplan
+	err := r.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(floorplansBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return decodeFloorplan(tx, data, &fp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fp, nil
+}
+
+func (r *BoltRepository) ListPage(ctx context.Context, opts ListOptions) ([]models.Floorplan, int, error) {
+	// Only the documents are read; the images bucket is left alone.
+	list := []models.Floorplan{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(floorplansBucket).ForEach(func(_, data []byte) error {
+			var fp models.Floorplan
+			if err := json.Unmarshal(data, &fp); err != nil {
+				return err
+			}
+			fp.ImageURL = "" // Set in documents written before images were split out
+			list = append(list, fp)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return opts.page(list), len(list), nil
+}
+
+func (r *BoltRepository) Update(ctx context.Context, fp *models.Floorplan) error {
+	data, err := encodeFloorplan(fp)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(floorplansBucket)
+		if b.Get([]byte(fp.ID)) == nil {
+			return ErrNotFound
+		}
+		if err := b.Put([]byte(fp.ID), data); err != nil {
+			return err
+		}
+		return putImage(tx, fp)
+	})
+}
+
+func (r *BoltRepository) Modify(ctx context.Context, id string, fn func(fp *models.Floorplan) error) (*models.Floorplan, error) {
+	var fp models.Floorplan
+	err := r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(floorplansBucket)
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		if err := decodeFloorplan(tx, data, &fp); err != nil {
+			return err
+		}
+		if err := fn(&fp); err != nil {
+			return err
+		}
+		data, err := encodeFloorplan(&fp)
+		if err != nil {
+			return err
+		}
+		if err := b.Put([]byte(id), data); err != nil {
+			return err
+		}
+		return putImage(tx, &fp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &fp, nil
+}
+
+func (r *BoltRepository) Delete(ctx context.Context, id string) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(floorplansBucket)
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(historyBucket).DeleteBucket([]byte(id)); err != nil && !errors.Is(err, berrors.ErrBucketNotFound) {
+			return err
+		}
+		if err := tx.Bucket(imagesBucket).Delete([]byte(id)); err != nil {
+			return err
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// encodeFloorplan returns the document stored for fp: everything but its
+// image (see putImage).
+func encodeFloorplan(fp *models.Floorplan) ([]byte, error) {
+	doc := *fp
+	doc.ImageURL = ""
+	data, err := json.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode floorplan: %w", err)
+	}
+	return data, nil
+}
+
+// decodeFloorplan reads a stored document into fp and attaches its image.
+// Documents written before images were split out keep their own.
+func decodeFloorplan(tx *bolt.Tx, data []byte, fp *models.Floorplan) error {
+	if err := json.Unmarshal(data, fp); err != nil {
+		return err
+	}
+	if image := tx.Bucket(imagesBucket).Get([]byte(fp.ID)); image != nil {
+		fp.ImageURL = string(image)
+	}
+	return nil
+}
+
+// putImage stores fp's image, unless it is the one already stored.
+func putImage(tx *bolt.Tx, fp *models.Floorplan) error {
+	b := tx.Bucket(imagesBucket)
+	if fp.ImageURL == "" {
+		return b.Delete([]byte(fp.ID))
+	}
+	if string(b.Get([]byte(fp.ID))) == fp.ImageURL {
+		return nil
+	}
+	return b.Put([]byte(fp.ID), []byte(fp.ImageURL))
+}
+
+func (r *BoltRepository) AppendHistory(ctx context.Context, changes []models.StatusChange) error {
+	return r.db.Update(func(tx *bolt.Tx) error {
+		for _, change := range changes {
+			b, err := tx.Bucket(historyBucket).CreateBucketIfNotExists([]byte(change.FloorplanID))
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(change)
+			if err != nil {
+				return fmt.Errorf("failed to encode status change: %w", err)
+			}
+			seq, _ := b.NextSequence()
+			if err := b.Put(historyKey(change.RoomID, change.Timestamp, seq), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *BoltRepository) RoomHistory(ctx context.Context, floorplanID, roomID string, from, to time.Time) ([]models.StatusChange, error) {
+	list := []models.StatusChange{}
+	err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket([]byte(floorplanID))
+		if b == nil {
+			return nil
+		}
+		end := historyBound(roomID, to)
+		c := b.Cursor()
+		for k, v := c.Seek(historyBound(roomID, from)); k != nil && bytes.Compare(k, end) < 0; k, v = c.Next() {
+			var change models.StatusChange
+			if err := json.Unmarshal(v, &change); err != nil {
+				return err
+			}
+			list = append(list, change)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (r *BoltRepository) LastChangeBefore(ctx context.Context, floorplanID, roomID string, t time.Time) (*models.StatusChange, error) {
+	var last *models.StatusChange
+	err := r.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(historyBucket).Bucket([]byte(floorplanID))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		k, v := c.Seek(historyBound(roomID, t))
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		if k == nil || !bytes.HasPrefix(k, historyPrefix(roomID)) {
+			return nil
+		}
+		last = &models.StatusChange{}
+		return json.Unmarshal(v, last)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return last, nil
+}
+
+// historyPrefix starts the keys of one room's changes.
+func historyPrefix(roomID string) []byte {
+	return append([]byte(roomID), 0)
+}
+
+// Instants outside the range of UnixNano (roughly years 1678 to 2262) are
+// clamped to its ends.
+var (
+	minHistoryTime = time.Unix(0, math.MinInt64)
+	maxHistoryTime = time.Unix(0, math.MaxInt64)
+)
+
+// historyBound returns the key a range from or to t starts or ends at. A
+// bound past the clamped range sorts after the changes clamped to its end.
+func historyBound(roomID string, t time.Time) []byte {
+	if t.After(maxHistoryTime) {
+		return historyKey(roomID, t, math.MaxUint64)
+	}
+	return historyKey(roomID, t, 0)
+}
+
+// historyKey orders a room's changes by time, then by append order: the room
+// ID, a zero byte, the big-endian Unix nanoseconds with the sign bit flipped,
+// so that instants before 1970 sort first, and a sequence number.
+func historyKey(roomID string, t time.Time, seq uint64) []byte {
+	if t.Before(minHistoryTime) {
+		t = minHistoryTime
+	} else if t.After(maxHistoryTime) {
+		t = maxHistoryTime
+	}
+	key := historyPrefix(roomID)
+	key = binary.BigEndian.AppendUint64(key, uint64(t.UnixNano())^1<<63)
+	return binary.BigEndian.AppendUint64(key, seq)
+}
+
+func (r *BoltRepository) Close() error {
+	return r.db.Close()
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"floorplan-whiteboard/models"
+)
+
+// MemoryRepository is a FloorplanRepository kept entirely in process memory.
+// It is intended for tests and local demos; contents are lost on restart.
+type MemoryRepository struct {
+	mutex      sync.RWMutex
+	floorplans map[string]*models.Floorplan
+	history    map[string][]models.StatusChange // By floorplan ID, in append order
+}
+
+// NewMemoryRepository returns an empty in-memory repository.
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		floorplans: make(map[string]*models.Floorplan),
+		history:    make(map[string][]models.StatusChange),
+	}
+}
+
+func (r *MemoryRepository) Create(ctx context.Context, fps ...*models.Floorplan) error {
+	for _, fp := range fps {
+		prepareCreate(fp)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, fp := range fps {
+		r.floorplans[fp.ID] = cloneFloorplan(fp)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) Get(ctx context.Context, id string) (*models.Floorplan, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	fp, ok := r.floorplans[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneFloorplan(fp), nil
+}
+
+func (r *MemoryRepository) ListPage(ctx context.Context, opts ListOptions) ([]models.Floorplan, int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	list := make([]models.Floorplan, 0, len(r.floorplans))
+	for _, fp := range r.floorplans {
+		list = append(list, *fp)
+	}
+	page := opts.page(list)
+	for i := range page {
+		page[i] = *cloneFloorplan(&page[i])
+		page[i].ImageURL = ""
+	}
+	return page, len(list), nil
+}
+
+func (r *MemoryRepository) Update(ctx context.Context, fp *models.Floorplan) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.floorplans[fp.ID]; !ok {
+		return ErrNotFound
+	}
+	r.floorplans[fp.ID] = cloneFloorplan(fp)
+	return nil
+}
+
+func (r *MemoryRepository) Modify(ctx context.Context, id string, fn func(fp *models.Floorplan) error) (*models.Floorplan, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stored, ok := r.floorplans[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	fp := cloneFloorplan(stored)
+	if err := fn(fp); err != nil {
+		return nil, err
+	}
+	r.floorplans[id] = cloneFloorplan(fp)
+	return fp, nil
+}
+
+func (r *MemoryRepository) Delete(ctx context.Context, id string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.floorplans[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.floorplans, id)
+	delete(r.history, id)
+	return nil
+}
+
+func (r *MemoryRepository) AppendHistory(ctx context.Context, changes []models.StatusChange) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, change := range changes {
+		r.history[change.FloorplanID] = append(r.history[change.FloorplanID], change)
+	}
+	return nil
+}
+
+func (r *MemoryRepository) RoomHistory(ctx context.Context, floorplanID, roomID string, from, to time.Time) ([]models.StatusChange, error) {
+	list := []models.StatusChange{}
+	for _, change := range r.roomHistory(floorplanID, roomID) {
+		if !change.Timestamp.Before(from) && change.Timestamp.Before(to) {
+			list = append(list, change)
+		}
+	}
+	return list, nil
+}
+
+func (r *MemoryRepository) LastChangeBefore(ctx context.Context, floorplanID, roomID string, t time.Time) (*models.StatusChange, error) {
+	var last *models.StatusChange
+	for _, change := range r.roomHistory(floorplanID, roomID) {
+		if !change.Timestamp.Before(t) {
+			break
+		}
+		last = &change
+	}
+	return last, nil
+}
+
+// roomHistory returns a copy of a room's changes ordered by Timestamp.
+func (r *MemoryRepository) roomHistory(floorplanID, roomID string) []models.StatusChange {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var list []models.StatusChange
+	for _, change := range r.history[floorplanID] {
+		if change.RoomID == roomID {
+			list = append(list, change)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Timestamp.Before(list[j].Timestamp) })
+	return list
+}
+
+func (r *MemoryRepository) Close() error {
+	return nil
+}
+
+// sortByCreatedAt orders floorplans oldest first, breaking ties by ID so the
+// order is stable across implementations.
+func sortByCreatedAt(list []models.Floorplan) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].ID < list[j].ID
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
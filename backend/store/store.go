@@ -0,0 +1,143 @@
+package store
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"floorplan-whiteboard/models"
+)
+
+// ErrNotFound is returned when a floorplan does not exist in the repository.
+var ErrNotFound = errors.New("floorplan not found")
+
+// FloorplanRepository persists processed floorplans together with their rooms.
+type FloorplanRepository interface {
+	// Create stores new floorplans, all or none. Empty floorplan/room IDs and a zero CreatedAt are filled in.
+	Create(ctx context.Context, fps ...*models.Floorplan) error
+	// Get returns the floorplan with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*models.Floorplan, error)
+	// ListPage returns a page of floorplans ordered by CreatedAt, without
+	// their ImageURL, and the number of floorplans stored.
+	ListPage(ctx context.Context, opts ListOptions) (page []models.Floorplan, total int, err error)
+	// Update replaces an existing floorplan or returns ErrNotFound.
+	Update(ctx context.Context, fp *models.Floorplan) error
+	// Modify loads a floorplan, applies fn to it and stores the result, with
+	// no other write to the floorplan in between. Nothing is stored if fn
+	// returns an error, which Modify returns. fn must not call the repository.
+	Modify(ctx context.Context, id string, fn func(fp *models.Floorplan) error) (*models.Floorplan, error)
+	// Delete removes a floorplan or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+	// Close releases any underlying resources.
+	Close() error
+}
+
+// ListOptions selects a page for ListPage.
+type ListOptions struct {
+	Offset     int  // Floorplans to skip; past the end gives an empty page
+	Limit      int  // Page size; 0 returns every floorplan from Offset
+	Descending bool // Newest first instead of oldest first
+}
+
+// page sorts list by CreatedAt and returns the part opts selects.
+func (opts ListOptions) page(list []models.Floorplan) []models.Floorplan {
+	sortByCreatedAt(list)
+	if opts.Descending {
+		slices.Reverse(list)
+	}
+	start := min(max(opts.Offset, 0), len(list))
+	end := len(list)
+	if opts.Limit > 0 && opts.Limit < end-start {
+		end = start + opts.Limit
+	}
+	return list[start:end]
+}
+
+// HistoryRepository records room status changes over time.
+type HistoryRepository interface {
+	// AppendHistory records changes.
+	AppendHistory(ctx context.Context, changes []models.StatusChange) error
+	// RoomHistory returns a room's changes with from <= Timestamp < to, oldest first.
+	RoomHistory(ctx context.Context, floorplanID, roomID string, from, to time.Time) ([]models.StatusChange, error)
+	// LastChangeBefore returns a room's latest change before t, or nil if there is none.
+	LastChangeBefore(ctx context.Context, floorplanID, roomID string, t time.Time) (*models.StatusChange, error)
+}
+
+// NewID returns a random RFC 4122 version 4 UUID string.
+func NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(fmt.Sprintf("store: failed to read random bytes: %v", err))
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	h := hex.EncodeToString(b[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// prepareCreate fills in generated fields before a floorplan is first stored.
+// Rooms without an ID are given one so they can be addressed individually.
+func prepareCreate(fp *models.Floorplan) {
+	if fp.ID == "" {
+		fp.ID = NewID()
+	}
+	if fp.CreatedAt.IsZero() {
+		fp.CreatedAt = time.Now().UTC()
+	}
+	for i := range fp.Rooms {
+		if fp.Rooms[i].ID == "" {
+			fp.Rooms[i].ID = NewID()
+		}
+		fp.Rooms[i].FloorplanID = fp.ID
+	}
+}
+
+// cloneFloorplan returns a deep copy so callers cannot mutate stored state.
+func cloneFloorplan(fp *models.Floorplan) *models.Floorplan {
+	out := *fp
+	if fp.Rooms != nil {
+		out.Rooms = make([]models.Room, len(fp.Rooms))
+		for i, room := range fp.Rooms {
+			out.Rooms[i] = room
+			if room.Rect != nil {
+				out.Rooms[i].Rect = append(models.Rect(nil), room.Rect...)
+			}
+			if room.Polygon != nil {
+				out.Rooms[i].Polygon = append([]models.Point(nil), room.Polygon...)
+			}
+		}
+	}
+	if fp.Walls != nil {
+		out.Walls = append([]models.Wall(nil), fp.Walls...)
+	}
+	if fp.Doors != nil {
+		out.Doors = make([]models.Door, len(fp.Doors))
+		for i, door := range fp.Doors {
+			out.Doors[i] = door
+			out.Doors[i].RoomIDs = append([]string(nil), door.RoomIDs...)
+		}
+	}
+	if fp.Windows != nil {
+		out.Windows = make([]models.Window, len(fp.Windows))
+		for i, window := range fp.Windows {
+			out.Windows[i] = window
+			out.Windows[i].RoomIDs = append([]string(nil), window.RoomIDs...)
+		}
+	}
+	if fp.ContentBox != nil {
+		box := *fp.ContentBox
+		box.Bounds = append([]int(nil), box.Bounds...)
+		box.Crop = append(models.Rect(nil), box.Crop...)
+		out.ContentBox = &box
+	}
+	if fp.GeoTransform != nil {
+		t := *fp.GeoTransform
+		out.GeoTransform = &t
+	}
+	return &out
+}
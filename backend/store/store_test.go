@@ -0,0 +1,360 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"floorplan-whiteboard/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	testRepository(t, NewMemoryRepository())
+}
+
+func TestBoltRepository(t *testing.T) {
+	repo, err := OpenBolt(filepath.Join(t.TempDir(), "floorplans.db"))
+	if err != nil {
+		t.Fatalf("OpenBolt() error = %v", err)
+	}
+	defer repo.Close()
+
+	testRepository(t, repo)
+}
+
+func TestBoltRepository_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "floorplans.db")
+
+	repo, err := OpenBolt(path)
+	if err != nil {
+		t.Fatalf("OpenBolt() error = %v", err)
+	}
+	fp := &models.Floorplan{Filename: "plan.png", Rooms: []models.Room{{Name: "Office 101"}}}
+	if err := repo.Create(context.Background(), fp); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	repo.Close()
+
+	repo, err = OpenBolt(path)
+	if err != nil {
+		t.Fatalf("OpenBolt() reopen error = %v", err)
+	}
+	defer repo.Close()
+
+	got, err := repo.Get(context.Background(), fp.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if len(got.Rooms) != 1 || got.Rooms[0].Name != "Office 101" {
+		t.Fatalf("expected persisted room Office 101, got %+v", got.Rooms)
+	}
+}
+
+func TestBoltRepository_StoresImagesApart(t *testing.T) {
+	repo, err := OpenBolt(filepath.Join(t.TempDir(), "floorplans.db"))
+	if err != nil {
+		t.Fatalf("OpenBolt() error = %v", err)
+	}
+	defer repo.Close()
+	ctx := context.Background()
+	stored := func(bucket []byte, id string) (value string) {
+		repo.db.View(func(tx *bolt.Tx) error {
+			value = string(tx.Bucket(bucket).Get([]byte(id)))
+			return nil
+		})
+		return value
+	}
+
+	fp := &models.Floorplan{ImageURL: "data:image/png;base64,AAAA", Rooms: []models.Room{{Name: "Office 101"}}}
+	if err := repo.Create(ctx, fp); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if doc := stored(floorplansBucket, fp.ID); strings.Contains(doc, "base64") {
+		t.Errorf("document holds the image: %s", doc)
+	}
+	if image := stored(imagesBucket, fp.ID); image != fp.ImageURL {
+		t.Errorf("stored image = %q", image)
+	}
+
+	// Documents written with the image inline still read, and move it out
+	// on the next write.
+	legacy := `{"id":"legacy","image_url":"data:image/png;base64,BBBB","rooms":[{"id":"a","occupancy":1}]}`
+	repo.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(floorplansBucket).Put([]byte("legacy"), []byte(legacy))
+	})
+	if got, err := repo.Get(ctx, "legacy"); err != nil || got.ImageURL != "data:image/png;base64,BBBB" {
+		t.Fatalf("Get(legacy) = %+v, %v", got, err)
+	}
+	if list, _, _ := repo.ListPage(ctx, ListOptions{}); len(list) != 2 || list[0].ImageURL != "" || list[1].ImageURL != "" {
+		t.Fatalf("ListPage() = %+v, want no images", list)
+	}
+	if _, err := repo.Modify(ctx, "legacy", func(fp *models.Floorplan) error {
+		fp.Rooms[0].Occupancy++
+		return nil
+	}); err != nil {
+		t.Fatalf("Modify(legacy) error = %v", err)
+	}
+	if doc, image := stored(floorplansBucket, "legacy"), stored(imagesBucket, "legacy"); strings.Contains(doc, "base64") || image != "data:image/png;base64,BBBB" {
+		t.Errorf("after Modify, document = %s, image = %q", doc, image)
+	}
+
+	if err := repo.Delete(ctx, fp.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if image := stored(imagesBucket, fp.ID); image != "" {
+		t.Errorf("Delete() left the image behind")
+	}
+}
+
+// testRepository exercises the FloorplanRepository contract shared by all implementations.
+func testRepository(t *testing.T, repo FloorplanRepository) {
+	ctx := context.Background()
+
+	first := &models.Floorplan{
+		Filename:  "first.png",
+		ImageURL:  "data:image/png;base64,Zmlyc3Q=",
+		Width:     800,
+		Height:    600,
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Rooms: []models.Room{
+			{Name: "Office 101", Type: models.RoomTypeOffice, Rect: models.Rect{1, 2, 3, 4}, Status: models.RoomStatusAvailable},
+		},
+	}
+	if err := repo.Create(ctx, first); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if first.ID == "" {
+		t.Fatal("Create() did not assign an ID")
+	}
+	if first.Rooms[0].ID == "" || first.Rooms[0].FloorplanID != first.ID {
+		t.Fatalf("room = %+v, want generated ID and FloorplanID %q", first.Rooms[0], first.ID)
+	}
+
+	second := &models.Floorplan{Filename: "second.png", CreatedAt: first.CreatedAt.Add(time.Hour)}
+	if err := repo.Create(ctx, second); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	got, err := repo.Get(ctx, first.ID)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Filename != "first.png" || got.ImageURL != first.ImageURL || got.Width != 800 || len(got.Rooms) != 1 {
+		t.Fatalf("Get() = %+v, want stored first floorplan", got)
+	}
+
+	// Mutating the returned copy must not change stored state.
+	got.Rooms[0].Rect[0] = 99
+	again, _ := repo.Get(ctx, first.ID)
+	if again.Rooms[0].Rect[0] != 1 {
+		t.Fatalf("stored rect was mutated through returned copy: %v", again.Rooms[0].Rect)
+	}
+
+	list, total, err := repo.ListPage(ctx, ListOptions{})
+	if err != nil {
+		t.Fatalf("ListPage() error = %v", err)
+	}
+	if total != 2 || len(list) != 2 || list[0].ID != first.ID || list[1].ID != second.ID {
+		t.Fatalf("ListPage() returned %d of %d floorplans in unexpected order", len(list), total)
+	}
+	if list[0].ImageURL != "" || len(list[0].Rooms) != 1 {
+		t.Fatalf("ListPage() item = %+v, want rooms without the image", list[0])
+	}
+	for _, tc := range []struct {
+		opts ListOptions
+		want []string
+	}{
+		{ListOptions{Descending: true}, []string{second.ID, first.ID}},
+		{ListOptions{Offset: 1, Limit: 1}, []string{second.ID}},
+		{ListOptions{Limit: 1, Descending: true}, []string{second.ID}},
+		{ListOptions{Offset: 5, Limit: 1}, nil},
+	} {
+		page, total, _ := repo.ListPage(ctx, tc.opts)
+		var ids []string
+		for _, fp := range page {
+			ids = append(ids, fp.ID)
+		}
+		if total != 2 || !slices.Equal(ids, tc.want) {
+			t.Errorf("ListPage(%+v) = %v of %d, want %v of 2", tc.opts, ids, total, tc.want)
+		}
+	}
+
+	got.Filename = "renamed.png"
+	got.ImageURL = "data:image/png;base64,cmVuYW1lZA=="
+	if err := repo.Update(ctx, got); err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	updated, _ := repo.Get(ctx, first.ID)
+	if updated.Filename != "renamed.png" || updated.ImageURL != got.ImageURL {
+		t.Fatalf("Update() did not persist, filename = %q, image = %q", updated.Filename, updated.ImageURL)
+	}
+
+	if err := repo.Update(ctx, &models.Floorplan{ID: "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Update() missing error = %v, want ErrNotFound", err)
+	}
+
+	// Concurrent Modify calls must not lose each other's changes.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.Modify(ctx, first.ID, func(fp *models.Floorplan) error {
+				fp.Rooms[0].Occupancy++
+				return nil
+			}); err != nil {
+				t.Errorf("Modify() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	modified, _ := repo.Get(ctx, first.ID)
+	if modified.Rooms[0].Occupancy != 20 {
+		t.Fatalf("occupancy after 20 concurrent increments = %d", modified.Rooms[0].Occupancy)
+	}
+	if modified.ImageURL != got.ImageURL {
+		t.Fatalf("Modify() lost the image, got %q", modified.ImageURL)
+	}
+
+	errRejected := errors.New("rejected")
+	if _, err := repo.Modify(ctx, first.ID, func(fp *models.Floorplan) error {
+		fp.Filename = "discarded.png"
+		return errRejected
+	}); !errors.Is(err, errRejected) {
+		t.Fatalf("Modify() error = %v, want fn's error", err)
+	}
+	if unchanged, _ := repo.Get(ctx, first.ID); unchanged.Filename != "renamed.png" {
+		t.Fatalf("Modify() stored a change after fn failed, filename = %q", unchanged.Filename)
+	}
+	if _, err := repo.Modify(ctx, "missing", func(*models.Floorplan) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Modify() missing error = %v, want ErrNotFound", err)
+	}
+
+	if err := repo.Delete(ctx, first.ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := repo.Get(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get() after delete error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Delete() missing error = %v, want ErrNotFound", err)
+	}
+
+	pages := []*models.Floorplan{{Filename: "plan.pdf", Page: 1}, {Filename: "plan.pdf", Page: 2}}
+	if err := repo.Create(ctx, pages...); err != nil {
+		t.Fatalf("Create() batch error = %v", err)
+	}
+	for _, page := range pages {
+		if got, err := repo.Get(ctx, page.ID); err != nil || got.Page != page.Page {
+			t.Fatalf("Get(page %d) = %+v, %v", page.Page, got, err)
+		}
+	}
+}
+
+func TestMemoryRepository_History(t *testing.T) {
+	testHistory(t, NewMemoryRepository())
+}
+
+func TestBoltRepository_History(t *testing.T) {
+	repo, err := OpenBolt(filepath.Join(t.TempDir(), "floorplans.db"))
+	if err != nil {
+		t.Fatalf("OpenBolt() error = %v", err)
+	}
+	defer repo.Close()
+
+	testHistory(t, repo)
+}
+
+// historyRepository is what both implementations provide.
+type historyRepository interface {
+	FloorplanRepository
+	HistoryRepository
+}
+
+// testHistory exercises the HistoryRepository contract shared by all implementations.
+func testHistory(t *testing.T, repo historyRepository) {
+	ctx := context.Background()
+	fp := &models.Floorplan{Rooms: []models.Room{{ID: "room-a"}, {ID: "room-b"}}}
+	if err := repo.Create(ctx, fp); err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	change := func(roomID string, minutes int, status models.RoomStatus) models.StatusChange {
+		return models.StatusChange{FloorplanID: fp.ID, RoomID: roomID, Status: status, Timestamp: base.Add(time.Duration(minutes) * time.Minute)}
+	}
+	// Appended out of order, with two changes at the same instant.
+	if err := repo.AppendHistory(ctx, []models.StatusChange{
+		change("room-a", 30, models.RoomStatusAvailable),
+		change("room-a", 0, models.RoomStatusBusy),
+		change("room-b", 10, models.RoomStatusBusy),
+		change("room-a", 60, models.RoomStatusBusy),
+		change("room-a", 60, models.RoomStatusOffline),
+	}); err != nil {
+		t.Fatalf("AppendHistory() error = %v", err)
+	}
+
+	got, err := repo.RoomHistory(ctx, fp.ID, "room-a", base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("RoomHistory() error = %v", err)
+	}
+	if len(got) != 2 || got[0].Status != models.RoomStatusBusy || got[1].Status != models.RoomStatusAvailable {
+		t.Errorf("RoomHistory([09:00, 10:00)) = %+v, want BUSY then AVAILABLE", got)
+	}
+	if all, _ := repo.RoomHistory(ctx, fp.ID, "room-a", base, base.Add(2*time.Hour)); len(all) != 4 || all[3].Status != models.RoomStatusOffline {
+		t.Errorf("RoomHistory(all) = %+v, want same-instant changes in append order", all)
+	}
+
+	last, err := repo.LastChangeBefore(ctx, fp.ID, "room-a", base.Add(45*time.Minute))
+	if err != nil || last == nil || last.Status != models.RoomStatusAvailable {
+		t.Errorf("LastChangeBefore(09:45) = %+v, %v, want AVAILABLE", last, err)
+	}
+	if last, _ := repo.LastChangeBefore(ctx, fp.ID, "room-a", base); last != nil {
+		t.Errorf("LastChangeBefore(first) = %+v, want nil", last)
+	}
+	if last, _ := repo.LastChangeBefore(ctx, fp.ID, "room-b", base.Add(24*time.Hour)); last == nil || last.RoomID != "room-b" {
+		t.Errorf("LastChangeBefore(room-b) = %+v", last)
+	}
+
+	// Instants before 1970 and far in the future still order correctly.
+	early, late := time.Date(1969, 12, 31, 23, 0, 0, 0, time.UTC), time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := repo.AppendHistory(ctx, []models.StatusChange{
+		{FloorplanID: fp.ID, RoomID: "room-b", Status: models.RoomStatusOffline, Timestamp: late},
+		{FloorplanID: fp.ID, RoomID: "room-b", Status: models.RoomStatusAvailable, Timestamp: early},
+	}); err != nil {
+		t.Fatalf("AppendHistory() error = %v", err)
+	}
+	if got, _ := repo.RoomHistory(ctx, fp.ID, "room-b", time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), base); len(got) != 1 || got[0].Status != models.RoomStatusAvailable {
+		t.Errorf("RoomHistory([1900, 2026)) = %+v, want the 1969 change", got)
+	}
+	if got, _ := repo.RoomHistory(ctx, fp.ID, "room-b", early, late.Add(time.Hour)); len(got) != 3 || got[0].Status != models.RoomStatusAvailable || got[2].Status != models.RoomStatusOffline {
+		t.Errorf("RoomHistory([1969, 3000]) = %+v, want AVAILABLE, BUSY, OFFLINE", got)
+	}
+	if last, _ := repo.LastChangeBefore(ctx, fp.ID, "room-b", base); last == nil || last.Status != models.RoomStatusAvailable {
+		t.Errorf("LastChangeBefore(room-b, 09:00) = %+v, want the 1969 change", last)
+	}
+
+	if err := repo.Delete(ctx, fp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.RoomHistory(ctx, fp.ID, "room-a", base, base.Add(2*time.Hour)); len(got) != 0 {
+		t.Errorf("RoomHistory after Delete = %+v, want empty", got)
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	if len(a) != 36 || a[14] != '4' {
+		t.Fatalf("NewID() = %q, want version 4 UUID", a)
+	}
+	if a == b {
+		t.Fatal("NewID() returned duplicate IDs")
+	}
+}
@@ -3,9 +3,14 @@ export class WebSocketService {
         this.url = url;
         this.socket = null;
         this.listeners = [];
+        this.openListeners = [];
+        this.pending = [];
+        this.closed = false;
+        this.retryDelay = 1000;
     }
 
     connect() {
+        this.closed = false;
         this.socket = new WebSocket(this.url);
         this.socket.onmessage = (event) => {
             try {
@@ -15,16 +20,45 @@ export class WebSocketService {
                 console.error("WS Parse Error", e);
             }
         };
-        this.socket.onopen = () => console.log("WS Connected");
-        this.socket.onclose = () => console.log("WS Closed");
+        this.socket.onopen = () => {
+            console.log("WS Connected");
+            this.retryDelay = 1000;
+            this.pending.forEach(msg => this.socket.send(msg));
+            this.pending = [];
+            this.openListeners.forEach(cb => cb());
+        };
+        // The server drops clients that fall behind; reconnect with backoff
+        // unless close() was called.
+        this.socket.onclose = () => {
+            console.log("WS Closed");
+            if (this.closed) return;
+            setTimeout(() => this.connect(), this.retryDelay);
+            this.retryDelay = Math.min(this.retryDelay * 2, 30000);
+        };
         this.socket.onerror = (e) => console.error("WS Error", e);
     }
 
+    // send delivers msg as JSON, queueing it until the socket is open.
+    send(msg) {
+        const data = JSON.stringify(msg);
+        if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+            this.socket.send(data);
+        } else {
+            this.pending.push(data);
+        }
+    }
+
     onMessage(callback) {
         this.listeners.push(callback);
     }
 
+    // onOpen registers a callback run on every (re)connect.
+    onOpen(callback) {
+        this.openListeners.push(callback);
+    }
+
     close() {
+        this.closed = true;
         if (this.socket) this.socket.close();
     }
 }